@@ -0,0 +1,293 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// Handler for <base>/api/account/... calls
+type AccountHandler struct {
+	EmailHandler   *EmailHandler
+	VerifyHandler  *VerifyHandler
+	ForgotHandler  *ForgotHandler
+	ResetHandler   *ResetHandler
+	TOTPHandler    *TOTPHandler
+	APIKeyHandler  *APIKeyHandler
+	OIDCHandler    *OIDCHandler
+	WebhookHandler *WebhookHandler
+}
+
+// AccountHandler's ServeHTTP serves URLs of account profile
+func (h *AccountHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	// <base>/api/account/totp/..., <base>/api/account/keys/..., <base>/api/account/oidc and
+	// <base>/api/account/webhooks/... have their own sub-routes
+	switch head {
+	case "totp":
+		h.TOTPHandler.ServeHTTP(res, req)
+		return
+	case "keys":
+		h.APIKeyHandler.ServeHTTP(res, req)
+		return
+	case "oidc":
+		h.OIDCHandler.ServeHTTP(res, req)
+		return
+	case "webhooks":
+		h.WebhookHandler.ServeHTTP(res, req)
+		return
+	}
+
+	// URL not empty even after removing the action
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "POST" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	switch head {
+	case "email": // <base>/api/account/email
+		h.EmailHandler.ServeHTTP(res, req)
+	case "verify": // <base>/api/account/verify
+		h.VerifyHandler.ServeHTTP(res, req)
+	case "forgot": // <base>/api/account/forgot
+		h.ForgotHandler.ServeHTTP(res, req)
+	case "reset": // <base>/api/account/reset
+		h.ResetHandler.ServeHTTP(res, req)
+	default:
+		helpers.NotFoundResponse(res)
+	}
+
+	return
+}
+
+// EmailHandler changes the email address of the logged in author
+type EmailHandler struct {
+}
+
+// EmailHandler's ServeHTTP sets the logged in author's email and sends a verification link to it
+//
+// POST	<base>/api/account/email
+func (h *EmailHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	var body types.EmailRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	if err := models.SetAuthorEmail(authorId, body.Email); err != nil {
+		errorResponse(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Verification email sent!"})
+
+	return
+}
+
+// VerifyHandler verifies email addresses
+type VerifyHandler struct {
+}
+
+// VerifyHandler's ServeHTTP consumes the token sent in a verification email
+//
+// POST	<base>/api/account/verify
+func (h *VerifyHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	var body types.TokenRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	if !validate(res, body.Validate(false)) {
+		return
+	}
+
+	if _, err := models.VerifyEmail(body.Token); err != nil {
+		errorResponse(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Email verified!"})
+
+	return
+}
+
+// ForgotHandler starts the password reset flow
+type ForgotHandler struct {
+}
+
+// ForgotHandler's ServeHTTP emails a password reset link to the author identified by username or
+// email
+//
+// The response is the same whether or not the author exists.
+//
+// POST	<base>/api/account/forgot
+func (h *ForgotHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	var body types.ForgotRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	identifier := body.Email
+
+	if identifier == "" {
+		identifier = body.Username
+	}
+
+	if identifier == "" {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "username", Code: "required", Message: "Username or email is required"}})
+		return
+	}
+
+	if err := models.RequestPasswordReset(identifier); err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "If the account has a verified email, a reset link has been sent to it!"})
+
+	return
+}
+
+// ResetHandler finishes the password reset flow
+type ResetHandler struct {
+}
+
+// ResetHandler's ServeHTTP consumes the token sent in a reset email and sets the new password
+//
+// POST	<base>/api/account/reset
+func (h *ResetHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	var body types.TokenRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	if !validate(res, body.Validate(true)) {
+		return
+	}
+
+	if _, err := models.ResetPassword(body.Token, body.Password); err != nil {
+		errorResponse(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Password changed!"})
+
+	return
+}
+
+// TOTPHandler manages the logged in author's TOTP second factor
+type TOTPHandler struct {
+}
+
+// TOTPHandler's ServeHTTP handles URLs of type
+//
+// POST		<base>/api/account/totp			Enrol, returns the secret and its provisioning URI
+//
+// POST		<base>/api/account/totp/confirm	Enable with a code from the authenticator, returns recovery codes
+//
+// DELETE	<base>/api/account/totp			Disable with a code from the authenticator or a recovery code
+func (h *TOTPHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+
+	res.Header().Set("Content-Type", "application/json")
+
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	var body types.OTPRequest
+
+	// enrolling takes no body
+	if (head == "confirm" && req.Method == "POST") || (head == "" && req.Method == "DELETE") {
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+	}
+
+	switch {
+	case head == "" && req.Method == "POST":
+		secret, uri, err := models.EnrolTOTP(authorId)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: types.TOTPEnrolment{Secret: secret, URI: uri}})
+	case head == "confirm" && req.Method == "POST":
+		codes, err := models.ConfirmTOTP(authorId, body.OTP)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: codes})
+	case head == "" && req.Method == "DELETE":
+		err := models.DisableTOTP(authorId, body.OTP)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Two-factor authentication disabled!"})
+	case head == "" || head == "confirm":
+		helpers.MethodNotAllowedResponse(res)
+	default:
+		helpers.NotFoundResponse(res)
+	}
+
+	return
+}
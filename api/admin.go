@@ -0,0 +1,164 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/importer"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// default largest accepted import in bytes, overridden by GOBLOG_MAX_IMPORT_BYTES
+const defaultMaxImportSize = 100 << 20
+
+// Handler for <base>/api/v2/admin/... calls, only allowed to the authors listed in GOBLOG_ADMINS
+type AdminHandler struct {
+	Admins        []string // author IDs of the administrators
+	ImportHandler *ImportHandler
+}
+
+// NewAdminHandler returns the handler of the administrators listed in GOBLOG_ADMINS, a comma
+// separated list of author IDs. IDs rather than usernames, which anyone could register while
+// they're free
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{
+		Admins:        splitList(os.Getenv("GOBLOG_ADMINS")),
+		ImportHandler: new(ImportHandler),
+	}
+}
+
+// AdminHandler's ServeHTTP checks the logged in author is an administrator and routes to the
+// handler of the path
+func (h *AdminHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	if !contains(h.Admins, authorId) {
+		helpers.ForbiddenResponse(res, "Only administrators are allowed!")
+		return
+	}
+
+	var head string
+
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	switch head {
+	case "import": // <base>/api/v2/admin/import
+		h.ImportHandler.ServeHTTP(res, req)
+	case "export": // <base>/api/v2/admin/export
+		h.serveExport(res, req)
+	default: // all other
+		helpers.NotFoundResponse(res)
+	}
+
+	return
+}
+
+// AdminHandler's serveExport responds with the archive of the whole site's content
+//
+// GET	<base>/api/v2/admin/export
+func (h *AdminHandler) serveExport(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "GET" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	writeExport(res, req, "")
+}
+
+// Handler for <base>/api/v2/admin/import call
+type ImportHandler struct {
+}
+
+// ImportHandler's ServeHTTP imports the posts of an export uploaded as the multipart field
+// "file" and responds with the report of every post
+//
+// POST	<base>/api/v2/admin/import
+func (h *ImportHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "POST" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	maxSize := int64(defaultMaxImportSize)
+
+	if value, err := strconv.ParseInt(os.Getenv("GOBLOG_MAX_IMPORT_BYTES"), 10, 64); err == nil {
+		maxSize = value
+	}
+
+	// leaving room for the multipart boundaries and other fields
+	helpers.LimitBody(res, req, maxSize+1<<20)
+
+	var body types.ImportRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	switch body.Format {
+	case "", importer.FormatWXR, importer.FormatMarkdown, importer.FormatJSON:
+	default:
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "format", Code: "invalid", Message: "Format must be wxr, markdown or json"}})
+		return
+	}
+
+	authorMap, err := importer.ParseAuthorMap(body.AuthorMap)
+
+	if err != nil {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "map_authors", Code: "invalid", Message: "Authors must be mapped as \"source=username\""}})
+		return
+	}
+
+	file, header, err := req.FormFile("file")
+
+	if err != nil {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "file", Code: "required", Message: "Multipart field \"file\" is required"}})
+		return
+	}
+
+	defer file.Close()
+
+	if header.Size > maxSize {
+		helpers.RequestEntityTooLargeResponse(res, "File too large!")
+		return
+	}
+
+	source, err := importer.Read(file, header.Size, header.Filename, body.Format)
+
+	if err != nil {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "file", Code: "invalid", Message: "File can't be read: " + err.Error()}})
+		return
+	}
+
+	report, err := importer.Run(source, importer.Options{
+		Source:        body.Source,
+		DryRun:        body.DryRun,
+		CreateAuthors: body.CreateAuthors,
+		DefaultAuthor: body.DefaultAuthor,
+		AuthorMap:     authorMap,
+	})
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, report)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// authenticateAPIKey authenticates a request sending an API key in its X-API-Key header and checks
+// the key's scopes allow it, if not a problem has been sent and false is returned
+//
+// path is the path below <base>/api/:version. Requests with a session token are left to it.
+func authenticateAPIKey(res http.ResponseWriter, req *http.Request, path string) (*http.Request, bool) {
+	key := req.Header.Get("X-API-Key")
+
+	if key == "" || req.Header.Get("token") != "" {
+		return req, true
+	}
+
+	keyId, authorId, scopes, err := models.AuthenticateAPIKey(key)
+
+	if err == sql.ErrNoRows {
+		helpers.ProblemResponse(res, helpers.NewProblem(http.StatusUnauthorized, helpers.CodeInvalidAPIKey, "Invalid or revoked API key!"))
+		return req, false
+	} else if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return req, false
+	}
+
+	if scope := requiredScope(req.Method, path); !helpers.ScopeAllows(scopes, scope) {
+		helpers.ProblemResponse(res, helpers.NewProblem(http.StatusForbidden, helpers.CodeInsufficientScope, "The API key lacks the "+scope+" scope!"))
+		return req, false
+	}
+
+	return helpers.WithAPIKey(req, keyId, authorId, scopes), true
+}
+
+// requiredScope returns the scope an API key needs for a request
+func requiredScope(method, path string) string {
+	switch {
+	case path == "/graphql":
+		// mutations check for write:posts themselves
+		return types.ScopeRead
+	case strings.HasPrefix(path, "/account/") || strings.HasPrefix(path, "/admin/"):
+		return types.ScopeAdmin
+	case method == "GET" || method == "HEAD":
+		return types.ScopeRead
+	default:
+		return types.ScopeWritePosts
+	}
+}
+
+// APIKeyHandler manages the logged in author's API keys
+type APIKeyHandler struct {
+}
+
+// APIKeyHandler's ServeHTTP handles URLs of type
+//
+// GET		<base>/api/account/keys			List the keys that haven't been revoked
+//
+// POST		<base>/api/account/keys			Create a key, the response is the only time it is shown
+//
+// DELETE	<base>/api/account/keys/:keyId	Revoke a key
+func (h *APIKeyHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var keyId string
+
+	res.Header().Set("Content-Type", "application/json")
+
+	keyId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	switch {
+	case keyId == "" && req.Method == "GET":
+		keys, err := models.GetAPIKeys(authorId)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: keys})
+	case keyId == "" && req.Method == "POST":
+		var body types.CreateAPIKeyRequest
+
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
+
+		key, err := models.CreateAPIKey(authorId, strings.TrimSpace(body.Name), body.Scopes)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: key})
+	case keyId != "" && req.Method == "DELETE":
+		if !helpers.IsValidId(keyId) {
+			helpers.NotFoundResponse(res)
+			return
+		}
+
+		if err := models.RevokeAPIKey(authorId, keyId); err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "API key revoked!"})
+	default:
+		helpers.MethodNotAllowedResponse(res)
+	}
+
+	return
+}
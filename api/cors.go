@@ -0,0 +1,169 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CORS lets browser clients on other origins call the API
+type CORS struct {
+	AllowedOrigins   []string // origins allowed to call the API, "*" for any
+	AllowedMethods   []string // methods allowed in preflights
+	AllowedHeaders   []string // request headers allowed in preflights
+	ExposedHeaders   []string // response headers readable by clients
+	AllowCredentials bool     // whether cookies and authorization headers may be sent
+	MaxAge           int      // seconds preflight results may be cached, 0 to not send
+}
+
+// NewCORS returns the CORS configuration read from GOBLOG_CORS_ORIGINS, GOBLOG_CORS_METHODS,
+// GOBLOG_CORS_HEADERS, GOBLOG_CORS_CREDENTIALS and GOBLOG_CORS_MAX_AGE
+//
+// No origin is allowed unless GOBLOG_CORS_ORIGINS is set.
+func NewCORS() *CORS {
+	cors := &CORS{
+		AllowedOrigins: splitList(os.Getenv("GOBLOG_CORS_ORIGINS")),
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Token", "X-API-Key", "If-Match", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders: []string{"ETag", "Last-Modified", "Location", "Deprecation", "Sunset", "Link", "Retry-After",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+		MaxAge: 600,
+	}
+
+	if methods := splitList(os.Getenv("GOBLOG_CORS_METHODS")); methods != nil {
+		cors.AllowedMethods = methods
+	}
+
+	if headers := splitList(os.Getenv("GOBLOG_CORS_HEADERS")); headers != nil {
+		cors.AllowedHeaders = headers
+	}
+
+	if value, err := strconv.ParseBool(os.Getenv("GOBLOG_CORS_CREDENTIALS")); err == nil {
+		cors.AllowCredentials = value
+	}
+
+	if value, err := strconv.Atoi(os.Getenv("GOBLOG_CORS_MAX_AGE")); err == nil {
+		cors.MaxAge = value
+	}
+
+	return cors
+}
+
+// CORS's Handle sets the CORS headers of a response and answers preflight requests, true is
+// returned if req was a preflight and has been answered
+func (c *CORS) Handle(res http.ResponseWriter, req *http.Request) bool {
+	header := res.Header()
+	origin := req.Header.Get("Origin")
+	preflight := req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != ""
+
+	// responses differ by origin, shared caches must keep them apart
+	header.Add("Vary", "Origin")
+
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowed := origin != "" && c.originAllowed(origin)
+
+	if allowed {
+		if c.AllowCredentials || !contains(c.AllowedOrigins, "*") {
+			header.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if c.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if allowed && len(c.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+
+		return false
+	}
+
+	// failed preflights are answered without allow headers, the browser blocks the request then
+	if allowed && c.methodAllowed(req.Header.Get("Access-Control-Request-Method")) && c.headersAllowed(req.Header.Get("Access-Control-Request-Headers")) {
+		header.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+
+		if requested := req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+
+		if c.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+		}
+	} else {
+		header.Del("Access-Control-Allow-Origin")
+		header.Del("Access-Control-Allow-Credentials")
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// originAllowed reports if origin may call the API
+func (c *CORS) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodAllowed reports if a preflight's requested method is allowed, simple methods always are
+func (c *CORS) methodAllowed(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "POST" || contains(c.AllowedMethods, method)
+}
+
+// headersAllowed reports if all of a preflight's comma separated requested headers are allowed
+func (c *CORS) headersAllowed(requested string) bool {
+	for _, name := range splitList(requested) {
+		found := false
+
+		for _, allowed := range c.AllowedHeaders {
+			if allowed == "*" || strings.EqualFold(allowed, name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitList returns the non-empty items of a comma separated list, nil if there are none
+func splitList(list string) []string {
+	var result []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// contains reports if value is one of values
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
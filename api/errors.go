@@ -0,0 +1,101 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// problemError is an error reported to clients as the problem it holds
+type problemError struct {
+	types.Problem
+}
+
+// problemError's Error returns the problem's detail
+func (e *problemError) Error() string {
+	return e.Detail
+}
+
+// errorProblem returns the problem matching an error returned by models, false for unknown errors
+func errorProblem(err error) (types.Problem, bool) {
+	if problem, ok := err.(*problemError); ok {
+		return problem.Problem, true
+	}
+
+	switch err {
+	case models.ErrInvalidEmail:
+		return helpers.NewValidationProblem([]types.FieldError{{Field: "email", Code: "invalid", Message: err.Error()}}), true
+	case models.ErrInvalidCode:
+		return helpers.NewValidationProblem([]types.FieldError{{Field: "otp", Code: "invalid", Message: err.Error()}}), true
+	case models.ErrUsernameTaken:
+		return helpers.NewProblem(http.StatusConflict, helpers.CodeUsernameTaken, err.Error()), true
+	case models.ErrEmailTaken:
+		return helpers.NewProblem(http.StatusConflict, helpers.CodeEmailTaken, err.Error()), true
+	case models.ErrTOTPEnabled:
+		return helpers.NewProblem(http.StatusConflict, helpers.CodeTOTPEnabled, err.Error()), true
+	case models.ErrTOTPNotEnrolled:
+		return helpers.NewProblem(http.StatusConflict, helpers.CodeTOTPNotEnrolled, err.Error()), true
+	case models.ErrSecondFactorLocked:
+		return helpers.NewProblem(http.StatusTooManyRequests, helpers.CodeTOTPLocked, err.Error()), true
+	case models.ErrIdentityNotLinked:
+		return helpers.NewProblem(http.StatusForbidden, helpers.CodeIdentityNotLinked, err.Error()), true
+	case models.ErrIdentityLinked:
+		return helpers.NewProblem(http.StatusConflict, helpers.CodeIdentityLinked, err.Error()), true
+	case models.ErrPostModified:
+		return helpers.NewProblem(http.StatusPreconditionFailed, helpers.CodePreconditionFailed, "The resource has been modified since it was read!"), true
+	case models.ErrInvalidToken:
+		return helpers.NewProblem(http.StatusBadRequest, helpers.CodeInvalidToken, err.Error()), true
+	case sql.ErrNoRows:
+		return helpers.NewProblem(http.StatusNotFound, helpers.CodeNotFound, "URL not found!"), true
+	default:
+		return types.Problem{}, false
+	}
+}
+
+// errorResponse returns the problem matching an error returned by models, unknown errors are
+// logged and reported as internal errors
+func errorResponse(res http.ResponseWriter, err error) {
+	if problem, ok := errorProblem(err); ok {
+		helpers.ProblemResponse(res, problem)
+	} else {
+		helpers.InternalServerErrorResponse(res, err)
+	}
+
+	return
+}
+
+// postWriteError returns the error of a failed update or delete of an author's post, telling apart
+// posts that don't exist from posts of other authors
+func postWriteError(postId string, err error) error {
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := models.GetPostAuthorId(postId); err == sql.ErrNoRows {
+		return &problemError{helpers.NewProblem(http.StatusNotFound, helpers.CodePostNotFound, "Post does not exist!")}
+	} else if err != nil {
+		return err
+	}
+
+	return &problemError{helpers.NewProblem(http.StatusForbidden, helpers.CodeForbidden, "You don't have write access to the post!")}
+}
+
+// postWriteErrorResponse returns the problem for a failed update or delete of an author's post
+func postWriteErrorResponse(res http.ResponseWriter, postId string, err error) {
+	errorResponse(res, postWriteError(postId, err))
+
+	return
+}
+
+// validate returns a 422 problem and false if the request body has invalid fields
+func validate(res http.ResponseWriter, errors []types.FieldError) bool {
+	if len(errors) > 0 {
+		helpers.ValidationErrorResponse(res, errors)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/helpers"
+)
+
+// page listing the operations of <base>/api/openapi.json with a form to try each of them
+//
+//go:embed explorer.html
+var explorerPage []byte
+
+// Handler for <base>/api/docs/ call
+type ExplorerHandler struct {
+}
+
+// ExplorerHandler's ServeHTTP returns the API explorer
+//
+// GET	<base>/api/docs/
+func (h *ExplorerHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "GET" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	res.WriteHeader(http.StatusOK)
+	res.Write(explorerPage)
+
+	return
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/samkit-jain/go-blog/exporter"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// content types of export archives by format
+var exportTypes = map[string]string{
+	exporter.FormatZip: "application/zip",
+	exporter.FormatTar: "application/gzip",
+}
+
+// ExportHandler handles the exports of authors
+type ExportHandler struct {
+}
+
+// ExportHandler's Handler returns the handler of the export of the author authorId, only allowed
+// to the author
+//
+// GET	<base>/api/:version/authors/:authorId/export
+func (h *ExportHandler) Handler(authorId string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			helpers.MethodNotAllowedResponse(res)
+			return
+		}
+
+		loggedIn := helpers.GetAuthorIdFromHeader(req)
+
+		if loggedIn == "" {
+			helpers.UnauthorizedResponse(res)
+			return
+		}
+
+		if loggedIn != authorId {
+			helpers.ForbiddenResponse(res, "You can only export your own content!")
+			return
+		}
+
+		writeExport(res, req, authorId)
+	})
+}
+
+// writeExport responds with the archive of the content of the author authorId, of the whole site
+// if authorId is empty, in the format asked for with ?format=zip (default) or tar
+//
+// The archive is tagged with a hash of its manifest, which names every file by its content.
+func writeExport(res http.ResponseWriter, req *http.Request, authorId string) {
+	format := req.URL.Query().Get("format")
+
+	if format == "" {
+		format = exporter.FormatZip
+	}
+
+	contentType, ok := exportTypes[format]
+
+	if !ok {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "format", Code: "invalid", Message: "Format must be zip or tar"}})
+		return
+	}
+
+	export, err := exporter.Collect(authorId)
+
+	if err == sql.ErrNoRows {
+		helpers.ResourceNotFoundResponse(res, helpers.CodeAuthorNotFound, "Author does not exist!")
+		return
+	}
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	manifest, err := json.Marshal(export)
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	name := "site"
+
+	if authorId != "" {
+		name = export.Authors[0].Username
+	}
+
+	sum := sha256.Sum256(append(manifest, format...))
+	header := res.Header()
+
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exporter.Filename(name, format, time.Now())}))
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	res.WriteHeader(http.StatusOK)
+
+	// too late for a problem response, the connection is cut so the archive isn't taken as complete
+	if err = exporter.Write(res, export, format); err != nil {
+		log.Printf("exporting %q: %v", name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
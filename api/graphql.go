@@ -0,0 +1,947 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// schema served at <base>/api/graphql
+const graphQLSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+"RFC 3339 date and time"
+scalar Time
+
+type Query {
+	"An author, null if there is none with the ID"
+	author(id: ID!): Author
+	"Authors ordered by username, first defaults to 20 and may be up to 100"
+	authors(first: Int, after: String): AuthorConnection!
+	"A post, null if there is none with the ID"
+	post(id: ID!): Post
+	"Posts, newest first, optionally only the ones having a tag; first defaults to 20 and may be up to 100"
+	posts(first: Int, after: String, tag: String): PostConnection!
+	"The logged in author, null if not logged in"
+	viewer: Author
+}
+
+type Mutation {
+	"Create an author"
+	createAuthor(input: CreateAuthorInput!): Author!
+	"Create a post of the logged in author"
+	createPost(input: PostInput!): Post!
+	"Update a post of the logged in author, only if its revision is still revision when given; tags are left unchanged if omitted"
+	updatePost(id: ID!, input: PostInput!, revision: Int): Post!
+	"Delete a post of the logged in author, only if its revision is still revision when given"
+	deletePost(id: ID!, revision: Int): ID!
+	"Delete all posts of the logged in author"
+	deletePosts: Boolean!
+}
+
+input CreateAuthorInput {
+	username: String!
+	password: String!
+	email: String
+}
+
+input PostInput {
+	title: String!
+	body: String!
+	tags: [String!]
+}
+
+type Author {
+	id: ID!
+	username: String!
+	createdAt: Time!
+	"The author's posts, newest first; first defaults to 20 and may be up to 100"
+	posts(first: Int, after: String): PostConnection!
+}
+
+type Post {
+	id: ID!
+	title: String!
+	body: String!
+	tags: [String!]!
+	createdAt: Time!
+	updatedAt: Time!
+	"Incremented on every update"
+	revision: Int!
+	author: Author!
+	attachments: [Attachment!]!
+}
+
+type Attachment {
+	id: ID!
+	filename: String!
+	contentType: String!
+	size: Int!
+	url: String!
+	thumbnailUrl: String
+	createdAt: Time!
+}
+
+type AuthorConnection {
+	edges: [AuthorEdge!]!
+	pageInfo: PageInfo!
+	totalCount: Int!
+}
+
+type AuthorEdge {
+	cursor: String!
+	node: Author!
+}
+
+type PostConnection {
+	edges: [PostEdge!]!
+	pageInfo: PageInfo!
+	totalCount: Int!
+}
+
+type PostEdge {
+	cursor: String!
+	node: Post!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	"Cursor of the last edge, to be sent as after for the next page"
+	endCursor: String
+}
+`
+
+// sizes of pages of authors and posts
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Handler for <base>/api/graphql calls
+type GraphQLHandler struct {
+	Schema        *graphql.Schema
+	MaxComplexity int // largest estimated cost of a query, see queryComplexity
+}
+
+// NewGraphQLHandler returns the handler limiting queries to the depth of GOBLOG_GRAPHQL_MAX_DEPTH
+// (default 15) and the estimated cost of GOBLOG_GRAPHQL_MAX_COMPLEXITY (default 2000)
+func NewGraphQLHandler() *GraphQLHandler {
+	maxDepth, maxComplexity := 15, 2000
+
+	if value, err := strconv.Atoi(os.Getenv("GOBLOG_GRAPHQL_MAX_DEPTH")); err == nil && value > 0 {
+		maxDepth = value
+	}
+
+	if value, err := strconv.Atoi(os.Getenv("GOBLOG_GRAPHQL_MAX_COMPLEXITY")); err == nil && value > 0 {
+		maxComplexity = value
+	}
+
+	return &GraphQLHandler{
+		Schema:        graphql.MustParseSchema(graphQLSchema, new(graphQLResolver), graphql.UseStringDescriptions(), graphql.MaxDepth(maxDepth)),
+		MaxComplexity: maxComplexity,
+	}
+}
+
+// GraphQLHandler's ServeHTTP handles URLs of type
+//
+// POST	<base>/api/graphql	Execute a query or mutation
+//
+// Queries that can be executed are answered with 200 and any errors of their fields listed in
+// "errors", as GraphQL clients expect. Authors are authenticated like in the REST API.
+func (h *GraphQLHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "POST" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	var body types.GraphQLRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return
+	}
+
+	if strings.TrimSpace(body.Query) == "" {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "query", Code: "required", Message: "Query is required"}})
+		return
+	}
+
+	// parsed and validated (including the depth) before estimating the cost
+	if errors := h.Schema.ValidateWithVariables(body.Query, body.Variables); len(errors) > 0 {
+		writeJSON(res, http.StatusOK, &graphql.Response{Errors: errors})
+		return
+	}
+
+	if complexity := queryComplexity(body.Query, body.OperationName, body.Variables); complexity > h.MaxComplexity {
+		writeJSON(res, http.StatusOK, &graphql.Response{Errors: []*gqlerrors.QueryError{{
+			Message:    "Query has complexity " + strconv.Itoa(complexity) + " that exceeds max complexity " + strconv.Itoa(h.MaxComplexity),
+			Extensions: map[string]interface{}{"code": "query_too_complex", "complexity": complexity, "max_complexity": h.MaxComplexity},
+		}}})
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), graphQLRequestKey{}, req)
+
+	writeJSON(res, http.StatusOK, h.Schema.Exec(ctx, body.Query, body.OperationName, body.Variables))
+
+	return
+}
+
+// key of the HTTP request in the context of resolvers
+type graphQLRequestKey struct{}
+
+// graphQLScope returns an error if the request of ctx was authenticated with an API key lacking scope
+func graphQLScope(ctx context.Context, scope string) error {
+	if !helpers.APIKeyAllows(ctx.Value(graphQLRequestKey{}).(*http.Request), scope) {
+		return &problemError{helpers.NewProblem(http.StatusForbidden, helpers.CodeInsufficientScope, "The API key lacks the "+scope+" scope!")}
+	}
+
+	return nil
+}
+
+// graphQLAuthor returns the author the request of ctx is authenticated as, API keys need scope
+func graphQLAuthor(ctx context.Context, scope string) (string, error) {
+	authorId := helpers.GetAuthorIdFromHeader(ctx.Value(graphQLRequestKey{}).(*http.Request))
+
+	if authorId == "" {
+		return "", &problemError{helpers.NewProblem(http.StatusUnauthorized, helpers.CodeUnauthorized, "Not logged in!")}
+	}
+
+	if err := graphQLScope(ctx, scope); err != nil {
+		return "", err
+	}
+
+	return authorId, nil
+}
+
+// graphQLError returns err as reported to GraphQL clients, unknown errors are logged and reported
+// as internal errors
+func graphQLError(err error) error {
+	problem, ok := errorProblem(err)
+
+	if !ok {
+		log.Printf("internal error: %v", err)
+
+		problem = helpers.NewProblem(http.StatusInternalServerError, helpers.CodeInternalError, "An unexpected error occurred!")
+	}
+
+	return &problemError{problem}
+}
+
+// problemError's Extensions returns the code, status and field errors of the problem, sent to
+// GraphQL clients with the error's message
+func (e *problemError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code, "status": e.Status}
+
+	if len(e.Errors) > 0 {
+		extensions["errors"] = e.Errors
+	}
+
+	return extensions
+}
+
+// pageLimit returns the number of items a page may have, first if it's given
+func pageLimit(first *int32) (int, error) {
+	if first == nil {
+		return defaultPageSize, nil
+	}
+
+	if *first < 0 || *first > maxPageSize {
+		return 0, &problemError{helpers.NewValidationProblem([]types.FieldError{{Field: "first", Code: "out_of_range", Message: "First must be between 0 and " + strconv.Itoa(maxPageSize)}})}
+	}
+
+	return int(*first), nil
+}
+
+// cursors are opaque to clients, they are base64url encoded "<kind>:<value>" strings
+
+// encodeCursor returns the cursor of kind of an item
+func encodeCursor(kind, value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + ":" + value))
+}
+
+// decodeCursor returns the value of a cursor of kind, empty if cursor is nil
+func decodeCursor(cursor *string, kind string) (string, error) {
+	if cursor == nil {
+		return "", nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(*cursor)
+	value, ok := strings.CutPrefix(string(decoded), kind+":")
+
+	if err != nil || !ok {
+		return "", &problemError{helpers.NewProblem(http.StatusBadRequest, helpers.CodeBadRequest, "Invalid cursor!")}
+	}
+
+	return value, nil
+}
+
+// postCursor returns the cursor of a post
+func postCursor(post types.Post) string {
+	return encodeCursor("post", post.CreatedAt.UTC().Format(time.RFC3339Nano)+" "+post.Id)
+}
+
+// decodePostCursor returns the position of a post cursor, the start if cursor is nil
+func decodePostCursor(cursor *string) (types.PostCursor, error) {
+	value, err := decodeCursor(cursor, "post")
+
+	if err != nil || value == "" {
+		return types.PostCursor{}, err
+	}
+
+	createdAt, id, _ := strings.Cut(value, " ")
+	position := types.PostCursor{Id: id}
+
+	if position.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil || !helpers.IsValidId(id) {
+		return types.PostCursor{}, &problemError{helpers.NewProblem(http.StatusBadRequest, helpers.CodeBadRequest, "Invalid cursor!")}
+	}
+
+	return position, nil
+}
+
+// resolver of the Query and Mutation types
+type graphQLResolver struct {
+}
+
+func (r *graphQLResolver) Author(args struct{ ID graphql.ID }) (*authorResolver, error) {
+	if !helpers.IsValidId(string(args.ID)) {
+		return nil, nil
+	}
+
+	content, err := models.GetAuthorById(string(args.ID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	return newAuthorResolvers([]types.Author{content.AuthorInfo})[0], nil
+}
+
+func (r *graphQLResolver) Authors(args struct {
+	First *int32
+	After *string
+}) (*authorConnectionResolver, error) {
+	limit, err := pageLimit(args.First)
+
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := decodeCursor(args.After, "author")
+
+	if err != nil {
+		return nil, err
+	}
+
+	// one more than asked for tells if there is a next page
+	authors, total, err := models.GetAuthorsPage(after, limit+1)
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	connection := &authorConnectionResolver{total: total}
+
+	if len(authors) > limit {
+		authors, connection.hasNextPage = authors[:limit], true
+	}
+
+	connection.nodes = newAuthorResolvers(authors)
+
+	return connection, nil
+}
+
+func (r *graphQLResolver) Post(args struct{ ID graphql.ID }) (*postResolver, error) {
+	if !helpers.IsValidId(string(args.ID)) {
+		return nil, nil
+	}
+
+	post, err := models.GetPostById(string(args.ID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	return newPostResolvers([]types.Post{post})[0], nil
+}
+
+func (r *graphQLResolver) Posts(args struct {
+	First *int32
+	After *string
+	Tag   *string
+}) (*postConnectionResolver, error) {
+	limit, err := pageLimit(args.First)
+
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := decodePostCursor(args.After)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tag := ""
+
+	if args.Tag != nil {
+		tag = *args.Tag
+	}
+
+	posts, total, err := models.GetPostsPage(tag, after, limit+1)
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	return newPostConnection(posts, limit, total), nil
+}
+
+func (r *graphQLResolver) Viewer(ctx context.Context) (*authorResolver, error) {
+	authorId := helpers.GetAuthorIdFromHeader(ctx.Value(graphQLRequestKey{}).(*http.Request))
+
+	if authorId == "" {
+		return nil, nil
+	}
+
+	return r.Author(struct{ ID graphql.ID }{graphql.ID(authorId)})
+}
+
+func (r *graphQLResolver) CreateAuthor(ctx context.Context, args struct {
+	Input struct {
+		Username string
+		Password string
+		Email    *string
+	}
+}) (*authorResolver, error) {
+	if err := graphQLScope(ctx, types.ScopeWritePosts); err != nil {
+		return nil, err
+	}
+
+	request := types.CreateAuthorRequest{Username: args.Input.Username, Password: args.Input.Password}
+
+	if args.Input.Email != nil {
+		request.Email = *args.Input.Email
+	}
+
+	if errors := request.Validate(); len(errors) > 0 {
+		return nil, &problemError{helpers.NewValidationProblem(errors)}
+	}
+
+	authorId, err := models.CreateAuthor(request.Username, request.Password, request.Email)
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	return r.Author(struct{ ID graphql.ID }{graphql.ID(authorId)})
+}
+
+// input of post mutations
+type postInput struct {
+	Title string
+	Body  string
+	Tags  *[]string
+}
+
+// postInput's request returns the input as the body of the REST call
+func (i postInput) request() types.PostRequest {
+	request := types.PostRequest{Title: i.Title, Body: i.Body}
+
+	if i.Tags != nil {
+		request.Tags = *i.Tags
+	}
+
+	return request
+}
+
+func (r *graphQLResolver) CreatePost(ctx context.Context, args struct{ Input postInput }) (*postResolver, error) {
+	authorId, err := graphQLAuthor(ctx, types.ScopeWritePosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := args.Input.request()
+
+	if errors := request.Validate(); len(errors) > 0 {
+		return nil, &problemError{helpers.NewValidationProblem(errors)}
+	}
+
+	postId, err := models.CreatePost(request.Title, request.Body, authorId, request.Tags)
+
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	return r.Post(struct{ ID graphql.ID }{graphql.ID(postId)})
+}
+
+func (r *graphQLResolver) UpdatePost(ctx context.Context, args struct {
+	ID       graphql.ID
+	Input    postInput
+	Revision *int32
+}) (*postResolver, error) {
+	authorId, err := graphQLAuthor(ctx, types.ScopeWritePosts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !helpers.IsValidId(string(args.ID)) {
+		return nil, &problemError{helpers.NewProblem(http.StatusNotFound, helpers.CodePostNotFound, "Post does not exist!")}
+	}
+
+	request := args.Input.request()
+
+	if errors := request.Validate(); len(errors) > 0 {
+		return nil, &problemError{helpers.NewValidationProblem(errors)}
+	}
+
+	// an empty list clears the tags, nil would leave them unchanged
+	if args.Input.Tags != nil && request.Tags == nil {
+		request.Tags = []string{}
+	}
+
+	if _, err := models.UpdatePost(string(args.ID), request.Title, request.Body, authorId, request.Tags, revisions(args.Revision)); err != nil {
+		return nil, graphQLError(postWriteError(string(args.ID), err))
+	}
+
+	return r.Post(struct{ ID graphql.ID }{args.ID})
+}
+
+func (r *graphQLResolver) DeletePost(ctx context.Context, args struct {
+	ID       graphql.ID
+	Revision *int32
+}) (graphql.ID, error) {
+	authorId, err := graphQLAuthor(ctx, types.ScopeWritePosts)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !helpers.IsValidId(string(args.ID)) {
+		return "", &problemError{helpers.NewProblem(http.StatusNotFound, helpers.CodePostNotFound, "Post does not exist!")}
+	}
+
+	if err := models.DeletePost(string(args.ID), authorId, revisions(args.Revision)); err != nil {
+		return "", graphQLError(postWriteError(string(args.ID), err))
+	}
+
+	return args.ID, nil
+}
+
+func (r *graphQLResolver) DeletePosts(ctx context.Context) (bool, error) {
+	authorId, err := graphQLAuthor(ctx, types.ScopeWritePosts)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := models.DeletePosts(authorId); err != nil {
+		return false, graphQLError(err)
+	}
+
+	return true, nil
+}
+
+// revisions returns the revisions a post may have to be written, nil for any
+func revisions(revision *int32) []int {
+	if revision == nil {
+		return nil
+	}
+
+	return []int{int(*revision)}
+}
+
+// resolver of the Author type
+type authorResolver struct {
+	author types.Author
+	posts  *authorPostsLoader // shared with the authors resolved along with this one
+}
+
+// newAuthorResolvers returns the resolvers of authors listed together, their posts are loaded at once
+func newAuthorResolvers(authors []types.Author) []*authorResolver {
+	loader := &authorPostsLoader{pages: make(map[string]*authorPostsPage)}
+	result := make([]*authorResolver, len(authors))
+
+	for index, author := range authors {
+		loader.authorIds = append(loader.authorIds, author.AuthorId)
+		result[index] = &authorResolver{author: author, posts: loader}
+	}
+
+	return result
+}
+
+func (r *authorResolver) ID() graphql.ID {
+	return graphql.ID(r.author.AuthorId)
+}
+
+func (r *authorResolver) Username() string {
+	return r.author.Username
+}
+
+func (r *authorResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.author.CreatedAt}
+}
+
+func (r *authorResolver) Posts(args struct {
+	First *int32
+	After *string
+}) (*postConnectionResolver, error) {
+	limit, err := pageLimit(args.First)
+
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := decodePostCursor(args.After)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := r.posts.load(limit+1, after)
+
+	if page.err != nil {
+		return nil, graphQLError(page.err)
+	}
+
+	return newPostConnection(page.posts[r.author.AuthorId], limit, page.totals[r.author.AuthorId]), nil
+}
+
+// authorPostsLoader reads the posts of authors listed together with one query per page asked for,
+// instead of one query per author
+type authorPostsLoader struct {
+	authorIds []string
+	mutex     sync.Mutex
+	pages     map[string]*authorPostsPage // by size and cursor
+}
+
+// a page of the posts of each author of an authorPostsLoader
+type authorPostsPage struct {
+	once   sync.Once
+	posts  map[string][]types.Post
+	totals map[string]int
+	err    error
+}
+
+// authorPostsLoader's load returns the page of up to limit posts of every author following after,
+// read by the first author asking for it
+func (l *authorPostsLoader) load(limit int, after types.PostCursor) *authorPostsPage {
+	key := strconv.Itoa(limit) + " " + after.CreatedAt.Format(time.RFC3339Nano) + " " + after.Id
+
+	l.mutex.Lock()
+
+	page, ok := l.pages[key]
+
+	if !ok {
+		page = new(authorPostsPage)
+		l.pages[key] = page
+	}
+
+	l.mutex.Unlock()
+
+	page.once.Do(func() {
+		page.posts, page.totals, page.err = models.GetAuthorsPosts(l.authorIds, after, limit)
+	})
+
+	return page
+}
+
+// resolver of the Post type
+type postResolver struct {
+	post        types.Post
+	author      *authorResolver
+	attachments *attachmentsLoader // shared with the posts resolved along with this one
+}
+
+// newPostResolvers returns the resolvers of posts listed together, the posts of their authors and
+// their attachments are loaded at once
+//
+// The authors come with the posts, resolving them doesn't query.
+func newPostResolvers(posts []types.Post) []*postResolver {
+	var (
+		authors   []types.Author
+		positions = make(map[string]int)
+		loader    = new(attachmentsLoader)
+	)
+
+	for _, post := range posts {
+		if _, ok := positions[post.AuthorInfo.AuthorId]; !ok {
+			positions[post.AuthorInfo.AuthorId] = len(authors)
+			authors = append(authors, post.AuthorInfo)
+		}
+
+		loader.postIds = append(loader.postIds, post.Id)
+	}
+
+	authorResolvers := newAuthorResolvers(authors)
+	result := make([]*postResolver, len(posts))
+
+	for index, post := range posts {
+		result[index] = &postResolver{post: post, author: authorResolvers[positions[post.AuthorInfo.AuthorId]], attachments: loader}
+	}
+
+	return result
+}
+
+func (r *postResolver) ID() graphql.ID {
+	return graphql.ID(r.post.Id)
+}
+
+func (r *postResolver) Title() string {
+	return r.post.Title
+}
+
+func (r *postResolver) Body() string {
+	return r.post.Body
+}
+
+func (r *postResolver) Tags() []string {
+	if r.post.Tags == nil {
+		return []string{}
+	}
+
+	return r.post.Tags
+}
+
+func (r *postResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.post.CreatedAt}
+}
+
+func (r *postResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.post.UpdatedAt}
+}
+
+func (r *postResolver) Revision() int32 {
+	return int32(r.post.Revision)
+}
+
+func (r *postResolver) Author() *authorResolver {
+	return r.author
+}
+
+func (r *postResolver) Attachments() ([]*attachmentResolver, error) {
+	attachments := r.post.Attachments
+
+	// posts read one by one come with their attachments
+	if attachments == nil {
+		loaded, err := r.attachments.load()
+
+		if err != nil {
+			return nil, graphQLError(err)
+		}
+
+		attachments = loaded[r.post.Id]
+	}
+
+	result := make([]*attachmentResolver, len(attachments))
+
+	for index, attachment := range attachments {
+		result[index] = &attachmentResolver{attachment}
+	}
+
+	return result, nil
+}
+
+// attachmentsLoader reads the attachments of posts listed together with one query
+type attachmentsLoader struct {
+	postIds     []string
+	once        sync.Once
+	attachments map[string][]types.Attachment
+	err         error
+}
+
+// attachmentsLoader's load returns the attachments of every post, read by the first post asking
+func (l *attachmentsLoader) load() (map[string][]types.Attachment, error) {
+	l.once.Do(func() {
+		l.attachments, l.err = models.GetPostsAttachments(l.postIds)
+	})
+
+	return l.attachments, l.err
+}
+
+// resolver of the Attachment type
+type attachmentResolver struct {
+	attachment types.Attachment
+}
+
+func (r *attachmentResolver) ID() graphql.ID {
+	return graphql.ID(r.attachment.Id)
+}
+
+func (r *attachmentResolver) Filename() string {
+	return r.attachment.Filename
+}
+
+func (r *attachmentResolver) ContentType() string {
+	return r.attachment.ContentType
+}
+
+func (r *attachmentResolver) Size() int32 {
+	return int32(r.attachment.Size)
+}
+
+func (r *attachmentResolver) URL() string {
+	return r.attachment.URL
+}
+
+func (r *attachmentResolver) ThumbnailURL() *string {
+	if r.attachment.ThumbnailURL == "" {
+		return nil
+	}
+
+	return &r.attachment.ThumbnailURL
+}
+
+func (r *attachmentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.attachment.CreatedAt}
+}
+
+// resolver of the AuthorConnection type
+type authorConnectionResolver struct {
+	nodes       []*authorResolver
+	hasNextPage bool
+	total       int
+}
+
+func (r *authorConnectionResolver) Edges() []*authorEdgeResolver {
+	result := make([]*authorEdgeResolver, len(r.nodes))
+
+	for index, node := range r.nodes {
+		result[index] = &authorEdgeResolver{node}
+	}
+
+	return result
+}
+
+func (r *authorConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: r.hasNextPage}
+
+	if len(r.nodes) > 0 {
+		cursor := (&authorEdgeResolver{r.nodes[len(r.nodes)-1]}).Cursor()
+		info.endCursor = &cursor
+	}
+
+	return info
+}
+
+func (r *authorConnectionResolver) TotalCount() int32 {
+	return int32(r.total)
+}
+
+// resolver of the AuthorEdge type
+type authorEdgeResolver struct {
+	node *authorResolver
+}
+
+func (r *authorEdgeResolver) Cursor() string {
+	return encodeCursor("author", r.node.author.Username)
+}
+
+func (r *authorEdgeResolver) Node() *authorResolver {
+	return r.node
+}
+
+// resolver of the PostConnection type
+type postConnectionResolver struct {
+	nodes       []*postResolver
+	hasNextPage bool
+	total       int
+}
+
+// newPostConnection returns the page of the first limit of posts, more posts mean there's a next page
+func newPostConnection(posts []types.Post, limit, total int) *postConnectionResolver {
+	connection := &postConnectionResolver{total: total}
+
+	if len(posts) > limit {
+		posts, connection.hasNextPage = posts[:limit], true
+	}
+
+	connection.nodes = newPostResolvers(posts)
+
+	return connection
+}
+
+func (r *postConnectionResolver) Edges() []*postEdgeResolver {
+	result := make([]*postEdgeResolver, len(r.nodes))
+
+	for index, node := range r.nodes {
+		result[index] = &postEdgeResolver{node}
+	}
+
+	return result
+}
+
+func (r *postConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: r.hasNextPage}
+
+	if len(r.nodes) > 0 {
+		cursor := postCursor(r.nodes[len(r.nodes)-1].post)
+		info.endCursor = &cursor
+	}
+
+	return info
+}
+
+func (r *postConnectionResolver) TotalCount() int32 {
+	return int32(r.total)
+}
+
+// resolver of the PostEdge type
+type postEdgeResolver struct {
+	node *postResolver
+}
+
+func (r *postEdgeResolver) Cursor() string {
+	return postCursor(r.node.post)
+}
+
+func (r *postEdgeResolver) Node() *postResolver {
+	return r.node
+}
+
+// resolver of the PageInfo type
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.endCursor
+}
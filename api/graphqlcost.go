@@ -0,0 +1,418 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// fields returning a page of items, their selections are counted once per item of the page
+var paginatedFields = map[string]bool{"authors": true, "posts": true}
+
+// queryComplexity estimates the cost of executing an operation of a valid GraphQL document, the
+// most expensive one if operationName is empty
+//
+// Every field costs 1, the selections of paginated fields are counted as many times as the page
+// may have items (first, or its default). Skipped and included fields are all counted.
+func queryComplexity(query, operationName string, variables map[string]interface{}) int {
+	parser := &costParser{tokens: tokenizeQuery(query), fragments: map[string][]costSelection{}}
+	operations := parser.document()
+	result := 0
+
+	for _, operation := range operations {
+		if operationName != "" && operation.name != operationName {
+			continue
+		}
+
+		values := map[string]interface{}{}
+
+		for name, value := range operation.defaults {
+			values[name] = value
+		}
+
+		for name, value := range variables {
+			values[name] = value
+		}
+
+		estimator := &costEstimator{fragments: parser.fragments, variables: values, costs: map[string]int{}}
+
+		if cost := estimator.cost(operation.selections); cost > result {
+			result = cost
+		}
+	}
+
+	return result
+}
+
+// token of a GraphQL document
+type costToken struct {
+	kind byte   // 'p' punctuator, 'n' name, 'i' integer, 'v' any other value
+	text string // text of punctuators, names and integers
+}
+
+// tokenizeQuery splits a GraphQL document into tokens, skipping whitespace, commas and comments
+func tokenizeQuery(query string) []costToken {
+	var tokens []costToken
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' && query[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "..."):
+			tokens = append(tokens, costToken{kind: 'p', text: "..."})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+			tokens = append(tokens, costToken{kind: 'p', text: string(c)})
+			i++
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+
+			for i < len(query) && (query[i] == '_' || (query[i] >= 'a' && query[i] <= 'z') || (query[i] >= 'A' && query[i] <= 'Z') || (query[i] >= '0' && query[i] <= '9')) {
+				i++
+			}
+
+			tokens = append(tokens, costToken{kind: 'n', text: query[start:i]})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			kind := byte('i')
+
+			for i++; i < len(query) && strings.IndexByte("0123456789.eE+-", query[i]) >= 0; i++ {
+				if strings.IndexByte(".eE", query[i]) >= 0 {
+					kind = 'v'
+				}
+			}
+
+			tokens = append(tokens, costToken{kind: kind, text: query[start:i]})
+		case strings.HasPrefix(query[i:], `"""`):
+			end := strings.Index(strings.ReplaceAll(query[i+3:], `\"""`, "xxxx"), `"""`)
+
+			if end < 0 {
+				return tokens
+			}
+
+			tokens = append(tokens, costToken{kind: 'v'})
+			i += 3 + end + 3
+		case c == '"':
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+
+			tokens = append(tokens, costToken{kind: 'v'})
+			i++
+		default:
+			// e.g. a byte order mark
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// operation of a GraphQL document
+type costOperation struct {
+	name       string
+	defaults   map[string]interface{} // default values of the variables
+	selections []costSelection
+}
+
+// field, inline fragment or fragment spread of a selection set
+type costSelection struct {
+	field      string      // name of the field, empty for fragments
+	first      interface{} // value of the field's first argument, nil if none
+	spread     string      // name of a spread fragment
+	selections []costSelection
+}
+
+// variable used as an argument value
+type costVariable string
+
+// costParser reads the operations and fragments of a GraphQL document that has been validated
+type costParser struct {
+	tokens    []costToken
+	position  int
+	fragments map[string][]costSelection
+}
+
+// costParser's peek returns the current token, the zero token at the end
+func (p *costParser) peek() costToken {
+	if p.position >= len(p.tokens) {
+		return costToken{}
+	}
+
+	return p.tokens[p.position]
+}
+
+// costParser's next returns the current token and moves to the next one
+func (p *costParser) next() costToken {
+	token := p.peek()
+	p.position++
+
+	return token
+}
+
+// costParser's at reports if the current token is the punctuator or name text
+func (p *costParser) at(text string) bool {
+	token := p.peek()
+
+	return token.kind != 'v' && token.text == text
+}
+
+// costParser's end reports if all tokens have been read
+func (p *costParser) end() bool {
+	return p.position >= len(p.tokens)
+}
+
+// costParser's document reads the operations, fragments are kept in fragments
+func (p *costParser) document() []costOperation {
+	var operations []costOperation
+
+	for !p.end() {
+		switch {
+		case p.at("{"):
+			operations = append(operations, costOperation{selections: p.selectionSet()})
+		case p.at("fragment"):
+			p.next()
+			name := p.next().text
+			p.next() // on
+			p.next() // type
+			p.directives()
+			p.fragments[name] = p.selectionSet()
+		default:
+			p.next() // query, mutation or subscription
+			operation := costOperation{defaults: map[string]interface{}{}}
+
+			if p.peek().kind == 'n' {
+				operation.name = p.next().text
+			}
+
+			if p.at("(") {
+				p.variableDefinitions(operation.defaults)
+			}
+
+			p.directives()
+			operation.selections = p.selectionSet()
+			operations = append(operations, operation)
+		}
+	}
+
+	return operations
+}
+
+// costParser's variableDefinitions reads "($name: Type = default ...)" into defaults
+func (p *costParser) variableDefinitions(defaults map[string]interface{}) {
+	p.next()
+
+	for !p.end() && !p.at(")") {
+		p.next() // $
+		name := p.next().text
+		p.next() // :
+
+		// the type, e.g. [Int!]!
+		for depth := 0; !p.end(); {
+			switch p.next().text {
+			case "[":
+				depth++
+			case "]":
+				depth--
+			}
+
+			if depth == 0 && !p.at("!") {
+				break
+			}
+		}
+
+		if p.at("=") {
+			p.next()
+			defaults[name] = p.value()
+		}
+
+		p.directives()
+	}
+
+	p.next()
+}
+
+// costParser's selectionSet reads "{ ... }", empty if there is none
+func (p *costParser) selectionSet() []costSelection {
+	var selections []costSelection
+
+	if !p.at("{") {
+		return nil
+	}
+
+	p.next()
+
+	for !p.end() && !p.at("}") {
+		if p.at("...") {
+			p.next()
+
+			switch {
+			case p.at("on"):
+				p.next()
+				p.next()
+				fallthrough
+			case p.at("@") || p.at("{"):
+				p.directives()
+				selections = append(selections, costSelection{selections: p.selectionSet()})
+			default:
+				selections = append(selections, costSelection{spread: p.next().text})
+				p.directives()
+			}
+
+			continue
+		}
+
+		selection := costSelection{field: p.next().text}
+
+		// alias: field
+		if p.at(":") {
+			p.next()
+			selection.field = p.next().text
+		}
+
+		if p.at("(") {
+			selection.first = p.arguments()["first"]
+		}
+
+		p.directives()
+		selection.selections = p.selectionSet()
+		selections = append(selections, selection)
+	}
+
+	p.next()
+
+	return selections
+}
+
+// costParser's arguments reads "(name: value ...)"
+func (p *costParser) arguments() map[string]interface{} {
+	arguments := map[string]interface{}{}
+
+	p.next()
+
+	for !p.end() && !p.at(")") {
+		name := p.next().text
+		p.next() // :
+		arguments[name] = p.value()
+	}
+
+	p.next()
+
+	return arguments
+}
+
+// costParser's directives skips "@name(arguments)" directives
+func (p *costParser) directives() {
+	for p.at("@") {
+		p.next()
+		p.next()
+
+		if p.at("(") {
+			p.arguments()
+		}
+	}
+}
+
+// costParser's value reads a value, only integers and variables are kept
+func (p *costParser) value() interface{} {
+	token := p.next()
+
+	switch {
+	case token.kind == 'i':
+		value, _ := strconv.Atoi(token.text)
+		return value
+	case token.kind == 'p' && token.text == "$":
+		return costVariable(p.next().text)
+	case token.kind == 'p' && (token.text == "[" || token.text == "{"):
+		closing := map[string]string{"[": "]", "{": "}"}[token.text]
+
+		for !p.end() && !p.at(closing) {
+			// object fields are "name: value"
+			if token.text == "{" {
+				p.next()
+				p.next()
+			}
+
+			p.value()
+		}
+
+		p.next()
+	}
+
+	return nil
+}
+
+// costEstimator sums the costs of selections of an operation
+type costEstimator struct {
+	fragments map[string][]costSelection
+	variables map[string]interface{}
+	costs     map[string]int // of the fragments already estimated
+}
+
+// costEstimator's cost returns the cost of selections
+func (e *costEstimator) cost(selections []costSelection) int {
+	total := 0
+
+	for _, selection := range selections {
+		switch {
+		case selection.spread != "":
+			// fragments spread many times are estimated once, -1 marks the ones being estimated
+			cost, ok := e.costs[selection.spread]
+
+			if !ok {
+				e.costs[selection.spread] = -1
+				cost = e.cost(e.fragments[selection.spread])
+				e.costs[selection.spread] = cost
+			}
+
+			if cost > 0 {
+				total += cost
+			}
+		case selection.field == "":
+			total += e.cost(selection.selections)
+		default:
+			items := 1
+
+			if paginatedFields[selection.field] {
+				items = e.pageSize(selection.first)
+			}
+
+			total += 1 + items*e.cost(selection.selections)
+		}
+	}
+
+	return total
+}
+
+// costEstimator's pageSize returns the number of items a page asked for with first may have
+func (e *costEstimator) pageSize(first interface{}) int {
+	if variable, ok := first.(costVariable); ok {
+		first = e.variables[string(variable)]
+	}
+
+	size := defaultPageSize
+
+	switch first := first.(type) {
+	case int:
+		size = first
+	case float64:
+		size = int(first)
+	}
+
+	if size < 0 {
+		return 0
+	}
+
+	if size > maxPageSize {
+		return maxPageSize
+	}
+
+	return size
+}
@@ -0,0 +1,155 @@
+package api
+
+import "testing"
+
+func TestQueryComplexity(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		operationName string
+		variables     map[string]interface{}
+		want          int
+	}{
+		{
+			name:  "fields",
+			query: `{ post(id: "1") { id title } }`,
+			want:  3,
+		},
+		{
+			name:  "default page size",
+			query: `{ posts { edges { node { id } } } }`,
+			want:  1 + 20*3,
+		},
+		{
+			name:  "first",
+			query: `{ posts(first: 5) { totalCount edges { node { title } } } }`,
+			want:  1 + 5*4,
+		},
+		{
+			name:  "first above the maximum",
+			query: `{ posts(first: 1000) { edges { node { id } } } }`,
+			want:  1 + 100*3,
+		},
+		{
+			name:  "negative first",
+			query: `{ posts(first: -1) { edges { node { id } } } }`,
+			want:  1,
+		},
+		{
+			name:  "nested pages",
+			query: `{ authors(first: 10) { edges { node { username posts(first: 5) { edges { node { title } } } } } } }`,
+			want:  1 + 10*(1+(1+(1+(1+5*3)))),
+		},
+		{
+			name:  "nested default pages",
+			query: `{ authors { edges { node { posts { totalCount } } } } }`,
+			want:  1 + 20*(1+(1+(1+20))),
+		},
+		{
+			name:  "fragment spread",
+			query: `query { posts(first: 2) { edges { node { ...post } } } } fragment post on Post { id title author { username } }`,
+			want:  1 + 2*(1+(1+4)),
+		},
+		{
+			name:  "fragment defined first and spread twice",
+			query: `fragment post on Post { id title } { a: post(id: "1") { ...post } b: post(id: "2") { ...post } }`,
+			want:  2 * 3,
+		},
+		{
+			name:  "fragment with a page",
+			query: `{ viewer { ...pages } } fragment pages on Author { posts(first: 3) { edges { node { id } } } }`,
+			want:  1 + (1 + 3*3),
+		},
+		{
+			name:  "inline fragments",
+			query: `{ viewer { ... on Author { username } ... @include(if: true) { id } } }`,
+			want:  3,
+		},
+		{
+			name:  "aliases",
+			query: `{ first: posts(first: 3) { totalCount } second: posts { totalCount } }`,
+			want:  (1 + 3) + (1 + 20),
+		},
+		{
+			name:  "alias of a non paginated name",
+			query: `{ items: posts(first: 2) { count: totalCount } }`,
+			want:  1 + 2,
+		},
+		{
+			name:      "first as a variable",
+			query:     `query Page($n: Int) { posts(first: $n) { totalCount } }`,
+			variables: map[string]interface{}{"n": float64(7)},
+			want:      1 + 7,
+		},
+		{
+			name:  "first as a variable not given",
+			query: `query Page($n: Int) { posts(first: $n) { totalCount } }`,
+			want:  1 + 20,
+		},
+		{
+			name:  "first as a variable with a default",
+			query: `query Page($n: Int = 4) { posts(first: $n) { totalCount } }`,
+			want:  1 + 4,
+		},
+		{
+			name:      "first as a variable overriding its default",
+			query:     `query Page($n: Int = 4) { posts(first: $n) { totalCount } }`,
+			variables: map[string]interface{}{"n": float64(2)},
+			want:      1 + 2,
+		},
+		{
+			name:  "defaults after list and non-null types",
+			query: `query Page($tags: [String!]! = ["a", "b"], $n: Int! = 6) { posts(first: $n) { totalCount } }`,
+			want:  1 + 6,
+		},
+		{
+			name:  "block string",
+			query: `{ post(id: """1 { posts { edges { node { id } } } }""") { title } }`,
+			want:  2,
+		},
+		{
+			name:  "block string with an escaped quote",
+			query: `{ post(id: """a \""" posts { id } """) { title } }`,
+			want:  2,
+		},
+		{
+			name:  "string with an escaped quote",
+			query: `{ post(id: "a \" { posts { id } }") { title } }`,
+			want:  2,
+		},
+		{
+			name:  "comment",
+			query: "# posts { edges { node { id } } }\n{ viewer { id } }",
+			want:  2,
+		},
+		{
+			name:  "object argument",
+			query: `mutation { createPost(input: {title: "a", body: "b", tags: ["x"]}) { id } }`,
+			want:  2,
+		},
+		{
+			name:  "directives are counted",
+			query: `{ posts(first: 2) @skip(if: false) { totalCount } }`,
+			want:  1 + 2,
+		},
+		{
+			name:  "most expensive operation",
+			query: `query Cheap { viewer { id } } query Expensive { posts { totalCount } }`,
+			want:  1 + 20,
+		},
+		{
+			name:          "named operation",
+			query:         `query Cheap { viewer { id } } query Expensive { posts { totalCount } }`,
+			operationName: "Cheap",
+			want:          2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := queryComplexity(test.query, test.operationName, test.variables); got != test.want {
+				t.Errorf("queryComplexity is %d, want %d", got, test.want)
+			}
+		})
+	}
+}
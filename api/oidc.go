@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// exchangeIdentity redeems the authorization code in the body of req at the identity provider and
+// returns the identity it was issued for, on failure a problem has been sent and false is returned
+func exchangeIdentity(res http.ResponseWriter, req *http.Request) (oidc.Identity, bool) {
+	if oidc.Default == nil {
+		helpers.NotFoundResponse(res)
+		return oidc.Identity{}, false
+	}
+
+	var body types.OIDCRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return oidc.Identity{}, false
+	}
+
+	if !validate(res, body.Validate()) {
+		return oidc.Identity{}, false
+	}
+
+	if !oidc.Default.RedirectAllowed(body.RedirectURI) {
+		helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "redirect_uri", Code: "invalid", Message: "Redirect URI is not allowed"}})
+		return oidc.Identity{}, false
+	}
+
+	identity, err := oidc.Default.Exchange(body.Code, body.CodeVerifier, body.RedirectURI, body.Nonce)
+
+	if err != nil {
+		log.Printf("oidc: exchanging code: %v", err)
+		helpers.ProblemResponse(res, helpers.NewProblem(http.StatusUnauthorized, helpers.CodeInvalidCredentials, "Signing in with the identity provider failed!"))
+		return oidc.Identity{}, false
+	}
+
+	return identity, true
+}
+
+// OIDCHandler links the logged in author's account to an identity at the identity provider
+type OIDCHandler struct {
+}
+
+// OIDCHandler's ServeHTTP handles URLs of type
+//
+// POST		<base>/api/account/oidc	Link the identity an authorization code was issued for
+//
+// DELETE	<base>/api/account/oidc	Unlink the identities at the identity provider
+func (h *OIDCHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if req.URL.Path != "/" || oidc.Default == nil {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	switch req.Method {
+	case "POST":
+		identity, ok := exchangeIdentity(res, req)
+
+		if !ok {
+			return
+		}
+
+		if err := models.LinkIdentity(authorId, identity); err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Identity linked!"})
+	case "DELETE":
+		if err := models.UnlinkIdentities(authorId, oidc.Default.Issuer); err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Identity unlinked!"})
+	default:
+		helpers.MethodNotAllowedResponse(res)
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samkit-jain/go-blog/dbtest"
+)
+
+func TestLoginOIDCRejects(t *testing.T) {
+	env := newSpecEnv(t)
+	handler := NewApiHandler()
+	handler.RateLimiter = nil
+
+	tests := []struct {
+		name   string
+		body   func() string // body of the login request
+		status int
+	}{
+		{"unlisted redirect URI", func() string {
+			return strings.Replace(env.oidcBody(), "http://127.0.0.1:8400/callback", "https://evil.example.com/callback", 1)
+		}, 422},
+		{"bad verifier", func() string {
+			return strings.Replace(env.oidcBody(), "verifier-of-the-test-client", "other-verifier", 1)
+		}, 401},
+		{"unknown code", func() string {
+			return `{"code":"unknown","code_verifier":"verifier-of-the-test-client","redirect_uri":"http://127.0.0.1:8400/callback"}`
+		}, 401},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// no rules, nobody may be logged in
+			db := dbtest.Open(t)
+
+			req := httptest.NewRequest("POST", "/v2/login/oidc", strings.NewReader(test.body()))
+			req.Header.Set("Content-Type", "application/json")
+			res := httptest.NewRecorder()
+
+			handler.ServeHTTP(res, req)
+
+			if res.Code != test.status {
+				t.Errorf("status %d, want %d: %s", res.Code, test.status, res.Body)
+			}
+
+			if queries := db.Queries(); len(queries) > 0 {
+				t.Errorf("ran %d queries", len(queries))
+			}
+		})
+	}
+}
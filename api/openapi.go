@@ -0,0 +1,578 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// documented operation of the API
+type operation struct {
+	Method      string              // HTTP method
+	Path        string              // path below <base>/api/:version, path parameters as {name}
+	Summary     string              // short description
+	Auth        bool                // needs the "token" header
+	Body        interface{}         // request body, nil if none
+	Multipart   bool                // body is multipart/form-data instead of JSON or form encoded
+	MergePatch  bool                // body is a JSON merge patch of Body
+	Responses   map[int]interface{} // success responses by status, nil for an empty body
+	Files       []string            // media types of a file sent instead of the nil success bodies
+	Problems    []int               // problem responses besides the ones every operation can return
+	Description string              // longer description (optional)
+}
+
+// envelope is the {"status", "content"} response of v1 around Content
+type envelope struct {
+	Content interface{}
+}
+
+// collection is the {"items", "count"} response of v2 around a slice of Items
+type collection struct {
+	Items interface{}
+}
+
+// content of v1's author response
+type v1AuthorPosts struct {
+	AuthorInfo types.Author        `json:"author"`
+	List       []types.PostSummary `json:"posts"`
+}
+
+// operations of v1, frozen
+var v1Operations = []operation{
+	{Method: "GET", Path: "/authors/", Summary: "All authors (excluding posts)",
+		Responses: map[int]interface{}{200: envelope{[]types.Author{}}}},
+	{Method: "POST", Path: "/authors/", Summary: "Create an author", Body: types.CreateAuthorRequest{},
+		Responses: map[int]interface{}{200: envelope{""}}, Problems: []int{409}},
+	{Method: "GET", Path: "/authors/{authorId}", Summary: "Author including posts",
+		Responses: map[int]interface{}{200: envelope{v1AuthorPosts{}}}, Problems: []int{404}},
+	{Method: "GET", Path: "/posts/", Summary: "All posts",
+		Responses: map[int]interface{}{200: envelope{[]types.Post{}}}},
+	{Method: "POST", Path: "/posts/", Summary: "Create a post", Auth: true, Body: types.PostRequest{},
+		Responses: map[int]interface{}{200: envelope{""}}},
+	{Method: "DELETE", Path: "/posts/", Summary: "Delete all posts of the logged in author", Auth: true,
+		Responses: map[int]interface{}{200: envelope{""}}},
+	{Method: "GET", Path: "/posts/{postId}", Summary: "A specific post",
+		Responses: map[int]interface{}{200: envelope{types.Post{}}}, Problems: []int{404}},
+	{Method: "PUT", Path: "/posts/{postId}", Summary: "Update a specific post", Auth: true, Body: types.PostRequest{},
+		Responses: map[int]interface{}{200: envelope{""}}, Problems: []int{403, 404, 412}},
+	{Method: "PATCH", Path: "/posts/{postId}", Summary: "Partially update a specific post", Auth: true, Body: types.PostRequest{}, MergePatch: true,
+		Responses: map[int]interface{}{200: envelope{""}}, Problems: []int{403, 404, 412}},
+	{Method: "DELETE", Path: "/posts/{postId}", Summary: "Delete a specific post", Auth: true,
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}, Problems: []int{403, 404, 412}},
+	{Method: "POST", Path: "/login/", Summary: "Log in, returns a session token", Body: types.LoginRequest{},
+		Responses: map[int]interface{}{200: envelope{""}}, Problems: []int{401},
+		Description: "Authors with two-factor authentication enabled must also send `otp`."},
+}
+
+// operations of v2
+var v2Operations = []operation{
+	{Method: "GET", Path: "/authors/", Summary: "All authors (excluding posts)",
+		Responses: map[int]interface{}{200: collection{[]types.Author{}}}},
+	{Method: "POST", Path: "/authors/", Summary: "Create an author", Body: types.CreateAuthorRequest{},
+		Responses: map[int]interface{}{201: types.Author{}}, Problems: []int{409}},
+	{Method: "GET", Path: "/authors/{authorId}", Summary: "Author including posts",
+		Responses: map[int]interface{}{200: types.AuthorDetail{}}, Problems: []int{404}},
+	{Method: "GET", Path: "/posts/", Summary: "All posts",
+		Responses: map[int]interface{}{200: collection{[]types.Post{}}}},
+	{Method: "POST", Path: "/posts/", Summary: "Create a post", Auth: true, Body: types.PostRequest{},
+		Responses: map[int]interface{}{201: types.Post{}}},
+	{Method: "DELETE", Path: "/posts/", Summary: "Delete all posts of the logged in author", Auth: true,
+		Responses: map[int]interface{}{204: nil}},
+	{Method: "GET", Path: "/posts/{postId}", Summary: "A specific post",
+		Responses: map[int]interface{}{200: types.Post{}}, Problems: []int{404}},
+	{Method: "PUT", Path: "/posts/{postId}", Summary: "Update a specific post", Auth: true, Body: types.PostRequest{},
+		Responses: map[int]interface{}{200: types.Post{}}, Problems: []int{403, 404, 412}},
+	{Method: "PATCH", Path: "/posts/{postId}", Summary: "Partially update a specific post", Auth: true, Body: types.PostRequest{}, MergePatch: true,
+		Responses: map[int]interface{}{200: types.Post{}}, Problems: []int{403, 404, 412}},
+	{Method: "DELETE", Path: "/posts/{postId}", Summary: "Delete a specific post", Auth: true,
+		Responses: map[int]interface{}{204: nil}, Problems: []int{403, 404, 412}},
+	{Method: "POST", Path: "/login/", Summary: "Log in, returns a session token and its expiry", Body: types.LoginRequest{},
+		Responses: map[int]interface{}{200: types.Token{}}, Problems: []int{401},
+		Description: "Authors with two-factor authentication enabled must also send `otp`."},
+	{Method: "POST", Path: "/login/oidc", Summary: "Log in with an authorization code of the identity provider", Body: types.OIDCRequest{},
+		Responses: map[int]interface{}{200: types.Token{}}, Problems: []int{401, 403, 404},
+		Description: "The client runs the authorization code flow with PKCE itself and sends the code with its verifier. Only exists if single sign-on is configured."},
+	{Method: "GET", Path: "/admin/export", Summary: "Export the whole site as an archive", Auth: true,
+		Responses: map[int]interface{}{200: nil}, Files: []string{"application/zip", "application/gzip"}, Problems: []int{422},
+		Description: "Only allowed to the administrators. The archive is the same as an author's export, with the content of every author."},
+	{Method: "POST", Path: "/admin/import", Summary: "Import posts from a WordPress, Markdown or JSON export", Auth: true, Body: types.ImportRequest{}, Multipart: true,
+		Responses:   map[int]interface{}{200: types.ImportReport{}},
+		Description: "Only allowed to the administrators. `file` is a WXR file, a ZIP or (gzip compressed) tar archive of Markdown files, or a JSON export as a file or an archive. Posts imported before from the same `source` are reported as `exists`, `dry_run` reports without writing. `map_authors` maps authors of the export to usernames as `old=new`."},
+}
+
+// operations served the same by every version
+var sharedOperations = []operation{
+	{Method: "POST", Path: "/account/email", Summary: "Set the email address and send a verification link", Auth: true, Body: types.EmailRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}, Problems: []int{409}},
+	{Method: "POST", Path: "/account/verify", Summary: "Verify an email address", Body: types.TokenRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}},
+	{Method: "POST", Path: "/account/forgot", Summary: "Email a password reset link", Body: types.ForgotRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}},
+	{Method: "POST", Path: "/account/reset", Summary: "Reset the password", Body: types.TokenRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}},
+	{Method: "POST", Path: "/account/totp", Summary: "Enrol in two-factor authentication", Auth: true,
+		Responses: map[int]interface{}{200: envelope{types.TOTPEnrolment{}}}, Problems: []int{409}},
+	{Method: "POST", Path: "/account/totp/confirm", Summary: "Enable two-factor authentication, returns recovery codes", Auth: true, Body: types.OTPRequest{},
+		Responses: map[int]interface{}{200: envelope{[]string{}}}, Problems: []int{409}},
+	{Method: "DELETE", Path: "/account/totp", Summary: "Disable two-factor authentication", Auth: true, Body: types.OTPRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}, Problems: []int{409}},
+	{Method: "POST", Path: "/account/oidc", Summary: "Link an identity of the identity provider", Auth: true, Body: types.OIDCRequest{},
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}, Problems: []int{404, 409}},
+	{Method: "DELETE", Path: "/account/oidc", Summary: "Unlink the identities of the identity provider", Auth: true,
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}, Problems: []int{404}},
+	{Method: "GET", Path: "/account/keys", Summary: "List the API keys", Auth: true,
+		Responses: map[int]interface{}{200: envelope{[]types.APIKey{}}}},
+	{Method: "POST", Path: "/account/keys", Summary: "Create an API key", Auth: true, Body: types.CreateAPIKeyRequest{},
+		Responses:   map[int]interface{}{201: envelope{types.APIKey{}}},
+		Description: "`key` is only returned here, it can't be retrieved later. Scopes are `read`, `write:posts` and `admin`."},
+	{Method: "DELETE", Path: "/account/keys/{keyId}", Summary: "Revoke an API key", Auth: true,
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}},
+	{Method: "GET", Path: "/account/webhooks", Summary: "List the webhooks", Auth: true,
+		Responses: map[int]interface{}{200: envelope{[]types.Webhook{}}}},
+	{Method: "POST", Path: "/account/webhooks", Summary: "Create a webhook", Auth: true, Body: types.CreateWebhookRequest{},
+		Responses:   map[int]interface{}{201: envelope{types.Webhook{}}},
+		Description: "Events are `post.created`, `post.updated`, `post.deleted` and `author.created` of the whole site. `secret` is only returned here, payloads are signed with it in the `X-Goblog-Signature` header."},
+	{Method: "DELETE", Path: "/account/webhooks/{webhookId}", Summary: "Delete a webhook", Auth: true,
+		Responses: map[int]interface{}{200: types.DefaultResponse{}}},
+	{Method: "GET", Path: "/account/webhooks/{webhookId}/deliveries", Summary: "List the latest deliveries of a webhook", Auth: true,
+		Responses: map[int]interface{}{200: envelope{[]types.WebhookDelivery{}}}},
+	{Method: "POST", Path: "/account/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", Summary: "Deliver the event of a delivery again", Auth: true,
+		Responses: map[int]interface{}{202: envelope{types.WebhookDelivery{}}}},
+	{Method: "GET", Path: "/authors/{authorId}/export", Summary: "Export the logged in author's posts and uploads as an archive", Auth: true,
+		Responses: map[int]interface{}{200: nil}, Files: []string{"application/zip", "application/gzip"}, Problems: []int{422},
+		Description: "`?format=zip` (default) or `tar` (gzip compressed). The archive holds `manifest.json` (the author, tags, posts and attachments), every post as `posts/<id>.md` with YAML front matter and the uploaded files under `media/`. It can be imported with `POST /api/v2/admin/import`."},
+	{Method: "POST", Path: "/uploads", Summary: "Upload a file, optionally linked to a post", Auth: true, Multipart: true,
+		Responses: map[int]interface{}{200: envelope{types.Attachment{}}}, Problems: []int{403}},
+}
+
+// NewSpec returns the OpenAPI 3.1 document of the API
+func NewSpec() map[string]interface{} {
+	g := &schemaGenerator{schemas: map[string]interface{}{}}
+	paths := map[string]map[string]interface{}{}
+
+	add := func(version string, ops []operation, deprecated bool) {
+		for _, op := range ops {
+			path := "/api/" + version + op.Path
+
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+
+			item := g.operation(op)
+			item["operationId"] = version + operationName(op)
+			item["tags"] = []string{version}
+
+			if deprecated {
+				item["deprecated"] = true
+			}
+
+			paths[path][strings.ToLower(op.Method)] = item
+		}
+	}
+
+	add("v1", v1Operations, true)
+	add("v1", sharedOperations, true)
+	add("v2", v2Operations, false)
+	add("v2", sharedOperations, false)
+
+	// unversioned, the stream isn't JSON so it's described by hand
+	paths["/api/stream"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "getStream",
+			"summary":     "Stream post events as Server-Sent Events",
+			"description": "Events are `post.created`, `post.updated` (data is the post) and `post.deleted` (data is `{\"id\", \"author_id\"}`). Reconnecting clients send `Last-Event-ID` to receive the events they missed.",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "Last-Event-ID", "in": "header", "schema": map[string]interface{}{"type": "string"}},
+				map[string]interface{}{"name": "last_event_id", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+
+	// unversioned, GraphQL responses are described by the schema of the endpoint
+	paths["/api/graphql"] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "postGraphql",
+			"summary":     "Execute a GraphQL query or mutation",
+			"description": "The schema can be introspected. Errors of executable queries are listed in `errors` of a 200 response with the problem `code` in their `extensions`.",
+			"security":    []interface{}{map[string]interface{}{}, map[string]interface{}{"token": []string{}}, map[string]interface{}{"apiKey": []string{}}},
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"query"},
+							"properties": map[string]interface{}{
+								"query":         map[string]interface{}{"type": "string"},
+								"operationName": map[string]interface{}{"type": []string{"string", "null"}},
+								"variables":     map[string]interface{}{"type": []string{"object", "null"}},
+								"extensions":    map[string]interface{}{"type": []string{"object", "null"}},
+							},
+						},
+					},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"data":   map[string]interface{}{"type": []string{"object", "null"}},
+									"errors": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// registers the Problem component
+	g.schema(reflect.TypeOf(types.Problem{}), false)
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "go-blog",
+			"version":     "2",
+			"description": "Paths without a version (`/api/...`) are served by v1. v1 is deprecated.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token":  map[string]interface{}{"type": "apiKey", "in": "header", "name": "token"},
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// operationName returns a camel case name of op, e.g. "getPostsPostId"
+func operationName(op operation) string {
+	name := strings.ToLower(op.Method)
+
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '{' || r == '}' }) {
+		name += strings.ToUpper(part[:1]) + part[1:]
+	}
+
+	return name
+}
+
+// schemaGenerator builds JSON schemas from Go types, named structs end up in schemas
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// operation returns the OpenAPI operation object of op
+func (g *schemaGenerator) operation(op operation) map[string]interface{} {
+	item := map[string]interface{}{"summary": op.Summary}
+
+	if op.Description != "" {
+		item["description"] = op.Description
+	}
+
+	var parameters []interface{}
+
+	for _, part := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(part, "{") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     strings.Trim(part, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+
+	if op.Auth {
+		item["security"] = []interface{}{map[string]interface{}{"token": []string{}}, map[string]interface{}{"apiKey": []string{}}}
+	}
+
+	if parameters != nil {
+		item["parameters"] = parameters
+	}
+
+	if op.Multipart {
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"post_id": map[string]interface{}{"type": "string"}},
+		}
+
+		// the other fields of the form
+		if op.Body != nil {
+			schema = g.object(reflect.TypeOf(op.Body), true)
+		}
+
+		schema["required"] = []string{"file"}
+		schema["properties"].(map[string]interface{})["file"] = map[string]interface{}{"type": "string", "contentMediaType": "application/octet-stream"}
+
+		item["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{"schema": schema},
+			},
+		}
+	} else if op.MergePatch {
+		schema := g.schema(reflect.TypeOf(op.Body), true)
+
+		item["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/merge-patch+json": map[string]interface{}{"schema": schema},
+				"application/json":             map[string]interface{}{"schema": schema},
+			},
+		}
+	} else if op.Body != nil {
+		schema := g.schema(reflect.TypeOf(op.Body), true)
+
+		item["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json":                  map[string]interface{}{"schema": schema},
+				"application/x-www-form-urlencoded": map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+
+	for status, body := range op.Responses {
+		response := map[string]interface{}{"description": http.StatusText(status)}
+
+		if body != nil {
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.responseSchema(body)},
+			}
+		} else if op.Files != nil {
+			content := map[string]interface{}{}
+
+			for _, mediaType := range op.Files {
+				content[mediaType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "contentMediaType": mediaType}}
+			}
+
+			response["content"] = content
+		}
+
+		responses[strconv.Itoa(status)] = response
+	}
+
+	for _, status := range problemStatuses(op) {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/problem+json": map[string]interface{}{"schema": ref("Problem")},
+			},
+		}
+	}
+
+	item["responses"] = responses
+
+	return item
+}
+
+// problemStatuses returns the statuses of the problems op can respond with
+func problemStatuses(op operation) []int {
+	statuses := map[int]bool{http.StatusMethodNotAllowed: true, http.StatusTooManyRequests: true, http.StatusInternalServerError: true}
+
+	// any request may send an API key, invalid ones and ones lacking the scope are refused
+	statuses[http.StatusUnauthorized] = true
+	statuses[http.StatusForbidden] = true
+
+	for _, status := range op.Problems {
+		statuses[status] = true
+	}
+
+	if op.Body != nil || op.Multipart {
+		statuses[http.StatusBadRequest] = true
+		statuses[http.StatusRequestEntityTooLarge] = true
+		statuses[http.StatusUnsupportedMediaType] = true
+		statuses[http.StatusUnprocessableEntity] = true
+	}
+
+	// invalid IDs are answered with a plain 404
+	if strings.Contains(op.Path, "{") {
+		statuses[http.StatusNotFound] = true
+	}
+
+	var result []int
+
+	for status := range statuses {
+		result = append(result, status)
+	}
+
+	sort.Ints(result)
+
+	return result
+}
+
+// responseSchema returns the schema of a response body, unwrapping envelopes and collections
+func (g *schemaGenerator) responseSchema(body interface{}) map[string]interface{} {
+	switch body := body.(type) {
+	case envelope:
+		return map[string]interface{}{
+			"type":                 "object",
+			"required":             []string{"status", "content"},
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"status":  map[string]interface{}{"type": "string", "const": "success"},
+				"content": g.schema(reflect.TypeOf(body.Content), false),
+			},
+		}
+	case collection:
+		return map[string]interface{}{
+			"type":                 "object",
+			"required":             []string{"items", "count"},
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"items": g.schema(reflect.TypeOf(body.Items), false),
+				"count": map[string]interface{}{"type": "integer"},
+			},
+		}
+	default:
+		return g.schema(reflect.TypeOf(body), false)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the JSON schema of t as encoding/json encodes it, named structs are added to
+// g.schemas and referenced, request schemas have no required fields as handlers validate them
+func (g *schemaGenerator) schema(t reflect.Type, request bool) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// nil slices are encoded as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": g.schema(t.Elem(), request)}
+	case reflect.Ptr:
+		return g.schema(t.Elem(), request)
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, request)
+		}
+
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// placeholder against recursion
+			g.schemas[t.Name()] = map[string]interface{}{}
+			g.schemas[t.Name()] = g.object(t, request)
+		}
+
+		return ref(t.Name())
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object returns the schema of struct t
+func (g *schemaGenerator) object(t reflect.Type, request bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	var addFields func(t reflect.Type)
+
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+
+			if tag == "-" || field.PkgPath != "" {
+				continue
+			}
+
+			// embedded structs without a name have their fields promoted
+			if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+
+			name := strings.Split(tag, ",")[0]
+
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = g.schema(field.Type, request)
+
+			if !request && !strings.Contains(tag, ",omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+
+	addFields(t)
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// ref returns a reference to the component schema name
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// Handler for <base>/api/openapi.json call
+type SpecHandler struct {
+	Spec map[string]interface{}
+}
+
+// SpecHandler's ServeHTTP returns the OpenAPI document of the API
+//
+// GET	<base>/api/openapi.json
+func (h *SpecHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "GET" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(h.Spec)
+
+	return
+}
@@ -0,0 +1,805 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samkit-jain/go-blog/dbtest"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/mailer"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/ratelimit"
+	"github.com/samkit-jain/go-blog/storage"
+)
+
+// IDs of the rows the scripted database answers with
+const (
+	testAuthorId   = "01HV0000000000000000000A01"
+	testPostId     = "01HV0000000000000000000P0S"
+	testKeyId      = "01HV0000000000000000000KEY"
+	testWebhookId  = "01HV0000000000000000000WHK"
+	testDeliveryId = "01HV0000000000000000000D01"
+	testEventId    = "01HV0000000000000000000EVT"
+	otherAuthorId  = "01HV0000000000000000000A02"
+)
+
+// invalidIds replaces the IDs of paths with ones that aren't valid
+var invalidIds = strings.NewReplacer(testAuthorId, "nope", testPostId, "nope", testKeyId, "nope", testWebhookId, "nope", testDeliveryId, "nope")
+
+var testCreatedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// specCase is a request of a documented operation, the database answering it and the status it's
+// answered with
+type specCase struct {
+	name    string                  // what the case checks, empty for the successful request
+	path    string                  // path below the version, parameters filled in
+	auth    bool                    // send the session token of testAuthorId
+	header  map[string]string       // more request headers
+	body    string                  // JSON body
+	newBody func() string           // body made for every request instead, e.g. with a fresh code
+	form    func(*multipart.Writer) // multipart body instead of body
+	db      func(*dbtest.DB)        // rules of the database
+	setup   func(*testing.T)        // changes to the environment until the end of the request
+	limited bool                    // sent to a handler allowing no requests
+	status  int                     // expected status
+}
+
+// specCase's with returns a copy of c with another name and status, changed by change
+func (c specCase) with(name string, status int, change func(*specCase)) specCase {
+	c.name, c.status = name, status
+
+	// the headers are copied so that change can set some
+	header := map[string]string{}
+
+	for key, value := range c.header {
+		header[key] = value
+	}
+
+	c.header = header
+	change(&c)
+
+	return c
+}
+
+// specEnv is what the cases of every version share
+type specEnv struct {
+	t        *testing.T
+	password string // password of the author, hash is its hash
+	hash     string
+	secret   string // TOTP secret of the author
+	apiKey   string // well-formed API key, the cases decide if the database knows it
+	stub     *httptest.Server
+}
+
+func newSpecEnv(t *testing.T) *specEnv {
+	t.Setenv("GOBLOG_SIGNING_KEY", "test signing key")
+	t.Setenv("GOBLOG_ADMINS", testAuthorId)
+
+	env := &specEnv{t: t, password: "correct horse battery", secret: "JBSWY3DPEHPK3PXP"}
+
+	hash, err := helpers.HashPassword(env.password)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.hash = hash
+
+	if env.apiKey, _, _, err = helpers.GenerateAPIKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	stub, err := oidc.NewStub("http://idp.invalid", "goblog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.stub = httptest.NewServer(stub)
+	t.Cleanup(env.stub.Close)
+	stub.Issuer = env.stub.URL
+
+	previousProvider, previousStore, previousMailer := oidc.Default, storage.Default, mailer.Default
+	oidc.Default = &oidc.Provider{Issuer: env.stub.URL, ClientID: "goblog", UsernameClaim: "preferred_username", EmailClaim: "email", Client: env.stub.Client()}
+	storage.Default = &storage.LocalStore{Dir: t.TempDir()}
+	mailer.Default = &mailer.LogMailer{Writer: ioutil.Discard, From: "blog@example.com"}
+
+	t.Cleanup(func() {
+		oidc.Default, storage.Default, mailer.Default = previousProvider, previousStore, previousMailer
+	})
+
+	return env
+}
+
+// specEnv's oidcBody returns the body of a request redeeming a fresh code of the stub
+func (env *specEnv) oidcBody() string {
+	redirectURI, verifier := "http://127.0.0.1:8400/callback", "verifier-of-the-test-client"
+
+	query := url.Values{
+		"client_id":             {"goblog"},
+		"response_type":         {"code"},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {oidc.Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"login_hint":            {"subject-1"},
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(env.stub.URL + "/authorize?" + query.Encode())
+
+	if err != nil {
+		env.t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil {
+		env.t.Fatal(err)
+	}
+
+	return `{"code":"` + location.Query().Get("code") + `","code_verifier":"` + verifier + `","redirect_uri":"` + redirectURI + `"}`
+}
+
+// specEnv's otp returns the current TOTP code of the author
+func (env *specEnv) otp() string {
+	code, err := helpers.TOTPCode(env.secret, helpers.TOTPStep(time.Now()))
+
+	if err != nil {
+		env.t.Fatal(err)
+	}
+
+	return code
+}
+
+// specEnv's signedToken returns a token for purpose as sent in emails
+func (env *specEnv) signedToken(purpose string) string {
+	token, _, err := helpers.CreateSignedToken(purpose)
+
+	if err != nil {
+		env.t.Fatal(err)
+	}
+
+	return token
+}
+
+// rules shared by the cases
+func authorRows(db *dbtest.DB) {
+	db.On("SELECT username, created_at FROM authors WHERE author_id=").Rows(dbtest.Row("sam", testCreatedAt))
+}
+
+func authorPostsRows(db *dbtest.DB) {
+	db.On("FROM posts WHERE author_id=$1 ORDER BY created_at DESC").
+		Rows(dbtest.Row(testPostId, "Hello", "Some *text*", testCreatedAt, testCreatedAt, []string{"go"}))
+}
+
+func postRows(db *dbtest.DB) {
+	db.On("WHERE posts.post_id=$1").
+		Rows(dbtest.Row("sam", testAuthorId, testCreatedAt, "Hello", "Some *text*", testCreatedAt, testCreatedAt, 1, []string{"go"}))
+	db.On("SELECT author_id FROM posts WHERE post_id=").Rows(dbtest.Row(testAuthorId))
+	attachmentRows(db)
+}
+
+func attachmentRows(db *dbtest.DB) {
+	db.On("FROM attachments WHERE post_id=ANY").
+		Rows(dbtest.Row("01HV0000000000000000000ATT", testPostId, "a.png", "image/png", 68, "ab/ab.png", nil, testCreatedAt))
+}
+
+func writePostRows(db *dbtest.DB) {
+	db.On("INSERT INTO posts").Rows(dbtest.Row(testPostId))
+	db.On("UPDATE posts SET").Rows(dbtest.Row(2))
+	db.On("post_tags (post_id, tag)")
+	db.On("DELETE FROM post_tags")
+}
+
+func exportRows(db *dbtest.DB) {
+	db.On("FROM authors WHERE ($1='' OR author_id=$1)").Rows(dbtest.Row(testAuthorId, "sam", "sam@example.com", testCreatedAt))
+	db.On("WHERE ($1='' OR posts.author_id=$1)").
+		Rows(dbtest.Row("sam", testAuthorId, testCreatedAt, testPostId, "Hello", "Some *text*", testCreatedAt, testCreatedAt, 1, []string{"go"}))
+	db.On("FROM attachments JOIN authors")
+}
+
+func webhookRows(db *dbtest.DB) {
+	db.On("SELECT EXISTS (SELECT 1 FROM webhooks").Rows(dbtest.Row(true))
+}
+
+func deliveryRow(status string) []interface{} {
+	return []interface{}{testDeliveryId, testEventId, "post.created", status, 1, 200, nil, testCreatedAt, testCreatedAt, testCreatedAt}
+}
+
+// otherPostRows answers with the post as another author's
+func otherPostRows(db *dbtest.DB) {
+	db.On("WHERE posts.post_id=$1").
+		Rows(dbtest.Row("kim", otherAuthorId, testCreatedAt, "Hello", "Some *text*", testCreatedAt, testCreatedAt, 1, []string{"go"}))
+	attachmentRows(db)
+}
+
+// missedPostRows answers updates and deletes of the post as changing nothing, exists tells if the
+// author has the post (at another revision) and owner who has it, empty if nobody
+func missedPostRows(exists bool, owner string) func(*dbtest.DB) {
+	return func(db *dbtest.DB) {
+		db.On("UPDATE posts SET")
+		db.On("DELETE FROM posts WHERE author_id=$1 AND post_id=$2").Affected(0)
+		db.On("SELECT EXISTS (SELECT 1 FROM posts").Rows(dbtest.Row(exists))
+
+		if owner != "" {
+			db.On("SELECT author_id FROM posts WHERE post_id=").Rows(dbtest.Row(owner))
+		} else {
+			db.On("SELECT author_id FROM posts WHERE post_id=")
+		}
+	}
+}
+
+// pngForm is the multipart body of an upload of a PNG image
+func pngForm(w *multipart.Writer) {
+	file, _ := w.CreateFormFile("file", "a.png")
+	png.Encode(file, image.NewGray(image.Rect(0, 0, 2, 2)))
+}
+
+// v1SpecCases returns the cases of every operation of v1Operations by "METHOD path", the
+// successful request first
+func v1SpecCases(env *specEnv) map[string][]specCase {
+	cases := map[string][]specCase{
+		"GET /authors/": {{path: "/authors/", status: 200, db: func(db *dbtest.DB) {
+			db.On("FROM authors ORDER BY username").Rows(dbtest.Row(testAuthorId, "sam", testCreatedAt))
+		}}},
+		"POST /authors/": {{path: "/authors/", body: `{"username":"sam","password":"` + env.password + `"}`, status: 200, db: func(db *dbtest.DB) {
+			db.On("INSERT INTO authors").Rows(dbtest.Row(testAuthorId, testCreatedAt))
+		}}},
+		"GET /authors/{authorId}": {{path: "/authors/" + testAuthorId, status: 200, db: func(db *dbtest.DB) {
+			authorRows(db)
+			authorPostsRows(db)
+		}}},
+		"GET /posts/": {{path: "/posts/", status: 200, db: func(db *dbtest.DB) {
+			db.On("ORDER BY posts.updated_at DESC").
+				Rows(dbtest.Row("sam", testAuthorId, testCreatedAt, testPostId, "Hello", "Some *text*", testCreatedAt, testCreatedAt, []string{"go"}))
+		}}},
+		"POST /posts/": {{path: "/posts/", auth: true, body: `{"title":"Hello","body":"Some *text*","tags":["go"]}`, status: 200, db: writePostRows}},
+		"DELETE /posts/": {{path: "/posts/", auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("DELETE FROM posts WHERE author_id=$1 RETURNING post_id").Rows(dbtest.Row(testPostId))
+		}}},
+		"GET /posts/{postId}": {{path: "/posts/" + testPostId, status: 200, db: postRows}},
+		"PUT /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, body: `{"title":"Hello","body":"Other text","tags":["go"]}`, status: 200, db: func(db *dbtest.DB) {
+			writePostRows(db)
+			postRows(db)
+		}}},
+		"PATCH /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, header: map[string]string{"Content-Type": "application/merge-patch+json"},
+			body: `{"title":"Goodbye"}`, status: 200, db: func(db *dbtest.DB) {
+				writePostRows(db)
+				postRows(db)
+			}}},
+		"DELETE /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("DELETE FROM posts WHERE author_id=$1 AND post_id=$2")
+		}}},
+		"POST /login/": {{path: "/login/", body: `{"username":"sam","password":"` + env.password + `"}`, status: 200, db: env.loginRows}},
+	}
+
+	addFailures(cases, versionFailures(env, cases))
+
+	return cases
+}
+
+// specEnv's loginRows answers the queries of logging in sam with the password
+func (env *specEnv) loginRows(db *dbtest.DB) {
+	db.On("SELECT password FROM authors WHERE username=").Rows(dbtest.Row(env.hash))
+	db.On("SELECT author_id FROM authors WHERE username=").Rows(dbtest.Row(testAuthorId))
+	db.On("FROM author_totp").Rows(dbtest.Row(false))
+}
+
+// v2SpecCases returns the cases of every operation of v2Operations by "METHOD path", the
+// successful request first
+func v2SpecCases(env *specEnv) map[string][]specCase {
+	export := `{"version":1,"authors":[{"id":"1","username":"sam","created_at":"2024-03-01T12:00:00Z"}],` +
+		`"posts":[{"id":"1","title":"Hello","body":"Some *text*","tags":["go"],"author":"sam","created_at":"2024-03-01T12:00:00Z","updated_at":"2024-03-01T12:00:00Z"}]}`
+
+	// administrators are listed by ID, kim isn't one
+	otherToken, _ := helpers.CreateToken(otherAuthorId)
+
+	importForm := func(format string) func(*multipart.Writer) {
+		return func(w *multipart.Writer) {
+			w.WriteField("source", "old-blog")
+			w.WriteField("dry_run", "true")
+			w.WriteField("format", format)
+			file, _ := w.CreateFormFile("file", "export.json")
+			file.Write([]byte(export))
+		}
+	}
+
+	cases := map[string][]specCase{
+		"GET /authors/": {{path: "/authors/", status: 200, db: func(db *dbtest.DB) {
+			db.On("FROM authors ORDER BY username").Rows(dbtest.Row(testAuthorId, "sam", testCreatedAt))
+		}}},
+		"POST /authors/": {{path: "/authors/", body: `{"username":"sam","password":"` + env.password + `"}`, status: 201, db: func(db *dbtest.DB) {
+			db.On("INSERT INTO authors").Rows(dbtest.Row(testAuthorId, testCreatedAt))
+			authorRows(db)
+			db.On("FROM posts WHERE author_id=$1 ORDER BY created_at DESC")
+		}}},
+		"GET /authors/{authorId}": {{path: "/authors/" + testAuthorId, status: 200, db: func(db *dbtest.DB) {
+			authorRows(db)
+			authorPostsRows(db)
+			attachmentRows(db)
+		}}},
+		"GET /posts/": {{path: "/posts/", status: 200, db: func(db *dbtest.DB) {
+			db.On("ORDER BY posts.updated_at DESC").
+				Rows(dbtest.Row("sam", testAuthorId, testCreatedAt, testPostId, "Hello", "Some *text*", testCreatedAt, testCreatedAt, []string{"go"}))
+			attachmentRows(db)
+		}}},
+		"POST /posts/": {{path: "/posts/", auth: true, body: `{"title":"Hello","body":"Some *text*","tags":["go"]}`, status: 201, db: func(db *dbtest.DB) {
+			writePostRows(db)
+			postRows(db)
+		}}},
+		"DELETE /posts/": {{path: "/posts/", auth: true, status: 204, db: func(db *dbtest.DB) {
+			db.On("DELETE FROM posts WHERE author_id=$1 RETURNING post_id").Rows(dbtest.Row(testPostId))
+		}}},
+		"GET /posts/{postId}": {{path: "/posts/" + testPostId, status: 200, db: postRows}},
+		"PUT /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, body: `{"title":"Hello","body":"Other text","tags":["go"]}`, status: 200, db: func(db *dbtest.DB) {
+			writePostRows(db)
+			postRows(db)
+		}}},
+		"PATCH /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, header: map[string]string{"Content-Type": "application/merge-patch+json"},
+			body: `{"title":"Goodbye"}`, status: 200, db: func(db *dbtest.DB) {
+				writePostRows(db)
+				postRows(db)
+			}}},
+		"DELETE /posts/{postId}": {{path: "/posts/" + testPostId, auth: true, status: 204, db: func(db *dbtest.DB) {
+			db.On("DELETE FROM posts WHERE author_id=$1 AND post_id=$2")
+		}}},
+		"POST /login/": {{path: "/login/", body: `{"username":"sam","password":"` + env.password + `"}`, status: 200, db: env.loginRows}},
+		"POST /login/oidc": {
+			{path: "/login/oidc", newBody: env.oidcBody, status: 200, db: func(db *dbtest.DB) {
+				db.On("FROM author_identities WHERE issuer=").Rows(dbtest.Row(testAuthorId))
+				db.On("FROM author_totp").Rows(dbtest.Row(false))
+			}},
+			{name: "unknown code", path: "/login/oidc", status: 401,
+				body: `{"code":"unknown","code_verifier":"verifier-of-the-test-client","redirect_uri":"http://127.0.0.1:8400/callback"}`},
+			{name: "identity not linked", path: "/login/oidc", newBody: env.oidcBody, status: 403, db: func(db *dbtest.DB) {
+				db.On("FROM author_identities WHERE issuer=")
+			}},
+			{name: "no identity provider", path: "/login/oidc", newBody: env.oidcBody, status: 404, setup: withoutProvider},
+			{name: "no code", path: "/login/oidc", body: `{"code_verifier":"verifier-of-the-test-client","redirect_uri":"http://127.0.0.1:8400/callback"}`, status: 422},
+		},
+		"GET /admin/export": {
+			{path: "/admin/export", auth: true, status: 200, db: exportRows},
+			{name: "not an administrator", path: "/admin/export", auth: true, header: map[string]string{"token": otherToken}, status: 403},
+			{name: "unknown format", path: "/admin/export?format=rar", auth: true, status: 422},
+		},
+		"POST /admin/import": {
+			{path: "/admin/import", auth: true, status: 200, form: importForm(""), db: func(db *dbtest.DB) {
+				db.On("FROM post_imports")
+				db.On("SELECT author_id FROM authors WHERE username=").Rows(dbtest.Row(testAuthorId))
+			}},
+			{name: "file too large", path: "/admin/import", auth: true, status: 413, form: importForm(""), setup: func(t *testing.T) {
+				t.Setenv("GOBLOG_MAX_IMPORT_BYTES", "10")
+			}},
+			{name: "unknown format", path: "/admin/import", auth: true, status: 422, form: importForm("csv")},
+		},
+	}
+
+	addFailures(cases, versionFailures(env, cases))
+
+	return cases
+}
+
+// versionFailures returns the failures of the operations on authors, posts and logins, which
+// every version answers with the same problems
+func versionFailures(env *specEnv, cases map[string][]specCase) map[string][]specCase {
+	createAuthor, put, patch, remove := cases["POST /authors/"][0], cases["PUT /posts/{postId}"][0], cases["PATCH /posts/{postId}"][0], cases["DELETE /posts/{postId}"][0]
+
+	return map[string][]specCase{
+		"POST /authors/": {
+			createAuthor.with("username taken", 409, func(c *specCase) {
+				c.db = func(db *dbtest.DB) {
+					db.On("INSERT INTO authors").Err(&pq.Error{Code: "23505", Constraint: "authors_username_key"})
+				}
+			}),
+			createAuthor.with("short password", 422, func(c *specCase) { c.body = `{"username":"sam","password":"short"}` }),
+		},
+		"GET /authors/{authorId}": {{name: "unknown author", path: "/authors/" + otherAuthorId, status: 404, db: func(db *dbtest.DB) {
+			db.On("SELECT username, created_at FROM authors WHERE author_id=")
+		}}},
+		"POST /posts/": {{name: "no title", path: "/posts/", auth: true, body: `{"title":" ","body":"Some *text*"}`, status: 422}},
+		"GET /posts/{postId}": {{name: "unknown post", path: "/posts/" + testPostId, status: 404, db: func(db *dbtest.DB) {
+			db.On("WHERE posts.post_id=$1")
+		}}},
+		"PUT /posts/{postId}": {
+			put.with("post of another author", 403, func(c *specCase) { c.db = missedPostRows(false, otherAuthorId) }),
+			put.with("unknown post", 404, func(c *specCase) { c.db = missedPostRows(false, "") }),
+			put.with("modified since", 412, func(c *specCase) {
+				c.header["If-Match"] = `"1"`
+				c.db = missedPostRows(true, testAuthorId)
+			}),
+			put.with("no title", 422, func(c *specCase) { c.body = `{"title":"","body":"Other text"}` }),
+		},
+		"PATCH /posts/{postId}": {
+			patch.with("post of another author", 403, func(c *specCase) { c.db = otherPostRows }),
+			patch.with("unknown post", 404, func(c *specCase) {
+				c.db = func(db *dbtest.DB) { db.On("WHERE posts.post_id=$1") }
+			}),
+			patch.with("modified since", 412, func(c *specCase) { c.header["If-Match"] = `"7"` }),
+			patch.with("no title", 422, func(c *specCase) { c.body = `{"title":null}` }),
+		},
+		"DELETE /posts/{postId}": {
+			remove.with("post of another author", 403, func(c *specCase) { c.db = missedPostRows(false, otherAuthorId) }),
+			remove.with("unknown post", 404, func(c *specCase) { c.db = missedPostRows(false, "") }),
+			remove.with("modified since", 412, func(c *specCase) {
+				c.header["If-Match"] = `"1"`
+				c.db = missedPostRows(true, testAuthorId)
+			}),
+		},
+		"POST /login/": {
+			{name: "wrong password", path: "/login/", body: `{"username":"sam","password":"wrong password"}`, status: 401, db: env.loginRows},
+			{name: "no username", path: "/login/", body: `{"password":"wrong password"}`, status: 422},
+		},
+	}
+}
+
+// addFailures appends the cases of failures to the cases of the same operations
+func addFailures(cases, failures map[string][]specCase) {
+	for name, failed := range failures {
+		cases[name] = append(cases[name], failed...)
+	}
+}
+
+// withoutProvider turns single sign-on off until the end of the test
+func withoutProvider(t *testing.T) {
+	previous := oidc.Default
+	oidc.Default = nil
+	t.Cleanup(func() { oidc.Default = previous })
+}
+
+// sharedSpecCases returns the cases of every operation of sharedOperations by "METHOD path", the
+// successful request first
+func sharedSpecCases(env *specEnv) map[string][]specCase {
+	webhookPath := "/account/webhooks/" + testWebhookId
+
+	return map[string][]specCase{
+		"POST /account/email": {
+			{path: "/account/email", auth: true, body: `{"email":"sam@example.com"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("UPDATE authors SET email=").Rows(dbtest.Row(testAuthorId))
+				db.On("SELECT email, email_verified_at FROM authors").Rows(dbtest.Row("sam@example.com", nil))
+				db.On("INSERT INTO author_tokens")
+			}},
+			{name: "email taken", path: "/account/email", auth: true, body: `{"email":"kim@example.com"}`, status: 409, db: func(db *dbtest.DB) {
+				db.On("UPDATE authors SET email=").Err(&pq.Error{Code: "23505", Constraint: "authors_email_key"})
+			}},
+			{name: "invalid email", path: "/account/email", auth: true, body: `{"email":"sam"}`, status: 422},
+		},
+		"POST /account/verify": {
+			{path: "/account/verify", body: `{"token":"` + env.signedToken("verify_email") + `"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("WHERE token_hash=$1").Rows(dbtest.Row(testAuthorId, "sam@example.com"))
+				db.On("UPDATE authors SET email_verified_at").Rows(dbtest.Row(testAuthorId))
+			}},
+			{name: "no token", path: "/account/verify", body: `{}`, status: 422},
+		},
+		"POST /account/forgot": {
+			{path: "/account/forgot", body: `{"username":"sam"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("SELECT author_id, email FROM authors").Rows(dbtest.Row(testAuthorId, "sam@example.com"))
+				db.On("INSERT INTO author_tokens")
+			}},
+			{name: "no username", path: "/account/forgot", body: `{}`, status: 422},
+		},
+		"POST /account/reset": {
+			{path: "/account/reset", body: `{"token":"` + env.signedToken("reset_password") + `","password":"another good password"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("WHERE token_hash=$1").Rows(dbtest.Row(testAuthorId, ""))
+				db.On("UPDATE authors SET password=").Rows(dbtest.Row(testAuthorId))
+				db.On("UPDATE author_tokens SET used_at")
+			}},
+			{name: "short password", path: "/account/reset", body: `{"token":"` + env.signedToken("reset_password") + `","password":"short"}`, status: 422},
+		},
+		"POST /account/totp": {
+			{path: "/account/totp", auth: true, status: 200, db: func(db *dbtest.DB) {
+				db.On("SELECT EXISTS").Rows(dbtest.Row(false))
+				db.On("SELECT username FROM authors WHERE author_id").Rows(dbtest.Row("sam"))
+				db.On("INSERT INTO author_totp")
+			}},
+			{name: "enabled already", path: "/account/totp", auth: true, status: 409, db: func(db *dbtest.DB) {
+				db.On("SELECT EXISTS").Rows(dbtest.Row(true))
+			}},
+		},
+		"POST /account/totp/confirm": {
+			{path: "/account/totp/confirm", auth: true, body: `{"otp":"` + env.otp() + `"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("SELECT secret, confirmed_at FROM author_totp").Rows(dbtest.Row(env.secret, nil))
+				db.On("UPDATE author_totp")
+				db.On("author_recovery_codes")
+			}},
+			{name: "not enrolled", path: "/account/totp/confirm", auth: true, body: `{"otp":"123456"}`, status: 409, db: func(db *dbtest.DB) {
+				db.On("SELECT secret, confirmed_at FROM author_totp")
+			}},
+			{name: "wrong code", path: "/account/totp/confirm", auth: true, body: `{"otp":"abc"}`, status: 422, db: func(db *dbtest.DB) {
+				db.On("SELECT secret, confirmed_at FROM author_totp").Rows(dbtest.Row(env.secret, nil))
+			}},
+		},
+		"DELETE /account/totp": {
+			{path: "/account/totp", auth: true, body: `{"otp":"` + env.otp() + `"}`, status: 200, db: func(db *dbtest.DB) {
+				db.On("RETURNING secret").Rows(dbtest.Row(env.secret))
+				db.On("UPDATE author_totp")
+				db.On("author_recovery_codes")
+				db.On("DELETE FROM author_totp")
+			}},
+			{name: "not enabled", path: "/account/totp", auth: true, body: `{"otp":"123456"}`, status: 409, db: func(db *dbtest.DB) {
+				db.On("RETURNING secret")
+				db.On("SELECT EXISTS").Rows(dbtest.Row(false))
+			}},
+			{name: "no code", path: "/account/totp", auth: true, body: `{"otp":""}`, status: 422},
+		},
+		"POST /account/oidc": {
+			{path: "/account/oidc", auth: true, newBody: env.oidcBody, status: 200, db: func(db *dbtest.DB) {
+				db.On("INSERT INTO author_identities").Rows(dbtest.Row(testAuthorId))
+			}},
+			{name: "no identity provider", path: "/account/oidc", auth: true, newBody: env.oidcBody, status: 404, setup: withoutProvider},
+			{name: "identity of another author", path: "/account/oidc", auth: true, newBody: env.oidcBody, status: 409, db: func(db *dbtest.DB) {
+				db.On("INSERT INTO author_identities").Rows(dbtest.Row(otherAuthorId))
+			}},
+			{name: "no code", path: "/account/oidc", auth: true, body: `{"code_verifier":"verifier-of-the-test-client","redirect_uri":"http://127.0.0.1:8400/callback"}`, status: 422},
+		},
+		"DELETE /account/oidc": {
+			{path: "/account/oidc", auth: true, status: 200, db: func(db *dbtest.DB) {
+				db.On("DELETE FROM author_identities")
+			}},
+			{name: "no identity linked", path: "/account/oidc", auth: true, status: 404, db: func(db *dbtest.DB) {
+				db.On("DELETE FROM author_identities").Affected(0)
+			}},
+		},
+		"GET /account/keys": {{path: "/account/keys", auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("FROM api_keys WHERE author_id=").Rows(dbtest.Row(testKeyId, "ci", "gb_abcd", []string{"read"}, testCreatedAt, nil))
+		}}},
+		"POST /account/keys": {
+			{path: "/account/keys", auth: true, body: `{"name":"ci","scopes":["read"]}`, status: 201, db: func(db *dbtest.DB) {
+				db.On("INSERT INTO api_keys").Rows(dbtest.Row(testCreatedAt))
+			}},
+			{name: "unknown scope", path: "/account/keys", auth: true, body: `{"name":"ci","scopes":["everything"]}`, status: 422},
+		},
+		"DELETE /account/keys/{keyId}": {{path: "/account/keys/" + testKeyId, auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("UPDATE api_keys SET revoked_at")
+		}}},
+		"GET /account/webhooks": {{path: "/account/webhooks", auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("FROM webhooks WHERE author_id=").Rows(dbtest.Row(testWebhookId, "https://example.com/hook", []string{"post.created"}, testCreatedAt))
+		}}},
+		"POST /account/webhooks": {
+			{path: "/account/webhooks", auth: true, body: `{"url":"https://example.com/hook","events":["post.created"]}`, status: 201, db: func(db *dbtest.DB) {
+				db.On("INSERT INTO webhooks").Rows(dbtest.Row(testCreatedAt))
+			}},
+			{name: "relative URL", path: "/account/webhooks", auth: true, body: `{"url":"/hook","events":["post.created"]}`, status: 422},
+		},
+		"DELETE /account/webhooks/{webhookId}": {{path: webhookPath, auth: true, status: 200, db: func(db *dbtest.DB) {
+			db.On("DELETE FROM webhooks")
+		}}},
+		"GET /account/webhooks/{webhookId}/deliveries": {{path: webhookPath + "/deliveries", auth: true, status: 200, db: func(db *dbtest.DB) {
+			webhookRows(db)
+			db.On("FROM webhook_deliveries").Rows(dbtest.Row(deliveryRow("succeeded")...))
+		}}},
+		"POST /account/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver": {{path: webhookPath + "/deliveries/" + testDeliveryId + "/redeliver", auth: true, status: 202, db: func(db *dbtest.DB) {
+			webhookRows(db)
+			db.On("INSERT INTO webhook_deliveries").Rows(dbtest.Row(deliveryRow("pending")...))
+		}}},
+		"GET /authors/{authorId}/export": {
+			{path: "/authors/" + testAuthorId + "/export", auth: true, status: 200, db: exportRows},
+			{name: "unknown format", path: "/authors/" + testAuthorId + "/export?format=rar", auth: true, status: 422},
+		},
+		"POST /uploads": {
+			{path: "/uploads", auth: true, status: 200, form: pngForm, db: func(db *dbtest.DB) {
+				db.On("INSERT INTO attachments").Rows(dbtest.Row(testCreatedAt))
+			}},
+			{name: "post of another author", path: "/uploads", auth: true, status: 403, form: func(w *multipart.Writer) {
+				w.WriteField("post_id", testPostId)
+				pngForm(w)
+			}, db: otherPostRows},
+			{name: "file too large", path: "/uploads", auth: true, status: 413, form: pngForm, setup: func(t *testing.T) {
+				t.Setenv("GOBLOG_MAX_UPLOAD_BYTES", "10")
+			}},
+			{name: "no file", path: "/uploads", auth: true, status: 422, form: func(w *multipart.Writer) {
+				w.WriteField("post_id", testPostId)
+			}},
+		},
+	}
+}
+
+// problemCases returns cases of the problems every operation like op can respond with, made from
+// its successful request success
+func problemCases(env *specEnv, op operation, success specCase) []specCase {
+	cases := []specCase{
+		success.with("unknown API key", 401, func(c *specCase) {
+			c.auth = false
+			c.header["X-API-Key"] = env.apiKey
+			c.db = func(db *dbtest.DB) { db.On("FROM api_keys WHERE key_hash=") }
+		}),
+		success.with("API key without scopes", 403, func(c *specCase) {
+			c.auth = false
+			c.header["X-API-Key"] = env.apiKey
+			c.db = func(db *dbtest.DB) {
+				db.On("FROM api_keys WHERE key_hash=").Rows(dbtest.Row(testKeyId, testAuthorId, []string{}, time.Now()))
+			}
+		}),
+		success.with("rate limited", 429, func(c *specCase) {
+			c.limited = true
+			c.db = nil
+		}),
+	}
+
+	if success.db != nil {
+		cases = append(cases, success.with("database failing", 500, func(c *specCase) {
+			c.db = func(db *dbtest.DB) { db.On("").Err(errors.New("connection refused")) }
+		}))
+	}
+
+	if op.Auth {
+		cases = append(cases, success.with("not logged in", 401, func(c *specCase) {
+			c.auth = false
+			c.db = nil
+		}))
+	}
+
+	if strings.Contains(op.Path, "{") {
+		cases = append(cases, success.with("invalid ID", 404, func(c *specCase) {
+			c.path = invalidIds.Replace(c.path)
+			c.db = nil
+		}))
+	}
+
+	if op.Body == nil && !op.Multipart {
+		return cases
+	}
+
+	// bodies are read after the checks of the successful request, which may need the database
+	cases = append(cases,
+		success.with("malformed body", 400, func(c *specCase) {
+			c.form, c.newBody, c.body = nil, nil, "{"
+
+			if op.Multipart {
+				c.header["Content-Type"], c.body = "multipart/form-data; boundary=x", "--x\r\nnot a part"
+			}
+		}),
+		success.with("plain text body", 415, func(c *specCase) {
+			c.form, c.newBody, c.body = nil, nil, "hello"
+			c.header["Content-Type"] = "text/plain"
+		}),
+	)
+
+	// multipart bodies are limited by their own settings
+	if !op.Multipart {
+		cases = append(cases, success.with("body too large", 413, func(c *specCase) {
+			c.newBody, c.body = nil, `{"title":"`+strings.Repeat("a", 1<<20)+`"}`
+		}))
+	}
+
+	return cases
+}
+
+// Every documented operation is called, successfully and with every documented problem, and its
+// responses are checked against the OpenAPI document, so that handlers and the operation tables
+// can't drift apart
+func TestOperationsMatchSpec(t *testing.T) {
+	env := newSpecEnv(t)
+	handler := NewApiHandler()
+	handler.RateLimiter = nil
+	limited := NewApiHandler()
+	limited.RateLimiter = &RateLimiter{Store: ratelimit.NewMemoryStore(), Rules: []RateLimitRule{{Method: "*", Path: "/*", Window: time.Minute}}}
+	checker := &SpecChecker{Spec: NewSpec()}
+
+	tables := []struct {
+		version    string
+		operations []operation
+		cases      func(*specEnv) map[string][]specCase
+	}{
+		{"v1", v1Operations, v1SpecCases},
+		{"v1", sharedOperations, sharedSpecCases},
+		{"v2", v2Operations, v2SpecCases},
+		{"v2", sharedOperations, sharedSpecCases},
+	}
+
+	for _, table := range tables {
+		cases := table.cases(env)
+
+		for _, op := range table.operations {
+			name := op.Method + " " + op.Path
+
+			if len(cases[name]) == 0 {
+				t.Errorf("%s %s: no case", table.version, name)
+				continue
+			}
+
+			covered := map[int]bool{}
+
+			for _, c := range append(cases[name], problemCases(env, op, cases[name][0])...) {
+				covered[c.status] = true
+
+				t.Run(strings.TrimSpace(table.version+" "+name+" "+strconv.Itoa(c.status)+" "+c.name), func(t *testing.T) {
+					db := dbtest.Open(t)
+
+					if c.db != nil {
+						c.db(db)
+					}
+
+					if c.setup != nil {
+						c.setup(t)
+					}
+
+					req := newSpecRequest(t, op.Method, "/"+table.version+c.path, c)
+					res := httptest.NewRecorder()
+
+					if c.limited {
+						limited.ServeHTTP(res, req)
+					} else {
+						handler.ServeHTTP(res, req)
+					}
+
+					if res.Code != c.status {
+						t.Errorf("status %d, want %d: %s", res.Code, c.status, res.Body)
+
+						for _, query := range db.Queries() {
+							t.Logf("ran %s", strings.Join(strings.Fields(query.SQL), " "))
+						}
+					}
+
+					path, _, _ := strings.Cut("/api/"+table.version+c.path, "?")
+
+					for _, divergence := range checker.Check(op.Method, path, res.Code, res.Header(), res.Body.Bytes()) {
+						t.Errorf("diverges from the spec: %s", divergence)
+					}
+				})
+			}
+
+			// 405 is only answered to the methods that aren't documented
+			for _, status := range problemStatuses(op) {
+				if status != http.StatusMethodNotAllowed && !covered[status] {
+					t.Errorf("%s %s: no case of the documented status %d", table.version, name, status)
+				}
+			}
+		}
+	}
+}
+
+// newSpecRequest returns the request of c, path is below /api
+func newSpecRequest(t *testing.T, method, path string, c specCase) *http.Request {
+	var (
+		body        bytes.Buffer
+		contentType = "application/json"
+	)
+
+	switch {
+	case c.form != nil:
+		w := multipart.NewWriter(&body)
+		c.form(w)
+		w.Close()
+		contentType = w.FormDataContentType()
+	case c.newBody != nil:
+		body.WriteString(c.newBody())
+	default:
+		body.WriteString(c.body)
+	}
+
+	req := httptest.NewRequest(method, path, &body)
+
+	if body.Len() > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.auth {
+		token, err := helpers.CreateToken(testAuthorId)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("token", token)
+	}
+
+	for name, value := range c.header {
+		req.Header.Set(name, value)
+	}
+
+	return req
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/ratelimit"
+)
+
+// limit of the requests of a client to the routes matching a method and a path
+type RateLimitRule struct {
+	Method string        // HTTP method, "*" for any
+	Path   string        // path below <base>/api/:version, a trailing "*" matches any rest
+	Limit  int           // requests allowed per window
+	Window time.Duration // length of the window
+}
+
+// RateLimitRule's matches reports if the rule applies to a request
+func (r RateLimitRule) matches(method, path string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+
+	if strings.HasSuffix(r.Path, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(r.Path, "*"))
+	}
+
+	return strings.TrimSuffix(path, "/") == strings.TrimSuffix(r.Path, "/")
+}
+
+// rules applied unless overridden by GOBLOG_RATE_LIMITS, the first matching rule applies
+var defaultRateLimits = []RateLimitRule{
+	{Method: "POST", Path: "/login/", Limit: 10, Window: time.Minute},
+	{Method: "POST", Path: "/login/oidc", Limit: 10, Window: time.Minute},
+	{Method: "POST", Path: "/account/*", Limit: 10, Window: time.Minute},
+	{Method: "POST", Path: "/uploads", Limit: 20, Window: time.Minute},
+	{Method: "GET", Path: "/posts/", Limit: 60, Window: time.Minute},
+	{Method: "GET", Path: "/*", Limit: 300, Window: time.Minute},
+	{Method: "*", Path: "/*", Limit: 60, Window: time.Minute},
+}
+
+// RateLimiter limits the requests of each client, identified by its API key, the author ID of its
+// token or else by its IP address
+type RateLimiter struct {
+	Store      ratelimit.Store
+	Rules      []RateLimitRule
+	TrustProxy bool // whether the client IP is taken from X-Forwarded-For set by a reverse proxy
+}
+
+// NewRateLimiter returns the rate limiter using ratelimit.Default and the rules of
+// GOBLOG_RATE_LIMITS before the default ones, nil if rate limiting is disabled
+//
+// GOBLOG_RATE_LIMITS is a ";" separated list of "METHOD PATH=LIMIT/WINDOW" rules, e.g.
+// "GET /posts/=30/1m;POST /*=100/1h".
+func NewRateLimiter() *RateLimiter {
+	if ratelimit.Default == nil {
+		return nil
+	}
+
+	limiter := &RateLimiter{Store: ratelimit.Default}
+
+	for _, entry := range strings.Split(os.Getenv("GOBLOG_RATE_LIMITS"), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		rule, err := parseRateLimitRule(entry)
+
+		if err != nil {
+			log.Fatalf("invalid GOBLOG_RATE_LIMITS rule %q: %v", entry, err)
+		}
+
+		limiter.Rules = append(limiter.Rules, rule)
+	}
+
+	limiter.Rules = append(limiter.Rules, defaultRateLimits...)
+	limiter.TrustProxy, _ = strconv.ParseBool(os.Getenv("GOBLOG_TRUST_PROXY"))
+
+	return limiter
+}
+
+// parseRateLimitRule parses a "METHOD PATH=LIMIT/WINDOW" rule
+func parseRateLimitRule(entry string) (RateLimitRule, error) {
+	route, quota, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	fields := strings.Fields(route)
+	limit, window, _ := strings.Cut(quota, "/")
+
+	if !ok || len(fields) != 2 {
+		return RateLimitRule{}, errInvalidRule
+	}
+
+	rule := RateLimitRule{Method: strings.ToUpper(fields[0]), Path: fields[1]}
+
+	var err error
+
+	if rule.Limit, err = strconv.Atoi(strings.TrimSpace(limit)); err != nil || rule.Limit < 0 {
+		return RateLimitRule{}, errInvalidRule
+	}
+
+	if rule.Window, err = time.ParseDuration(strings.TrimSpace(window)); err != nil || rule.Window <= 0 {
+		return RateLimitRule{}, errInvalidRule
+	}
+
+	return rule, nil
+}
+
+var errInvalidRule = errors.New(`expected "METHOD PATH=LIMIT/WINDOW"`)
+
+// RateLimiter's Allow counts a request and sets the RateLimit headers of its response, if the
+// client exceeded its limit a 429 problem has been sent and false is returned
+//
+// path is the path below <base>/api/:version. Requests are allowed if the store fails.
+func (l *RateLimiter) Allow(res http.ResponseWriter, req *http.Request, path string) bool {
+	var rule RateLimitRule
+
+	found := false
+
+	for _, candidate := range l.Rules {
+		if candidate.matches(req.Method, path) {
+			rule, found = candidate, true
+			break
+		}
+	}
+
+	if !found {
+		return true
+	}
+
+	key := l.client(req) + " " + rule.Method + " " + rule.Path
+	result, err := l.Store.Take(key, rule.Limit, rule.Window)
+
+	if err != nil {
+		log.Printf("rate limiting %s: %v", key, err)
+		return true
+	}
+
+	reset := strconv.Itoa(int((result.Reset + time.Second - 1) / time.Second))
+	header := res.Header()
+
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", reset)
+	header.Set("RateLimit-Policy", strconv.Itoa(rule.Limit)+";w="+strconv.Itoa(int(rule.Window/time.Second)))
+
+	if !result.Allowed {
+		header.Set("Retry-After", reset)
+		helpers.ProblemResponse(res, helpers.NewProblem(http.StatusTooManyRequests, helpers.CodeRateLimited, "Too many requests, try again in "+reset+" seconds!"))
+		return false
+	}
+
+	return true
+}
+
+// RateLimiter's client returns the key identifying the client of req
+func (l *RateLimiter) client(req *http.Request) string {
+	// every key of an author gets its own limits
+	if keyId := helpers.GetAPIKeyId(req); keyId != "" {
+		return "key:" + keyId
+	}
+
+	if authorId := helpers.GetAuthorIdFromHeader(req); authorId != "" {
+		return "author:" + authorId
+	}
+
+	// the last address is the one the proxy appended, the ones before are sent by the client
+	if l.TrustProxy {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addresses := strings.Split(forwarded, ",")
+
+			return "ip:" + strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	return "ip:" + host
+}
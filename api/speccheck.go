@@ -0,0 +1,271 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SpecChecker compares every API response with the OpenAPI document and reports the ones that
+// diverge from it, so handlers and spec can't drift apart unnoticed
+//
+// Enabled with GOBLOG_OPENAPI_CHECK=log, which logs divergences, the API's tests check every
+// documented operation with it.
+type SpecChecker struct {
+	Spec map[string]interface{}
+}
+
+// NewSpecChecker returns the checker configured by GOBLOG_OPENAPI_CHECK, nil if checking is disabled
+func NewSpecChecker(spec map[string]interface{}) *SpecChecker {
+	switch os.Getenv("GOBLOG_OPENAPI_CHECK") {
+	case "log":
+		return &SpecChecker{Spec: spec}
+	default:
+		return nil
+	}
+}
+
+// Handler returns h with its responses checked against the operations documented under path
+func (c *SpecChecker) Handler(path string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		recorder := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+
+		h.ServeHTTP(recorder, req)
+
+		divergences := c.Check(req.Method, path, recorder.status, recorder.header, recorder.body.Bytes())
+
+		if len(divergences) > 0 {
+			log.Printf("openapi: %s %s -> %d diverges from the spec: %s", req.Method, path, recorder.status, strings.Join(divergences, "; "))
+		}
+
+		for key, values := range recorder.header {
+			res.Header()[key] = values
+		}
+
+		res.WriteHeader(recorder.status)
+		res.Write(recorder.body.Bytes())
+	})
+}
+
+// Check returns how a response diverges from the OpenAPI document, nothing if it conforms
+func (c *SpecChecker) Check(method, path string, status int, header http.Header, body []byte) []string {
+	item := c.pathItem(path)
+
+	// unknown routes may only be answered with 404, unknown methods with 405
+	if item == nil {
+		if status != http.StatusNotFound {
+			return []string{"undocumented path"}
+		}
+
+		return nil
+	}
+
+	op, ok := item[strings.ToLower(method)].(map[string]interface{})
+
+	if !ok {
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotFound {
+			return []string{"undocumented method"}
+		}
+
+		return nil
+	}
+
+	response, ok := op["responses"].(map[string]interface{})[strconv.Itoa(status)].(map[string]interface{})
+
+	if !ok {
+		return []string{"undocumented status " + strconv.Itoa(status)}
+	}
+
+	content, ok := response["content"].(map[string]interface{})
+
+	if !ok {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return []string{"body sent for a response documented without one"}
+		}
+
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := content[mediaType].(map[string]interface{})
+
+	if !ok {
+		return []string{"undocumented content type " + strconv.Quote(mediaType)}
+	}
+
+	// files, e.g. exports
+	if !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{"invalid JSON: " + err.Error()}
+	}
+
+	return c.validate(media["schema"].(map[string]interface{}), value, "body")
+}
+
+// pathItem returns the path item matching path, unversioned paths are looked up in v1
+func (c *SpecChecker) pathItem(path string) map[string]interface{} {
+	segments := splitPath(path)
+
+	if len(segments) > 1 && segments[1] != "v1" && segments[1] != "v2" {
+		segments = append([]string{segments[0], "v1"}, segments[1:]...)
+	}
+
+	for template, item := range c.Spec["paths"].(map[string]map[string]interface{}) {
+		if matchPath(splitPath(template), segments) {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// splitPath returns the non-empty segments of path
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+// matchPath reports if segments match the template segments, {name} matches any segment
+func matchPath(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+
+	for i := range template {
+		if !strings.HasPrefix(template[i], "{") && template[i] != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate returns how value diverges from schema, at is the location of value for messages
+func (c *SpecChecker) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	if reference, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(reference, "#/components/schemas/")
+		schemas := c.Spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+		return c.validate(schemas[name].(map[string]interface{}), value, at)
+	}
+
+	if expected, ok := schema["const"]; ok && value != expected {
+		return []string{fmt.Sprintf("%s is %v instead of %v", at, value, expected)}
+	}
+
+	var allowed []string
+
+	switch kind := schema["type"].(type) {
+	case string:
+		allowed = []string{kind}
+	case []string:
+		allowed = kind
+	default:
+		// any value
+		return nil
+	}
+
+	actual := jsonType(value)
+
+	if !typeAllowed(actual, allowed) {
+		return []string{fmt.Sprintf("%s is %s instead of %s", at, actual, strings.Join(allowed, " or "))}
+	}
+
+	var divergences []string
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]string)
+
+		for _, name := range required {
+			if _, ok := value[name]; !ok {
+				divergences = append(divergences, at+"."+name+" is missing")
+			}
+		}
+
+		for name, property := range value {
+			propertySchema, ok := properties[name].(map[string]interface{})
+
+			if !ok {
+				if schema["additionalProperties"] == false {
+					divergences = append(divergences, at+"."+name+" is undocumented")
+				}
+
+				continue
+			}
+
+			divergences = append(divergences, c.validate(propertySchema, property, at+"."+name)...)
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+
+		for index, item := range value {
+			divergences = append(divergences, c.validate(items, item, at+"["+strconv.Itoa(index)+"]")...)
+		}
+	}
+
+	return divergences
+}
+
+// jsonType returns the JSON schema type of a value decoded by encoding/json
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// typeAllowed reports if the JSON schema type actual is one of allowed, integers are numbers too
+func typeAllowed(actual string, allowed []string) bool {
+	for _, kind := range allowed {
+		if kind == actual || (kind == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// responseRecorder buffers a response so it can be checked before being sent
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samkit-jain/go-blog/events"
+	"github.com/samkit-jain/go-blog/helpers"
+)
+
+// number of events kept for clients resuming with Last-Event-ID
+const streamLogSize = 1000
+
+// time between comments keeping idle connections open through proxies
+const streamHeartbeat = 15 * time.Second
+
+// StreamHandler sends post events to clients as Server-Sent Events
+//
+// Events of the last streamLogSize posts changes are kept, so that clients reconnecting with
+// Last-Event-ID get the ones they missed. Only events of this instance are streamed.
+type StreamHandler struct {
+	mu      sync.Mutex
+	log     []events.Event // oldest first
+	clients map[chan events.Event]bool
+}
+
+// NewStreamHandler returns a stream handler subscribed to the post events of bus
+func NewStreamHandler(bus *events.Bus) *StreamHandler {
+	h := &StreamHandler{clients: map[chan events.Event]bool{}}
+
+	bus.Subscribe(h.publish)
+
+	return h
+}
+
+// StreamHandler's publish logs a post event and passes it to the connected clients, clients too
+// slow to keep up are disconnected and resume with Last-Event-ID
+func (h *StreamHandler) publish(event events.Event) {
+	if !strings.HasPrefix(event.Type, "post.") {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.log) == streamLogSize {
+		copy(h.log, h.log[1:])
+		h.log = h.log[:streamLogSize-1]
+	}
+
+	h.log = append(h.log, event)
+
+	for client := range h.clients {
+		select {
+		case client <- event:
+		default:
+			delete(h.clients, client)
+			close(client)
+		}
+	}
+}
+
+// StreamHandler's subscribe registers a client and returns the logged events after lastEventId,
+// all logged events if lastEventId is unknown, none if it's empty
+func (h *StreamHandler) subscribe(lastEventId string) (chan events.Event, []events.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client := make(chan events.Event, 64)
+	h.clients[client] = true
+
+	if lastEventId == "" {
+		return client, nil
+	}
+
+	for index, event := range h.log {
+		if event.Id == lastEventId {
+			return client, append([]events.Event(nil), h.log[index+1:]...)
+		}
+	}
+
+	return client, append([]events.Event(nil), h.log...)
+}
+
+// StreamHandler's unsubscribe removes a client that disconnected
+func (h *StreamHandler) unsubscribe(client chan events.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client)
+	}
+}
+
+// StreamHandler's ServeHTTP streams post.created, post.updated and post.deleted events
+//
+// GET	<base>/api/stream
+func (h *StreamHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "GET" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	controller := http.NewResponseController(res)
+
+	// browsers send Last-Event-ID when reconnecting, the query parameter is for the first connection
+	lastEventId := req.Header.Get("Last-Event-ID")
+
+	if lastEventId == "" {
+		lastEventId = req.URL.Query().Get("last_event_id")
+	}
+
+	client, missed := h.subscribe(lastEventId)
+	defer h.unsubscribe(client)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	// clients wait this long before reconnecting
+	fmt.Fprint(res, "retry: 3000\n\n")
+
+	for _, event := range missed {
+		writeEvent(res, event)
+	}
+
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-client:
+			if !ok {
+				return
+			}
+
+			writeEvent(res, event)
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": heartbeat\n\n")
+		case <-req.Context().Done():
+			return
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes event in the text/event-stream format, its data is the event's JSON
+func writeEvent(res http.ResponseWriter, event events.Event) {
+	data, err := json.Marshal(event.Data)
+
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// default largest accepted upload in bytes, overridden by GOBLOG_MAX_UPLOAD_BYTES
+const defaultMaxUploadSize = 10 << 20
+
+// largest side of generated thumbnails in pixels
+const thumbnailSize = 320
+
+// accepted (sniffed) MIME types of uploads and the extension they're stored with
+var uploadTypes = map[string]string{
+	"image/png":                 ".png",
+	"image/jpeg":                ".jpg",
+	"image/gif":                 ".gif",
+	"image/webp":                ".webp",
+	"application/pdf":           ".pdf",
+	"application/zip":           ".zip",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+// image types thumbnails are generated for
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// Handler for <base>/api/uploads call
+type UploadHandler struct {
+}
+
+// UploadHandler's ServeHTTP stores a file uploaded as the multipart field "file" and optionally
+// links it to one of the logged in author's posts given as "post_id"
+//
+// POST	<base>/api/uploads
+func (h *UploadHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if req.URL.Path != "/" {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "POST" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	maxSize := int64(defaultMaxUploadSize)
+
+	if value, err := strconv.ParseInt(os.Getenv("GOBLOG_MAX_UPLOAD_BYTES"), 10, 64); err == nil {
+		maxSize = value
+	}
+
+	// leaving room for the multipart boundaries and other fields
+	helpers.LimitBody(res, req, maxSize+1<<20)
+
+	file, header, err := req.FormFile("file")
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &tooLarge):
+			helpers.RequestEntityTooLargeResponse(res, "File too large!")
+		case err == http.ErrNotMultipart:
+			helpers.UnsupportedMediaTypeResponse(res, "Content-Type must be multipart/form-data!")
+		case err == http.ErrMissingFile:
+			helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "file", Code: "required", Message: "Multipart field \"file\" is required"}})
+		default:
+			helpers.BadRequestResponse(res, "Malformed form!")
+		}
+
+		return
+	}
+
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(file, maxSize+1))
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	if int64(len(data)) > maxSize {
+		helpers.RequestEntityTooLargeResponse(res, "File too large!")
+		return
+	}
+
+	// trusting the content, not the client's Content-Type
+	contentType := http.DetectContentType(data)
+	extension, ok := uploadTypes[contentType]
+
+	if !ok {
+		helpers.UnsupportedMediaTypeResponse(res, "Files of type "+contentType+" are not allowed!")
+		return
+	}
+
+	postId := req.FormValue("post_id")
+
+	if postId != "" {
+		post, err := models.GetPostById(postId)
+
+		if err == sql.ErrNoRows {
+			helpers.ValidationErrorResponse(res, []types.FieldError{{Field: "post_id", Code: "not_found", Message: "Post does not exist"}})
+			return
+		}
+
+		if err == nil && post.AuthorInfo.AuthorId != authorId {
+			helpers.ForbiddenResponse(res, "You don't have write access to the post!")
+			return
+		}
+
+		if err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+	}
+
+	// content addressed keys, identical uploads share a blob
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	attachment := types.Attachment{
+		PostId:      postId,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Key:         hash[:2] + "/" + hash + extension,
+	}
+
+	if err = storage.Default.Put(attachment.Key, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	if thumbnailTypes[contentType] {
+		if thumbnail, thumbnailType, err := helpers.Thumbnail(data, thumbnailSize); err != nil {
+			// the upload is still usable without a thumbnail
+			log.Printf("generating thumbnail of %s: %v", attachment.Key, err)
+		} else {
+			key := "thumbs/" + hash[:2] + "/" + hash + uploadTypes[thumbnailType]
+
+			if err = storage.Default.Put(key, bytes.NewReader(thumbnail), int64(len(thumbnail)), thumbnailType); err != nil {
+				helpers.InternalServerErrorResponse(res, err)
+				return
+			}
+
+			attachment.ThumbnailKey = key
+		}
+	}
+
+	attachment, err = models.CreateAttachment(attachment, authorId)
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: attachment})
+
+	return
+}
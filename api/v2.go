@@ -0,0 +1,392 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// Handler for <base>/api/v2/... calls
+//
+// Compared to v1, v2 responds with the resources themselves instead of a {"status", "content"}
+// envelope, collections as {"items", "count"}, 201 with a Location header on creation, the full
+// resource on update and 204 on deletion.
+//
+// Posts are sent with an ETag, modifications honour If-Match and fail with 412 if the post has
+// been modified since.
+type V2Handler struct {
+	AccountHandler *AccountHandler
+	AdminHandler   *AdminHandler
+	AuthorHandler  *V2AuthorHandler
+	LoginHandler   *V2LoginHandler
+	PostHandler    *V2PostHandler
+	UploadHandler  *UploadHandler
+}
+
+// V2Handler's ServeHTTP receives <base>/api/v2/:profile/... calls and based on the profile routes
+// to best matched handler
+func (h *V2Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	switch head {
+	case "authors": // <base>/api/v2/authors/...
+		h.AuthorHandler.ServeHTTP(res, req)
+	case "posts": // <base>/api/v2/posts/...
+		h.PostHandler.ServeHTTP(res, req)
+	case "login": // <base>/api/v2/login/...
+		h.LoginHandler.ServeHTTP(res, req)
+	case "account": // <base>/api/v2/account/...
+		h.AccountHandler.ServeHTTP(res, req)
+	case "uploads": // <base>/api/v2/uploads
+		h.UploadHandler.ServeHTTP(res, req)
+	case "admin": // <base>/api/v2/admin/...
+		h.AdminHandler.ServeHTTP(res, req)
+	default: // all other
+		helpers.NotFoundResponse(res)
+	}
+
+	return
+}
+
+// Handler for <base>/api/v2/authors/... calls
+type V2AuthorHandler struct {
+	ExportHandler *ExportHandler
+}
+
+// V2AuthorHandler's ServeHTTP handles URLs of type
+//
+// GET	<base>/api/v2/authors/					All authors (excluding posts)
+//
+// POST	<base>/api/v2/authors/					Create an author
+//
+// GET	<base>/api/v2/authors/:authorId			Author including posts
+//
+// GET	<base>/api/v2/authors/:authorId/export	Archive of the author's posts and uploads
+func (h *V2AuthorHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var authorId string
+
+	authorId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if authorId != "" && req.URL.Path == "/export" && helpers.IsValidId(authorId) {
+		h.ExportHandler.Handler(authorId).ServeHTTP(res, req)
+		return
+	}
+
+	if req.URL.Path != "/" || (authorId != "" && !helpers.IsValidId(authorId)) {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	switch {
+	case authorId == "" && req.Method == "GET":
+		authors, err := models.GetAllAuthors()
+
+		if err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+
+		writeJSON(res, http.StatusOK, types.Collection{Items: authors, Count: len(authors)})
+	case authorId == "" && req.Method == "POST":
+		var body types.CreateAuthorRequest
+
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
+
+		authorId, err := models.CreateAuthor(body.Username, body.Password, body.Email)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		content, err := models.GetAuthorById(authorId)
+
+		if err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+
+		res.Header().Set("Location", "/api/v2/authors/"+authorId)
+		writeJSON(res, http.StatusCreated, content.AuthorInfo)
+	case authorId != "" && req.Method == "GET":
+		content, err := models.GetAuthorById(authorId)
+
+		if err == sql.ErrNoRows {
+			helpers.ResourceNotFoundResponse(res, helpers.CodeAuthorNotFound, "Author does not exist!")
+			return
+		}
+
+		if err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+
+		detail := types.AuthorDetail{Author: content.AuthorInfo, Posts: make([]types.PostSummary, len(content.List))}
+
+		for index, post := range content.List {
+			detail.Posts[index] = postSummary(post)
+		}
+
+		writeJSON(res, http.StatusOK, detail)
+	default:
+		helpers.MethodNotAllowedResponse(res)
+	}
+
+	return
+}
+
+// Handler for <base>/api/v2/posts/... calls
+type V2PostHandler struct {
+}
+
+// V2PostHandler's ServeHTTP handles URLs of type
+//
+// GET		<base>/api/v2/posts/			All posts
+//
+// POST		<base>/api/v2/posts/			Create a post
+//
+// DELETE	<base>/api/v2/posts/			Delete all posts of the logged in author
+//
+// GET		<base>/api/v2/posts/:postId		A specific post
+//
+// PUT		<base>/api/v2/posts/:postId		Update a specific post
+//
+// PATCH	<base>/api/v2/posts/:postId		Partially update a specific post with a JSON merge patch
+//
+// DELETE	<base>/api/v2/posts/:postId		Delete a specific post
+func (h *V2PostHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var postId string
+
+	postId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if req.URL.Path != "/" || (postId != "" && !helpers.IsValidId(postId)) {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	// everything but reading needs a logged in author
+	if req.Method != "GET" && authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	switch {
+	case postId == "" && req.Method == "GET":
+		posts, err := models.GetAllPosts()
+
+		if err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+
+		writeJSON(res, http.StatusOK, types.Collection{Items: posts, Count: len(posts)})
+	case postId == "" && req.Method == "POST":
+		var body types.PostRequest
+
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
+
+		postId, err := models.CreatePost(body.Title, body.Body, authorId, body.Tags)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.Header().Set("Location", "/api/v2/posts/"+postId)
+		writePost(res, http.StatusCreated, postId)
+	case postId == "" && req.Method == "DELETE":
+		if err := models.DeletePosts(authorId); err != nil {
+			helpers.InternalServerErrorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	case postId != "" && req.Method == "GET":
+		writePost(res, http.StatusOK, postId)
+	case postId != "" && req.Method == "PUT":
+		var body types.PostRequest
+
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
+
+		revisions, ok := helpers.IfMatchRevisions(req)
+
+		if !ok {
+			helpers.PreconditionFailedResponse(res)
+			return
+		}
+
+		if _, err := models.UpdatePost(postId, body.Title, body.Body, authorId, body.Tags, revisions); err != nil {
+			postWriteErrorResponse(res, postId, err)
+			return
+		}
+
+		writePost(res, http.StatusOK, postId)
+	case postId != "" && req.Method == "PATCH":
+		if _, ok := patchPost(res, req, postId, authorId); !ok {
+			return
+		}
+
+		writePost(res, http.StatusOK, postId)
+	case postId != "" && req.Method == "DELETE":
+		revisions, ok := helpers.IfMatchRevisions(req)
+
+		if !ok {
+			helpers.PreconditionFailedResponse(res)
+			return
+		}
+
+		if err := models.DeletePost(postId, authorId, revisions); err != nil {
+			postWriteErrorResponse(res, postId, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		helpers.MethodNotAllowedResponse(res)
+	}
+
+	return
+}
+
+// Handler for <base>/api/v2/login/ call
+type V2LoginHandler struct {
+}
+
+// V2LoginHandler's ServeHTTP logs in a user and returns a session token with its expiry
+//
+// POST	<base>/api/v2/login/		With username and password
+//
+// POST	<base>/api/v2/login/oidc	With an authorization code of the identity provider
+func (h *V2LoginHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if req.URL.Path != "/" || (head != "" && head != "oidc") {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if req.Method != "POST" {
+		helpers.MethodNotAllowedResponse(res)
+		return
+	}
+
+	var (
+		authorId string
+		ok       bool
+	)
+
+	if head == "oidc" {
+		authorId, ok = checkOIDCLogin(res, req)
+	} else {
+		authorId, ok = checkLogin(res, req)
+	}
+
+	if !ok {
+		return
+	}
+
+	expiresAt := time.Now().Add(helpers.TokenLifetime)
+	tokenString, err := helpers.CreateToken(authorId)
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, types.Token{Token: tokenString, ExpiresAt: expiresAt.UTC().Truncate(time.Second)})
+
+	return
+}
+
+// checkOIDCLogin returns the author of the identity an authorization code was issued for, on
+// failure a problem has been sent and false is returned
+//
+// The identity provider is trusted to have done any second factor, TOTP isn't asked for.
+func checkOIDCLogin(res http.ResponseWriter, req *http.Request) (string, bool) {
+	identity, ok := exchangeIdentity(res, req)
+
+	if !ok {
+		return "", false
+	}
+
+	authorId, err := models.LoginWithIdentity(identity, oidc.Default.Provision)
+
+	if err != nil {
+		errorResponse(res, err)
+		return "", false
+	}
+
+	return authorId, true
+}
+
+// writePost responds with the post whose id is postId
+func writePost(res http.ResponseWriter, status int, postId string) {
+	post, err := models.GetPostById(postId)
+
+	if err == sql.ErrNoRows {
+		helpers.ResourceNotFoundResponse(res, helpers.CodePostNotFound, "Post does not exist!")
+		return
+	}
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return
+	}
+
+	res.Header().Set("ETag", helpers.RevisionETag(post.Revision))
+	res.Header().Set("Last-Modified", post.UpdatedAt.UTC().Format(http.TimeFormat))
+	writeJSON(res, status, post)
+}
+
+// writeJSON responds with v encoded as JSON
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(v)
+}
+
+// postSummary returns post without its author
+func postSummary(post types.Post) types.PostSummary {
+	return types.PostSummary{
+		Id:        post.Id,
+		Title:     post.Title,
+		Body:      post.Body,
+		Tags:      post.Tags,
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+}
@@ -2,10 +2,14 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
-	"database/sql"
+	"github.com/samkit-jain/go-blog/events"
 	"github.com/samkit-jain/go-blog/helpers"
 	"github.com/samkit-jain/go-blog/models"
 	"github.com/samkit-jain/go-blog/types"
@@ -13,40 +17,185 @@ import (
 
 // Base handler for <base>/api/... calls
 type ApiHandler struct {
-	AuthorHandler *AuthorHandler
-	LoginHandler  *LoginHandler
-	PostHandler   *PostHandler
+	V1Handler       *V1Handler
+	V2Handler       *V2Handler
+	SpecHandler     *SpecHandler
+	ExplorerHandler *ExplorerHandler
+	StreamHandler   *StreamHandler
+	GraphQLHandler  *GraphQLHandler
+	SpecChecker     *SpecChecker // nil unless GOBLOG_OPENAPI_CHECK is set
+	CORS            *CORS
+	RateLimiter     *RateLimiter // nil if rate limiting is disabled
 }
 
 // ApiHandler's constructor
 func NewApiHandler() *ApiHandler {
+	// handlers whose responses are the same in every version
+	accountHandler := &AccountHandler{
+		EmailHandler:   new(EmailHandler),
+		VerifyHandler:  new(VerifyHandler),
+		ForgotHandler:  new(ForgotHandler),
+		ResetHandler:   new(ResetHandler),
+		TOTPHandler:    new(TOTPHandler),
+		APIKeyHandler:  new(APIKeyHandler),
+		OIDCHandler:    new(OIDCHandler),
+		WebhookHandler: new(WebhookHandler),
+	}
+	uploadHandler := new(UploadHandler)
+	exportHandler := new(ExportHandler)
+	spec := NewSpec()
+
 	return &ApiHandler{
-		AuthorHandler: &AuthorHandler{
-			AuthorIdPresentHandler:    new(AuthorIdPresentHandler),
-			AuthorIdNotPresentHandler: new(AuthorIdNotPresentHandler),
+		V1Handler: &V1Handler{
+			AuthorHandler: &AuthorHandler{
+				AuthorIdPresentHandler:    new(AuthorIdPresentHandler),
+				AuthorIdNotPresentHandler: new(AuthorIdNotPresentHandler),
+				ExportHandler:             exportHandler,
+			},
+			PostHandler: &PostHandler{
+				PostIdPresentHandler:    new(PostIdPresentHandler),
+				PostIdNotPresentHandler: new(PostIdNotPresentHandler),
+			},
+			LoginHandler:   new(LoginHandler),
+			UploadHandler:  uploadHandler,
+			AccountHandler: accountHandler,
 		},
-		PostHandler: &PostHandler{
-			PostIdPresentHandler:    new(PostIdPresentHandler),
-			PostIdNotPresentHandler: new(PostIdNotPresentHandler),
+		V2Handler: &V2Handler{
+			AuthorHandler:  &V2AuthorHandler{ExportHandler: exportHandler},
+			PostHandler:    new(V2PostHandler),
+			LoginHandler:   new(V2LoginHandler),
+			UploadHandler:  uploadHandler,
+			AccountHandler: accountHandler,
+			AdminHandler:   NewAdminHandler(),
 		},
-		LoginHandler: new(LoginHandler),
+		SpecHandler:     &SpecHandler{Spec: spec},
+		ExplorerHandler: new(ExplorerHandler),
+		StreamHandler:   NewStreamHandler(events.Default),
+		GraphQLHandler:  NewGraphQLHandler(),
+		SpecChecker:     NewSpecChecker(spec),
+		CORS:            NewCORS(),
+		RateLimiter:     NewRateLimiter(),
 	}
 }
 
-// ApiHandler's ServeHTTP receives <base>/api/:profile/... calls and based on the profile routes to
-// best matched handler
+// ApiHandler's ServeHTTP receives <base>/api/:version/... calls and routes them to the handler of
+// the version, paths without a version are served by v1
 func (h *ApiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var handler http.Handler
+
+	// browser clients on other origins, preflights of every route are answered here
+	if h.CORS.Handle(res, req) {
+		return
+	}
+
+	path := req.URL.Path
+	head, tail := helpers.ShiftPath(path)
+
+	switch head {
+	case "openapi.json": // <base>/api/openapi.json
+		req.URL.Path = tail
+		h.SpecHandler.ServeHTTP(res, req)
+		return
+	case "docs": // <base>/api/docs/
+		req.URL.Path = tail
+		h.ExplorerHandler.ServeHTTP(res, req)
+		return
+	case "stream": // <base>/api/stream
+		req.URL.Path = tail
+
+		// long-lived, counted once per connection and never checked against the spec
+		if h.RateLimiter != nil && !h.RateLimiter.Allow(res, req, "/stream") {
+			return
+		}
+
+		h.StreamHandler.ServeHTTP(res, req)
+		return
+	case "graphql": // <base>/api/graphql
+		req.URL.Path = tail
+
+		// a single route for every operation, mutations check the scope of API keys themselves
+		req, ok := authenticateAPIKey(res, req, "/graphql")
+
+		if !ok {
+			return
+		}
+
+		if h.RateLimiter != nil && !h.RateLimiter.Allow(res, req, "/graphql") {
+			return
+		}
+
+		h.GraphQLHandler.ServeHTTP(res, req)
+		return
+	case "v1": // <base>/api/v1/...
+		req.URL.Path = tail
+		handler = h.V1Handler
+	case "v2": // <base>/api/v2/...
+		req.URL.Path = tail
+		handler = h.V2Handler
+	default: // <base>/api/... alias of v1
+		handler = h.V1Handler
+	}
+
+	// scripts authenticated by an API key instead of a session token
+	req, ok := authenticateAPIKey(res, req, req.URL.Path)
+
+	if !ok {
+		return
+	}
+
+	if h.RateLimiter != nil && !h.RateLimiter.Allow(res, req, req.URL.Path) {
+		return
+	}
+
+	if h.SpecChecker != nil {
+		handler = h.SpecChecker.Handler("/api"+path, handler)
+	}
+
+	handler.ServeHTTP(res, req)
+
+	return
+}
+
+// dates announced in the Deprecation and Sunset headers of v1 responses
+var (
+	v1DeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	v1SunsetAt     = time.Date(2027, time.October, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// Handler for <base>/api/v1/... calls
+//
+// v1 is frozen, its responses keep the {"status": ..., "content": ...} shape.
+type V1Handler struct {
+	AccountHandler *AccountHandler
+	AuthorHandler  *AuthorHandler
+	LoginHandler   *LoginHandler
+	PostHandler    *PostHandler
+	UploadHandler  *UploadHandler
+}
+
+// V1Handler's ServeHTTP receives <base>/api/v1/:profile/... calls and based on the profile routes
+// to best matched handler
+func (h *V1Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	var head string
 
+	// RFC 9745 and RFC 8594
+	res.Header().Set("Deprecation", "@"+strconv.FormatInt(v1DeprecatedAt.Unix(), 10))
+	res.Header().Set("Sunset", v1SunsetAt.Format(http.TimeFormat))
+	res.Header().Set("Link", `</api/v2/>; rel="successor-version"`)
+
 	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
 
 	switch head {
-	case "authors": // <base>/api/authors/...
+	case "authors": // <base>/api/v1/authors/...
 		h.AuthorHandler.ServeHTTP(res, req)
-	case "posts": // <base>/api/posts/...
+	case "posts": // <base>/api/v1/posts/...
 		h.PostHandler.ServeHTTP(res, req)
-	case "login": // <base>/api/login/...
+	case "login": // <base>/api/v1/login/...
 		h.LoginHandler.ServeHTTP(res, req)
+	case "account": // <base>/api/v1/account/...
+		h.AccountHandler.ServeHTTP(res, req)
+	case "uploads": // <base>/api/v1/uploads
+		h.UploadHandler.ServeHTTP(res, req)
 	default: // all other
 		helpers.NotFoundResponse(res)
 	}
@@ -54,10 +203,11 @@ func (h *ApiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	return
 }
 
-// Handler for <base>/api/authors/... calls
+// Handler for <base>/api/v1/authors/... calls
 type AuthorHandler struct {
 	AuthorIdPresentHandler    *AuthorIdPresentHandler
 	AuthorIdNotPresentHandler *AuthorIdNotPresentHandler
+	ExportHandler             *ExportHandler
 }
 
 // AuthorHandler's ServeHTTP serves URLs of authors profile
@@ -68,6 +218,17 @@ func (h *AuthorHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	// get authorId from path
 	authorId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
 
+	// path /authors/:authorId/export
+	if authorId != "" && req.URL.Path == "/export" {
+		if !helpers.IsValidId(authorId) {
+			helpers.NotFoundResponse(res)
+			return
+		}
+
+		h.ExportHandler.Handler(authorId).ServeHTTP(res, req)
+		return
+	}
+
 	// URL not empty even after removing authorId
 	if req.URL.Path != "/" {
 		helpers.NotFoundResponse(res)
@@ -80,6 +241,11 @@ func (h *AuthorHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.AuthorIdNotPresentHandler.ServeHTTP(res, req)
 	default:
 		// path /authors/:authorId
+		if !helpers.IsValidId(authorId) {
+			helpers.NotFoundResponse(res)
+			return
+		}
+
 		h.AuthorIdPresentHandler.Handler(authorId).ServeHTTP(res, req)
 	}
 
@@ -92,7 +258,7 @@ type AuthorIdPresentHandler struct {
 
 // AuthorIdPresentHandler's ServeHTTP returns information of author (including posts) whose id is authorId
 //
-// GET	<base>/api/authors/:authorId
+// GET	<base>/api/v1/authors/:authorId
 func (h *AuthorIdPresentHandler) Handler(authorId string) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		// set response header's content-type
@@ -100,9 +266,9 @@ func (h *AuthorIdPresentHandler) Handler(authorId string) http.Handler {
 
 		if content, err := models.GetAuthorById(authorId); err != nil {
 			if err == sql.ErrNoRows {
-				helpers.BadRequestResponse(res, "Author does not exist!")
+				helpers.ResourceNotFoundResponse(res, helpers.CodeAuthorNotFound, "Author does not exist!")
 			} else {
-				helpers.InternalServerErrorResponse(res, err.Error())
+				helpers.InternalServerErrorResponse(res, err)
 			}
 		} else {
 			res.WriteHeader(http.StatusOK)
@@ -119,9 +285,9 @@ type AuthorIdNotPresentHandler struct {
 
 // AuthorIdNotPresentHandler's ServeHTTP returns information of all authors (excluding posts)
 //
-// GET	<base>/api/authors/		Get all authors
+// GET	<base>/api/v1/authors/		Get all authors
 //
-// POST	<base>/api/authors/		Create an author
+// POST	<base>/api/v1/authors/		Create an author (email optional)
 func (h *AuthorIdNotPresentHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	// set response header's content-type
 	res.Header().Set("Content-Type", "application/json")
@@ -133,19 +299,27 @@ func (h *AuthorIdNotPresentHandler) ServeHTTP(res http.ResponseWriter, req *http
 				res.WriteHeader(http.StatusOK)
 				json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: content})
 			} else {
-				helpers.InternalServerErrorResponse(res, err.Error())
+				helpers.InternalServerErrorResponse(res, err)
 			}
 		} else {
 			res.WriteHeader(http.StatusOK)
 			json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: content})
 		}
 	case "POST":
-		// read passed form parameters
-		username := req.FormValue("username")
-		password := req.FormValue("password")
+		var body types.CreateAuthorRequest
+
+		// read passed JSON or form parameters
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
 
-		if authorId, err := models.CreateAuthor(username, password); err != nil {
-			helpers.InternalServerErrorResponse(res, err.Error())
+		if authorId, err := models.CreateAuthor(body.Username, body.Password, body.Email); err != nil {
+			errorResponse(res, err)
 		} else {
 			res.WriteHeader(http.StatusOK)
 			json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: authorId})
@@ -157,7 +331,7 @@ func (h *AuthorIdNotPresentHandler) ServeHTTP(res http.ResponseWriter, req *http
 	return
 }
 
-// Handler for <base>/api/posts/... calls
+// Handler for <base>/api/v1/posts/... calls
 type PostHandler struct {
 	PostIdPresentHandler    *PostIdPresentHandler
 	PostIdNotPresentHandler *PostIdNotPresentHandler
@@ -184,6 +358,11 @@ func (h *PostHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.PostIdNotPresentHandler.Handler(authorId).ServeHTTP(res, req)
 	default:
 		// path /posts/:postId
+		if !helpers.IsValidId(postId) {
+			helpers.NotFoundResponse(res)
+			return
+		}
+
 		h.PostIdPresentHandler.Handler(postId, authorId).ServeHTTP(res, req)
 	}
 
@@ -196,11 +375,13 @@ type PostIdPresentHandler struct {
 
 // PostIdPresentHandler's method to handle URLs of type
 //
-// GET  	<base>/api/posts/:postId	Info of a specific post
+// GET  	<base>/api/v1/posts/:postId	Info of a specific post
+//
+// PUT  	<base>/api/v1/posts/:postId	Update a specific post
 //
-// PUT  	<base>/api/posts/:postId	Update a specific post
+// PATCH  	<base>/api/v1/posts/:postId	Partially update a specific post with a JSON merge patch
 //
-// DELETE  	<base>/api/posts/:postId	Delete a specific post
+// DELETE  	<base>/api/v1/posts/:postId	Delete a specific post
 func (h *PostIdPresentHandler) Handler(postId, authorId string) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		// set response header's content-type
@@ -210,46 +391,73 @@ func (h *PostIdPresentHandler) Handler(postId, authorId string) http.Handler {
 		case "GET":
 			if content, err := models.GetPostById(postId); err != nil {
 				if err == sql.ErrNoRows {
-					helpers.BadRequestResponse(res, "Post does not exist!")
+					helpers.ResourceNotFoundResponse(res, helpers.CodePostNotFound, "Post does not exist!")
 				} else {
-					helpers.InternalServerErrorResponse(res, err.Error())
+					helpers.InternalServerErrorResponse(res, err)
 				}
 			} else {
+				res.Header().Set("ETag", helpers.RevisionETag(content.Revision))
+				res.Header().Set("Last-Modified", content.UpdatedAt.UTC().Format(http.TimeFormat))
 				res.WriteHeader(http.StatusOK)
 				json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: content})
 			}
 		case "PUT":
 			if authorId != "" {
-				title := req.FormValue("title")
-				body := req.FormValue("body")
-
-				if postId, err := models.UpdatePost(postId, title, body, authorId); err != nil {
-					if err == sql.ErrNoRows {
-						helpers.BadRequestResponse(res, "You don't have write access to the post!")
-					} else {
-						helpers.InternalServerErrorResponse(res, err.Error())
-					}
+				var body types.PostRequest
+
+				if err := helpers.DecodeRequest(res, req, &body); err != nil {
+					helpers.RequestErrorResponse(res, err)
+					return
+				}
+
+				if !validate(res, body.Validate()) {
+					return
+				}
+
+				revisions, ok := helpers.IfMatchRevisions(req)
+
+				if !ok {
+					helpers.PreconditionFailedResponse(res)
+					return
+				}
+
+				if revision, err := models.UpdatePost(postId, body.Title, body.Body, authorId, body.Tags, revisions); err != nil {
+					postWriteErrorResponse(res, postId, err)
 				} else {
+					res.Header().Set("ETag", helpers.RevisionETag(revision))
+					res.WriteHeader(http.StatusOK)
+					json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: postId})
+				}
+			} else {
+				helpers.UnauthorizedResponse(res)
+			}
+		case "PATCH":
+			if authorId != "" {
+				if revision, ok := patchPost(res, req, postId, authorId); ok {
+					res.Header().Set("ETag", helpers.RevisionETag(revision))
 					res.WriteHeader(http.StatusOK)
 					json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: postId})
 				}
 			} else {
-				helpers.ForbiddenResponse(res)
+				helpers.UnauthorizedResponse(res)
 			}
 		case "DELETE":
 			if authorId != "" {
-				if err := models.DeletePost(postId, authorId); err != nil {
-					if err == sql.ErrNoRows {
-						helpers.BadRequestResponse(res, "You don't have write access to the post!")
-					} else {
-						helpers.InternalServerErrorResponse(res, err.Error())
-					}
+				revisions, ok := helpers.IfMatchRevisions(req)
+
+				if !ok {
+					helpers.PreconditionFailedResponse(res)
+					return
+				}
+
+				if err := models.DeletePost(postId, authorId, revisions); err != nil {
+					postWriteErrorResponse(res, postId, err)
 				} else {
 					res.WriteHeader(http.StatusOK)
 					json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Post deleted!"})
 				}
 			} else {
-				helpers.ForbiddenResponse(res)
+				helpers.UnauthorizedResponse(res)
 				return
 			}
 		default:
@@ -283,7 +491,7 @@ func (h *PostIdNotPresentHandler) Handler(authorId string) http.Handler {
 					res.WriteHeader(http.StatusOK)
 					json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: content})
 				} else {
-					helpers.InternalServerErrorResponse(res, err.Error())
+					helpers.InternalServerErrorResponse(res, err)
 				}
 			} else {
 				res.WriteHeader(http.StatusOK)
@@ -291,37 +499,37 @@ func (h *PostIdNotPresentHandler) Handler(authorId string) http.Handler {
 			}
 		case "POST":
 			if authorId != "" {
-				// read passed form parameters
-				title := req.FormValue("title")
-				body := req.FormValue("body")
-
-				if postId, err := models.CreatePost(title, body, authorId); err != nil {
-					if err == sql.ErrNoRows {
-						helpers.BadRequestResponse(res, "You don't have write access to the post!")
-					} else {
-						helpers.InternalServerErrorResponse(res, err.Error())
-					}
+				var body types.PostRequest
+
+				// read passed JSON or form parameters
+				if err := helpers.DecodeRequest(res, req, &body); err != nil {
+					helpers.RequestErrorResponse(res, err)
+					return
+				}
+
+				if !validate(res, body.Validate()) {
+					return
+				}
+
+				if postId, err := models.CreatePost(body.Title, body.Body, authorId, body.Tags); err != nil {
+					errorResponse(res, err)
 				} else {
 					res.WriteHeader(http.StatusOK)
 					json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: postId})
 				}
 			} else {
-				helpers.ForbiddenResponse(res)
+				helpers.UnauthorizedResponse(res)
 			}
 		case "DELETE":
 			if authorId != "" {
 				if err := models.DeletePosts(authorId); err != nil {
-					if err == sql.ErrNoRows {
-						helpers.BadRequestResponse(res, "You don't have write access to the post!")
-					} else {
-						helpers.InternalServerErrorResponse(res, err.Error())
-					}
+					helpers.InternalServerErrorResponse(res, err)
 				} else {
 					res.WriteHeader(http.StatusOK)
 					json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: "Deleted!"})
 				}
 			} else {
-				helpers.ForbiddenResponse(res)
+				helpers.UnauthorizedResponse(res)
 			}
 		default:
 			helpers.MethodNotAllowedResponse(res)
@@ -331,13 +539,16 @@ func (h *PostIdNotPresentHandler) Handler(authorId string) http.Handler {
 	})
 }
 
-// Handler for <base>/api/login/ call
+// Handler for <base>/api/v1/login/ call
 type LoginHandler struct {
 }
 
 // LoginHandler's ServeHTTP logs in a user and returns a session token
 //
-// POST	<base>/api/login/
+// Authors with two-factor authentication enabled must also send "otp", either a TOTP code or a
+// recovery code.
+//
+// POST	<base>/api/v1/login/
 func (h *LoginHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
 
@@ -349,42 +560,181 @@ func (h *LoginHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	}
 
 	if req.Method == "POST" {
-		un := req.FormValue("username")
-		ps := req.FormValue("password")
+		authorId, ok := checkLogin(res, req)
+
+		if !ok {
+			return
+		}
 
-		encryptedPassword, err := models.GetPasswordHash(un)
+		tokenString, err := helpers.CreateToken(authorId)
 
 		if err != nil {
-			helpers.InternalServerErrorResponse(res, err.Error())
+			helpers.InternalServerErrorResponse(res, err)
 			return
 		}
 
-		psMatched := helpers.CheckPasswordHash(ps, encryptedPassword)
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: tokenString})
+	} else {
+		helpers.MethodNotAllowedResponse(res)
+	}
 
-		if psMatched {
-			authorId, err := models.GetAuthorIdByUsername(un)
+	return
+}
 
-			if err != nil {
-				helpers.InternalServerErrorResponse(res, err.Error())
-				return
-			}
+// patchPost applies the JSON merge patch in the body of req to an author's post and returns its
+// new revision, on failure a problem has been sent and false is returned
+//
+// The patch is applied to the revision that has been read, so a concurrent modification fails with
+// 412 like a mismatching If-Match header does.
+func patchPost(res http.ResponseWriter, req *http.Request, postId, authorId string) (int, bool) {
+	revisions, ok := helpers.IfMatchRevisions(req)
+
+	if !ok {
+		helpers.PreconditionFailedResponse(res)
+		return 0, false
+	}
 
-			tokenString, err := helpers.CreateToken(authorId)
+	post, err := models.GetPostById(postId)
 
-			if err != nil {
-				helpers.InternalServerErrorResponse(res, err.Error())
-				return
-			}
+	if err == sql.ErrNoRows {
+		helpers.ResourceNotFoundResponse(res, helpers.CodePostNotFound, "Post does not exist!")
+		return 0, false
+	}
 
-			res.WriteHeader(http.StatusOK)
-			json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: tokenString})
-		} else {
-			res.WriteHeader(http.StatusOK)
-			json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: "Invalid credentials"})
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return 0, false
+	}
+
+	if post.AuthorInfo.AuthorId != authorId {
+		helpers.ForbiddenResponse(res, "You don't have write access to the post!")
+		return 0, false
+	}
+
+	if revisions != nil && !containsRevision(revisions, post.Revision) {
+		helpers.PreconditionFailedResponse(res)
+		return 0, false
+	}
+
+	// the tags are copied, decoding into the cached post's slice would modify it
+	body := types.PostRequest{Title: post.Title, Body: post.Body, Tags: append([]string(nil), post.Tags...)}
+
+	if err := helpers.DecodeMergePatch(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return 0, false
+	}
+
+	// tags set to null are removed, nil would leave them unchanged
+	if body.Tags == nil {
+		body.Tags = []string{}
+	}
+
+	if !validate(res, body.Validate()) {
+		return 0, false
+	}
+
+	revision, err := models.UpdatePost(postId, body.Title, body.Body, authorId, body.Tags, []int{post.Revision})
+
+	if err != nil {
+		postWriteErrorResponse(res, postId, err)
+		return 0, false
+	}
+
+	return revision, true
+}
+
+// containsRevision reports if revision is one of revisions
+func containsRevision(revisions []int, revision int) bool {
+	for _, item := range revisions {
+		if item == revision {
+			return true
 		}
-	} else {
-		helpers.MethodNotAllowedResponse(res)
 	}
 
+	return false
+}
+
+// checkLogin reads the credentials of a login request and returns the ID of the author they belong
+// to, on failure a problem has been sent and false is returned
+func checkLogin(res http.ResponseWriter, req *http.Request) (string, bool) {
+	var body types.LoginRequest
+
+	if err := helpers.DecodeRequest(res, req, &body); err != nil {
+		helpers.RequestErrorResponse(res, err)
+		return "", false
+	}
+
+	if !validate(res, body.Validate()) {
+		return "", false
+	}
+
+	un := body.Username
+	ps := body.Password
+
+	encryptedPassword, err := models.GetPasswordHash(un)
+
+	// unknown usernames are reported the same as wrong passwords
+	if err == sql.ErrNoRows {
+		invalidCredentialsResponse(res)
+		return "", false
+	}
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return "", false
+	}
+
+	if !helpers.CheckPasswordHash(ps, encryptedPassword) {
+		invalidCredentialsResponse(res)
+		return "", false
+	}
+
+	// not failing the login if the stored hash can't be upgraded
+	if err := models.UpgradePasswordHash(un, ps, encryptedPassword); err != nil {
+		log.Printf("upgrading password hash of %s: %v", un, err)
+	}
+
+	authorId, err := models.GetAuthorIdByUsername(un)
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return "", false
+	}
+
+	// authors with TOTP enabled also need to send a code from their authenticator
+	totpEnabled, err := models.TOTPEnabled(authorId)
+
+	if err != nil {
+		helpers.InternalServerErrorResponse(res, err)
+		return "", false
+	}
+
+	if totpEnabled {
+		if body.OTP == "" {
+			helpers.ProblemResponse(res, helpers.NewProblem(http.StatusUnauthorized, helpers.CodeOTPRequired, "Two-factor code required!"))
+			return "", false
+		}
+
+		otpMatched, err := models.CheckSecondFactor(authorId, body.OTP)
+
+		if err != nil {
+			errorResponse(res, err)
+			return "", false
+		}
+
+		if !otpMatched {
+			invalidCredentialsResponse(res)
+			return "", false
+		}
+	}
+
+	return authorId, true
+}
+
+// invalidCredentialsResponse returns a 401 problem for a failed login
+func invalidCredentialsResponse(res http.ResponseWriter) {
+	helpers.ProblemResponse(res, helpers.NewProblem(http.StatusUnauthorized, helpers.CodeInvalidCredentials, "Invalid credentials!"))
+
 	return
 }
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// number of deliveries returned by the delivery log
+const webhookDeliveryLogSize = 50
+
+// WebhookHandler manages the logged in author's webhooks
+type WebhookHandler struct {
+}
+
+// WebhookHandler's ServeHTTP handles URLs of type
+//
+// GET		<base>/api/account/webhooks										List the webhooks
+//
+// POST		<base>/api/account/webhooks										Create a webhook, the response is the only time its secret is shown
+//
+// DELETE	<base>/api/account/webhooks/:webhookId							Delete a webhook
+//
+// GET		<base>/api/account/webhooks/:webhookId/deliveries				Latest deliveries of a webhook
+//
+// POST		<base>/api/account/webhooks/:webhookId/deliveries/:deliveryId/redeliver	Deliver the event of a delivery again
+func (h *WebhookHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var webhookId, action, deliveryId, redeliver string
+
+	res.Header().Set("Content-Type", "application/json")
+
+	webhookId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+	action, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+	deliveryId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+	redeliver, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	// the only action on a delivery is redelivering it
+	if req.URL.Path != "/" || (action != "" && action != "deliveries") || (deliveryId != "") != (redeliver == "redeliver") {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	if (webhookId != "" && !helpers.IsValidId(webhookId)) || (deliveryId != "" && !helpers.IsValidId(deliveryId)) {
+		helpers.NotFoundResponse(res)
+		return
+	}
+
+	authorId := helpers.GetAuthorIdFromHeader(req)
+
+	if authorId == "" {
+		helpers.UnauthorizedResponse(res)
+		return
+	}
+
+	switch {
+	case webhookId == "" && req.Method == "GET":
+		webhooks, err := models.GetWebhooks(authorId)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: webhooks})
+	case webhookId == "" && req.Method == "POST":
+		var body types.CreateWebhookRequest
+
+		if err := helpers.DecodeRequest(res, req, &body); err != nil {
+			helpers.RequestErrorResponse(res, err)
+			return
+		}
+
+		if !validate(res, body.Validate()) {
+			return
+		}
+
+		webhook, err := models.CreateWebhook(authorId, body.URL, body.Events)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: webhook})
+	case webhookId != "" && action == "" && req.Method == "DELETE":
+		if err := models.DeleteWebhook(authorId, webhookId); err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.DefaultResponse{Status: "success", Message: "Webhook deleted!"})
+	case webhookId != "" && action != "" && deliveryId == "" && req.Method == "GET":
+		deliveries, err := models.GetWebhookDeliveries(authorId, webhookId, webhookDeliveryLogSize)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: deliveries})
+	case webhookId != "" && deliveryId != "" && req.Method == "POST":
+		delivery, err := models.RedeliverWebhook(authorId, webhookId, deliveryId)
+
+		if err != nil {
+			errorResponse(res, err)
+			return
+		}
+
+		res.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(res).Encode(types.ValidResponse{Status: "success", Content: delivery})
+	default:
+		helpers.MethodNotAllowedResponse(res)
+	}
+
+	return
+}
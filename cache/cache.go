@@ -0,0 +1,140 @@
+// Package cache provides a read cache in front of the models
+package cache
+
+import (
+	"expvar"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Store keeps cached values, implementations must be safe for concurrent use
+//
+// Values are shared between callers and must be treated as read-only. Stores keeping values out of
+// process (e.g. Redis) have to encode them, gob keeps the fields hidden from JSON.
+type Store interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	Delete(keys ...string)
+}
+
+// Stats are the counters of a cache
+type Stats struct {
+	Hits      uint64 `json:"hits"`      // values served from the store
+	Misses    uint64 `json:"misses"`    // values loaded
+	Coalesced uint64 `json:"coalesced"` // misses that shared one load with concurrent misses
+}
+
+// Cache loads values through its store, concurrent misses of a key share one load
+type Cache struct {
+	Store Store
+	TTL   time.Duration
+
+	group      singleflight.Group
+	generation uint64 // incremented on every invalidation
+	hits       uint64
+	misses     uint64
+	coalesced  uint64
+}
+
+// Cache's Fetch returns the value of key, calling load on a miss
+//
+// Errors aren't cached. A nil cache always calls load.
+func (c *Cache) Fetch(key string, load func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return load()
+	}
+
+	if value, ok := c.Store.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return value, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	value, err, shared := c.group.Do(key, func() (interface{}, error) {
+		generation := atomic.LoadUint64(&c.generation)
+		value, err := load()
+
+		// values loaded while the key was invalidated may be stale already
+		if err == nil && atomic.LoadUint64(&c.generation) == generation {
+			c.Store.Set(key, value, c.TTL)
+		}
+
+		return value, err
+	})
+
+	if shared {
+		atomic.AddUint64(&c.coalesced, 1)
+	}
+
+	return value, err
+}
+
+// Cache's Invalidate removes keys, loads in flight for them aren't stored or shared afterwards
+func (c *Cache) Invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+
+	atomic.AddUint64(&c.generation, 1)
+
+	for _, key := range keys {
+		c.group.Forget(key)
+	}
+
+	c.Store.Delete(keys...)
+}
+
+// Cache's Stats returns the counters of the cache
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Coalesced: atomic.LoadUint64(&c.coalesced),
+	}
+}
+
+// cache used by the models, nil if caching is disabled
+var Default *Cache
+
+var publishOnce sync.Once
+
+// InitCache creates Default as configured by GOBLOG_CACHE ("memory" (default) or "off"),
+// GOBLOG_CACHE_SIZE (entries, default 1024) and GOBLOG_CACHE_TTL (default 5m)
+//
+// The counters are published as the expvar "cache".
+func InitCache() {
+	size := 1024
+	ttl := 5 * time.Minute
+
+	if value, err := strconv.Atoi(os.Getenv("GOBLOG_CACHE_SIZE")); err == nil && value > 0 {
+		size = value
+	}
+
+	if value, err := time.ParseDuration(os.Getenv("GOBLOG_CACHE_TTL")); err == nil && value > 0 {
+		ttl = value
+	}
+
+	switch kind := os.Getenv("GOBLOG_CACHE"); kind {
+	case "", "memory":
+		Default = &Cache{Store: NewLRUStore(size), TTL: ttl}
+	case "off":
+		Default = nil
+	default:
+		log.Fatalf("unknown GOBLOG_CACHE %q", kind)
+	}
+
+	publishOnce.Do(func() {
+		expvar.Publish("cache", expvar.Func(func() interface{} { return Default.Stats() }))
+	})
+}
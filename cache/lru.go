@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRUStore keeps up to size values in memory, evicting the least recently used one
+type LRUStore struct {
+	size    int
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+// entry of LRUStore
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// NewLRUStore returns an empty store holding up to size values
+func NewLRUStore(size int) *LRUStore {
+	return &LRUStore{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// LRUStore's Get returns the value of key if present and not expired
+func (s *LRUStore) Get(key string) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+
+	if time.Now().After(entry.expiresAt) {
+		s.remove(element)
+		return nil, false
+	}
+
+	s.order.MoveToFront(element)
+
+	return entry.value, true
+}
+
+// LRUStore's Set stores value under key for ttl
+func (s *LRUStore) Set(key string, value interface{}, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+// LRUStore's Delete removes keys
+func (s *LRUStore) Delete(keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, key := range keys {
+		if element, ok := s.entries[key]; ok {
+			s.remove(element)
+		}
+	}
+}
+
+// remove removes an element, the mutex must be held
+func (s *LRUStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,33 @@
+// Command oidc-stub runs a stub OpenID Connect identity provider to try out and test single
+// sign-on locally
+//
+// Run it next to the app:
+//
+//	go run ./cmd/oidc-stub -addr :9999 -client-id goblog
+//	GOBLOG_OIDC_ISSUER=http://localhost:9999 GOBLOG_OIDC_CLIENT_ID=goblog GOBLOG_OIDC_PROVISION=true go run .
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/oidc"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9999", "URL the stub is reachable at")
+	clientId := flag.String("client-id", "goblog", "client ID of the app")
+
+	flag.Parse()
+
+	stub, err := oidc.NewStub(*issuer, *clientId)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("stub identity provider %s listening on %s", *issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, stub))
+}
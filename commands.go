@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/exporter"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/importer"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/staticsite"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+	"github.com/samkit-jain/go-blog/website"
+)
+
+// commands run instead of the server by "go-blog <command> [flags]", they return the exit code
+var commands = map[string]func(args []string) int{
+	"import": importCommand,
+	"export": exportCommand,
+	"build":  buildCommand,
+}
+
+// runCommand runs the command named name with args
+func runCommand(name string, args []string) int {
+	command, ok := commands[name]
+
+	if !ok {
+		names := make([]string, 0, len(commands))
+
+		for name := range commands {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q, commands are: %s\n", name, strings.Join(names, ", "))
+		return 2
+	}
+
+	return command(args)
+}
+
+// list of a repeatable flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// importCommand imports the posts of an export, exits with 1 if any post failed
+//
+//	go-blog import [-format wxr|markdown|json] [-dry-run] [-create-authors] [-default-author name] [-map-author old=new] path
+func importCommand(args []string) int {
+	var authorMap listFlag
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the export: wxr, markdown or json (detected if empty)")
+	source := flags.String("source", "", "name of the imported blog, posts imported before from it are skipped (default from the export)")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing")
+	createAuthors := flags.Bool("create-authors", false, "create the authors that don't exist")
+	defaultAuthor := flags.String("default-author", "", "username of the author of posts whose author doesn't exist")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+
+	flags.Var(&authorMap, "map-author", "map an author of the export to a username as old=new (repeatable)")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go-blog import [flags] <file or directory>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	mapping, err := importer.ParseAuthorMap(authorMap)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	content, err := importer.Open(flags.Arg(0), *format)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading %s: %v\n", flags.Arg(0), err)
+		return 1
+	}
+
+	config.InitDB()
+	helpers.InitPasswordHasher()
+	helpers.InitIdGenerator()
+	storage.InitBlobStore()
+
+	report, err := importer.Run(content, importer.Options{
+		Source:        *source,
+		DryRun:        *dryRun,
+		CreateAuthors: *createAuthors,
+		DefaultAuthor: *defaultAuthor,
+		AuthorMap:     mapping,
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printImportReport(report)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import stopped: %v\n", err)
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// exportCommand writes the content of an author, or of the whole site, as an archive
+//
+//	go-blog export [-author username] [-format zip|tar] [-o file]
+func exportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	username := flags.String("author", "", "username of the author to export (default the whole site)")
+	format := flags.String("format", exporter.FormatZip, "format of the archive: zip or tar (gzip compressed)")
+	output := flags.String("o", "", `file written, "-" for the standard output (default goblog-<author or site>-<date>.zip or .tar.gz)`)
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go-blog export [flags]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if *format != exporter.FormatZip && *format != exporter.FormatTar {
+		fmt.Fprintln(os.Stderr, exporter.ErrUnknownFormat)
+		return 2
+	}
+
+	config.InitDB()
+	storage.InitBlobStore()
+
+	var authorId string
+	name := "site"
+
+	if *username != "" {
+		id, err := models.GetAuthorIdByUsername(*username)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "finding author %s: %v\n", *username, err)
+			return 1
+		}
+
+		authorId, name = id, *username
+	}
+
+	export, err := exporter.Collect(authorId)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading the content: %v\n", err)
+		return 1
+	}
+
+	path := *output
+
+	if path == "" {
+		path = exporter.Filename(name, *format, time.Now())
+	}
+
+	file := os.Stdout
+
+	if path != "-" {
+		if file, err = os.Create(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	err = exporter.Write(file, export, *format)
+
+	if path != "-" {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "writing %s: %v\n", path, err)
+		return 1
+	}
+
+	if path != "-" {
+		fmt.Fprintf(os.Stderr, "%s: %d posts\n", path, len(export.Posts))
+	}
+
+	return 0
+}
+
+// buildCommand renders the website into a directory of static files, only the pages that changed
+// since the last build in it
+//
+//	go-blog build [-out dir] [-force]
+func buildCommand(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	out := flags.String("out", "public", "directory the site is written to")
+	force := flags.Bool("force", false, "render every page, even unchanged ones")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go-blog build [flags]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	config.InitDB()
+	storage.InitBlobStore()
+	website.InitTheme()
+
+	report, err := staticsite.Build(staticsite.Options{Out: *out, Force: *force})
+
+	for _, page := range report.Rendered {
+		fmt.Println("rendered", page)
+	}
+
+	for _, page := range report.Removed {
+		fmt.Println("removed ", page)
+	}
+
+	for _, tag := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "tag %q skipped, it can't be a directory\n", tag)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "build stopped: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("%s: %d rendered, %d unchanged, %d removed, %d uploaded files copied\n", *out, len(report.Rendered), report.Unchanged, len(report.Removed), report.Files)
+
+	return 0
+}
+
+// printImportReport prints a line per post and the totals of report
+func printImportReport(report types.ImportReport) {
+	for _, result := range report.Results {
+		line := fmt.Sprintf("%-8s %s %q", result.Status, result.ExternalId, result.Title)
+
+		if result.Author != "" {
+			line += " by " + result.Author
+		}
+
+		if result.PostId != "" {
+			line += " -> " + result.PostId
+		}
+
+		if result.Reason != "" {
+			line += ": " + result.Reason
+		}
+
+		fmt.Println(line)
+	}
+
+	if len(report.AuthorsCreated) > 0 {
+		fmt.Printf("authors created: %s\n", strings.Join(report.AuthorsCreated, ", "))
+	}
+
+	fmt.Printf("%s: %d created, %d existing, %d skipped, %d failed", report.Source, report.Created, report.Existing, report.Skipped, report.Failed)
+
+	if report.DryRun {
+		fmt.Print(" (dry run, nothing written)")
+	}
+
+	fmt.Println()
+}
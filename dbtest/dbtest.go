@@ -0,0 +1,357 @@
+// Package dbtest provides a scripted database for tests of code querying config.DB
+//
+// Queries are answered by the first rule whose fragment they contain, with the rows (positional
+// values) or error of the rule. Queries matching no rule fail, so tests notice queries they didn't
+// expect. Transactions are accepted and have no effect.
+//
+//	db := dbtest.Open(t)
+//	db.On("FROM authors WHERE author_id=").Rows(dbtest.Row("sam", createdAt))
+//	db.On("INSERT INTO authors").Once().Err(&pq.Error{Code: "23505", Constraint: "authors_username_key"})
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+)
+
+// DB is a scripted database
+type DB struct {
+	mu      sync.Mutex
+	rules   []*Rule
+	queries []Query
+}
+
+// Query is a query run against a DB, with its arguments
+type Query struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// Rule answers the queries containing a fragment
+type Rule struct {
+	db       *DB
+	fragment string
+	rows     [][]driver.Value
+	err      error
+	affected int64
+	once     bool
+}
+
+var (
+	registerOnce sync.Once
+	databases    sync.Map // by data source name
+	opened       int
+	openedMutex  sync.Mutex
+)
+
+// Open returns an empty scripted database and makes it config.DB until the end of the test
+func Open(t testing.TB) *DB {
+	registerOnce.Do(func() { sql.Register("dbtest", scriptedDriver{}) })
+
+	openedMutex.Lock()
+	opened++
+	name := "db" + strconv.Itoa(opened)
+	openedMutex.Unlock()
+
+	db := &DB{}
+	databases.Store(name, db)
+
+	conn, err := sql.Open("dbtest", name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.DB
+	config.DB = conn
+
+	t.Cleanup(func() {
+		config.DB = previous
+		conn.Close()
+		databases.Delete(name)
+	})
+
+	return db
+}
+
+// DB's On adds a rule answering the queries containing fragment, by default with no rows and one
+// affected row
+func (db *DB) On(fragment string) *Rule {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rule := &Rule{db: db, fragment: fragment, affected: 1}
+	db.rules = append(db.rules, rule)
+
+	return rule
+}
+
+// DB's Queries returns the queries run so far, in order
+func (db *DB) Queries() []Query {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return append([]Query(nil), db.queries...)
+}
+
+// DB's Ran returns the queries run so far containing fragment
+func (db *DB) Ran(fragment string) []Query {
+	var result []Query
+
+	for _, query := range db.Queries() {
+		if strings.Contains(query.SQL, fragment) {
+			result = append(result, query)
+		}
+	}
+
+	return result
+}
+
+// Row returns the values of a row, ints are stored as int64 like drivers do
+func Row(values ...interface{}) []driver.Value {
+	row := make([]driver.Value, len(values))
+
+	for index, value := range values {
+		switch value := value.(type) {
+		case int:
+			row[index] = int64(value)
+		case []string:
+			// PostgreSQL arrays as lib/pq reads them
+			row[index] = "{" + strings.Join(value, ",") + "}"
+		default:
+			row[index] = value
+		}
+	}
+
+	return row
+}
+
+// Rule's Rows makes the rule answer with rows
+func (r *Rule) Rows(rows ...[]driver.Value) *Rule {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.rows = rows
+
+	return r
+}
+
+// Rule's Err makes the rule fail with err
+func (r *Rule) Err(err error) *Rule {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.err = err
+
+	return r
+}
+
+// Rule's Affected sets the number of rows statements matching the rule affect
+func (r *Rule) Affected(count int64) *Rule {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.affected = count
+
+	return r
+}
+
+// Rule's Once makes the rule answer only the first query matching it
+func (r *Rule) Once() *Rule {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.once = true
+
+	return r
+}
+
+// DB's answer records a query and returns the rule answering it
+func (db *DB) answer(query string, args []driver.NamedValue) (*Rule, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	values := make([]driver.Value, len(args))
+
+	for index, arg := range args {
+		values[index] = arg.Value
+	}
+
+	db.queries = append(db.queries, Query{SQL: query, Args: values})
+
+	for index, rule := range db.rules {
+		if !strings.Contains(query, rule.fragment) {
+			continue
+		}
+
+		if rule.once {
+			db.rules = append(db.rules[:index:index], db.rules[index+1:]...)
+		}
+
+		if rule.err != nil {
+			return nil, rule.err
+		}
+
+		return rule, nil
+	}
+
+	return nil, fmt.Errorf("dbtest: unexpected query %s", strings.Join(strings.Fields(query), " "))
+}
+
+// scriptedDriver opens the DBs registered by Open
+type scriptedDriver struct{}
+
+func (scriptedDriver) Open(name string) (driver.Conn, error) {
+	db, ok := databases.Load(name)
+
+	if !ok {
+		return nil, fmt.Errorf("dbtest: unknown database %s", name)
+	}
+
+	return &conn{db: db.(*DB)}, nil
+}
+
+// conn is a connection to a DB
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rule, err := c.db.answer(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{values: rule.rows}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rule, err := c.db.answer(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(rule.affected), nil
+}
+
+// CheckNamedValue accepts the values drivers accept, and the values of database/sql's Valuers
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	var err error
+
+	if valuer, ok := value.Value.(driver.Valuer); ok {
+		value.Value, err = valuer.Value()
+		return err
+	}
+
+	switch converted := value.Value.(type) {
+	case time.Time, nil:
+	default:
+		value.Value, err = driver.DefaultParameterConverter.ConvertValue(converted)
+	}
+
+	return err
+}
+
+// stmt is a query prepared on a conn
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+// named returns args as named values
+func named(args []driver.Value) []driver.NamedValue {
+	result := make([]driver.NamedValue, len(args))
+
+	for index, arg := range args {
+		result[index] = driver.NamedValue{Ordinal: index + 1, Value: arg}
+	}
+
+	return result
+}
+
+// tx is a transaction of a conn, which has no effect
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+// rows are the rows of a rule
+type rows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	if len(r.values) == 0 {
+		// any number of columns, no row is scanned
+		return nil
+	}
+
+	columns := make([]string, len(r.values[0]))
+
+	for index := range columns {
+		columns[index] = "column" + strconv.Itoa(index+1)
+	}
+
+	return columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package events publishes changes of posts and authors to the parts of the app reacting to them,
+// such as webhooks
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+)
+
+// Event is something that happened to a post or an author
+type Event struct {
+	Id        string      `json:"id"`         // event's ID, IDs of later events sort after earlier ones
+	Type      string      `json:"type"`       // one of types.EventTypes
+	CreatedAt time.Time   `json:"created_at"` // when it happened
+	Data      interface{} `json:"data"`       // the post or author concerned
+}
+
+// Bus delivers published events to its subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(Event)
+	next        int
+}
+
+// Bus's Subscribe calls f with every event published from now on until the returned function is
+// called
+//
+// f is called by Publish, in the publisher's goroutine while the bus is locked for reading. It must
+// return quickly, without I/O, and must not subscribe or unsubscribe. Slow work is handed off to a
+// goroutine of the subscriber, e.g. through a buffered channel.
+func (b *Bus) Subscribe(f func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = map[int]func(Event){}
+	}
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = f
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, id)
+	}
+}
+
+// Bus's Active reports if anyone is subscribed, so that publishers can skip loading data
+func (b *Bus) Active() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers) > 0
+}
+
+// Bus's Publish passes an event of eventType about data to every subscriber
+func (b *Bus) Publish(eventType string, data interface{}) {
+	id, err := helpers.NewId()
+
+	if err != nil {
+		log.Printf("events: dropping %s: %v", eventType, err)
+		return
+	}
+
+	event := Event{Id: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, f := range b.subscribers {
+		f(event)
+	}
+}
+
+// bus the models publish to
+var Default = new(Bus)
@@ -0,0 +1,301 @@
+// Package exporter writes the content of an author, or of the whole site, as an archive of
+// Markdown files with front matter, a JSON manifest and the uploaded files, which package importer
+// reads back
+package exporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// formats of archives
+const (
+	FormatZip = "zip" // ZIP archive
+	FormatTar = "tar" // gzip compressed tar archive
+)
+
+var ErrUnknownFormat = errors.New("unknown format, expected zip or tar")
+
+// version of the JSON export written
+const exportVersion = 1
+
+// Collect reads the content of the author authorId, of the whole site if authorId is empty,
+// sql.ErrNoRows is returned if the author doesn't exist
+func Collect(authorId string) (types.Export, error) {
+	authors, err := models.GetExportAuthors(authorId)
+
+	if err != nil {
+		return types.Export{}, err
+	}
+
+	if authorId != "" && len(authors) == 0 {
+		return types.Export{}, sql.ErrNoRows
+	}
+
+	posts, err := models.GetExportPosts(authorId)
+
+	if err != nil {
+		return types.Export{}, err
+	}
+
+	attachments, err := models.GetExportAttachments(authorId)
+
+	if err != nil {
+		return types.Export{}, err
+	}
+
+	export := types.Export{Version: exportVersion, Authors: authors, Tags: []string{}, Posts: []types.ExportPost{}, Attachments: attachments}
+	tags := map[string]bool{}
+
+	for _, post := range posts {
+		export.Posts = append(export.Posts, types.ExportPost{
+			Id:        post.Id,
+			Title:     post.Title,
+			Body:      post.Body,
+			Tags:      post.Tags,
+			Author:    post.AuthorInfo.Username,
+			CreatedAt: post.CreatedAt,
+			UpdatedAt: post.UpdatedAt,
+		})
+
+		for _, tag := range post.Tags {
+			tags[tag] = true
+		}
+	}
+
+	for tag := range tags {
+		export.Tags = append(export.Tags, tag)
+	}
+
+	sort.Strings(export.Tags)
+
+	return export, nil
+}
+
+// Filename returns the name of the archive of name's content exported at date in format
+func Filename(name, format string, date time.Time) string {
+	extension := ".zip"
+
+	if format == FormatTar {
+		extension = ".tar.gz"
+	}
+
+	return "goblog-" + name + "-" + date.UTC().Format("20060102") + extension
+}
+
+// archive is written file by file
+type archive interface {
+	// create adds a file of size bytes, it's written before the next file is created
+	create(name string, size int64, modTime time.Time) (io.Writer, error)
+	close() error
+}
+
+// Write writes export to w as an archive in format: the manifest, a Markdown file per post and the
+// files of the attachments read from storage.Default
+//
+// Attachments whose files are missing from the store are left out of the manifest.
+func Write(w io.Writer, export types.Export, format string) error {
+	var files archive
+
+	switch format {
+	case FormatZip:
+		files = &zipArchive{writer: zip.NewWriter(w)}
+	case FormatTar:
+		compressed := gzip.NewWriter(w)
+		files = &tarArchive{writer: tar.NewWriter(compressed), compressed: compressed}
+	default:
+		return ErrUnknownFormat
+	}
+
+	now := time.Now()
+
+	for _, post := range export.Posts {
+		content := Markdown(post)
+
+		if err := writeFile(files, types.ExportPostsDir+post.Id+".md", []byte(content), post.UpdatedAt); err != nil {
+			return err
+		}
+	}
+
+	attachments := make([]types.ExportAttachment, 0, len(export.Attachments))
+	blobs := &blobCopier{files: files, copied: map[string]bool{}}
+
+	for _, attachment := range export.Attachments {
+		found, err := blobs.copy(attachment.File)
+
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			log.Printf("exporting attachment %s: %s is missing from the store", attachment.Id, attachment.File)
+			continue
+		}
+
+		if attachment.Thumbnail != "" {
+			if found, err = blobs.copy(attachment.Thumbnail); err != nil {
+				return err
+			}
+
+			if !found {
+				attachment.Thumbnail = ""
+			}
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	// last, as it only lists the attachments written
+	export.Attachments = attachments
+
+	manifest, err := json.MarshalIndent(export, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err = writeFile(files, types.ExportManifest, manifest, now); err != nil {
+		return err
+	}
+
+	return files.close()
+}
+
+// writeFile adds a file holding data to files
+func writeFile(files archive, name string, data []byte, modTime time.Time) error {
+	writer, err := files.create(name, int64(len(data)), modTime)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+
+	return err
+}
+
+// blobCopier adds blobs to an archive, each once as identical uploads share a blob
+type blobCopier struct {
+	files  archive
+	copied map[string]bool
+}
+
+// blobCopier's copy adds the blob named name in the archive (media/<key>), false is returned if
+// the store has no such blob
+func (c *blobCopier) copy(name string) (bool, error) {
+	if c.copied[name] {
+		return true, nil
+	}
+
+	blob, info, err := storage.Default.Get(strings.TrimPrefix(name, types.ExportMediaDir))
+
+	if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer blob.Close()
+
+	writer, err := c.files.create(name, info.Size, info.ModTime)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = io.Copy(writer, blob); err != nil {
+		return false, err
+	}
+
+	c.copied[name] = true
+
+	return true, nil
+}
+
+// Markdown returns post as a Markdown file with YAML front matter, as read by package importer
+func Markdown(post types.ExportPost) string {
+	var builder strings.Builder
+
+	builder.WriteString("---\n")
+	builder.WriteString("id: " + yamlString(post.Id) + "\n")
+	builder.WriteString("title: " + yamlString(post.Title) + "\n")
+	builder.WriteString("author: " + yamlString(post.Author) + "\n")
+	builder.WriteString("date: " + post.CreatedAt.UTC().Format(time.RFC3339) + "\n")
+	builder.WriteString("lastmod: " + post.UpdatedAt.UTC().Format(time.RFC3339) + "\n")
+
+	if post.Slug != "" {
+		builder.WriteString("slug: " + yamlString(post.Slug) + "\n")
+	}
+
+	tags := make([]string, len(post.Tags))
+
+	for index, tag := range post.Tags {
+		tags[index] = yamlString(tag)
+	}
+
+	builder.WriteString("tags: [" + strings.Join(tags, ", ") + "]\n")
+	builder.WriteString("---\n\n")
+	builder.WriteString(post.Body)
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// yamlString returns value as a double quoted YAML string, JSON strings are valid ones
+func yamlString(value string) string {
+	quoted, _ := json.Marshal(value)
+
+	return string(quoted)
+}
+
+// ZIP archive
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (a *zipArchive) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	return a.writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+}
+
+func (a *zipArchive) close() error {
+	return a.writer.Close()
+}
+
+// gzip compressed tar archive
+type tarArchive struct {
+	writer     *tar.Writer
+	compressed *gzip.Writer
+}
+
+func (a *tarArchive) create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Size: size, Mode: 0644, ModTime: modTime}
+
+	if err := a.writer.WriteHeader(header); err != nil {
+		return nil, err
+	}
+
+	return a.writer, nil
+}
+
+func (a *tarArchive) close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+
+	return a.compressed.Close()
+}
@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// prefix of every API key so leaked keys are easy to recognise, e.g. by secret scanners
+const apiKeyPrefix = "gbk_"
+
+// number of characters of a key stored in clear to let authors tell their keys apart
+const apiKeyVisibleLength = 12
+
+// GenerateAPIKey creates a random API key and returns it with its visible prefix and the hash under
+// which it should be stored
+//
+// Result: gbk_Zm9vYmFy... gbk_Zm9vYmFy 5e2b...
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	if key, err = NewSecret(apiKeyPrefix); err != nil {
+		return "", "", "", err
+	}
+
+	return key, key[:apiKeyVisibleLength], HashAPIKey(key), nil
+}
+
+// NewSecret returns prefix followed by 32 random bytes encoded in base64url
+func NewSecret(prefix string) (string, error) {
+	random := make([]byte, 32)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// HashAPIKey returns the hash under which key is stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports if key looks like a key created by GenerateAPIKey
+func IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix) && len(key) > apiKeyVisibleLength
+}
+
+// ScopeAllows reports if a key with scopes may be used for an action requiring scope, admin
+// allows everything and write:posts allows reading
+func ScopeAllows(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope || granted == types.ScopeAdmin || (granted == types.ScopeWritePosts && scope == types.ScopeRead) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// key of the authenticated API key in a request's context
+type apiKeyContextKey struct{}
+
+// authenticated API key of a request
+type apiKeyAuth struct {
+	keyId    string
+	authorId string
+	scopes   []string
+}
+
+// WithAPIKey returns req authenticated as authorId by the API key keyId having scopes
+func WithAPIKey(req *http.Request, keyId, authorId string, scopes []string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), apiKeyContextKey{}, apiKeyAuth{keyId: keyId, authorId: authorId, scopes: scopes}))
+}
+
+// GetAPIKeyId returns the ID of the API key req was authenticated with, empty if none
+func GetAPIKeyId(req *http.Request) string {
+	auth, _ := req.Context().Value(apiKeyContextKey{}).(apiKeyAuth)
+
+	return auth.keyId
+}
+
+// APIKeyAllows reports if req may be used for an action requiring scope, requests that weren't
+// authenticated with an API key always may
+func APIKeyAllows(req *http.Request, scope string) bool {
+	auth, ok := req.Context().Value(apiKeyContextKey{}).(apiKeyAuth)
+
+	return !ok || ScopeAllows(auth.scopes, scope)
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RevisionETag returns the strong entity tag of a resource's revision
+func RevisionETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// IfMatchRevisions returns the revisions listed in the If-Match header of req
+//
+// nil is returned if any revision is acceptable, i.e. the header is missing or "*". ok is false if
+// the header can't match any revision, the request's precondition has failed then.
+func IfMatchRevisions(req *http.Request) (revisions []int, ok bool) {
+	header := strings.TrimSpace(req.Header.Get("If-Match"))
+
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// weak tags never match for If-Match
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+
+		if revision, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	return revisions, len(revisions) > 0
+}
@@ -2,8 +2,11 @@
 package helpers
 
 import (
-	"encoding/json"
-	"math/rand"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"net/http"
 	"os"
 	"path"
@@ -11,16 +14,21 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/samkit-jain/go-blog/types"
 )
 
-// CheckPasswordHash checks whether hash can be decrypted as password
+// CheckPasswordHash checks whether hash was created from password by any known hasher
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	for _, hasher := range knownHashers {
+		if hasher.Identify(hash) {
+			matched, err := hasher.Verify(password, hash)
 
-	return err == nil
+			return err == nil && matched
+		}
+	}
+
+	return false
 }
 
 // ShiftPath splits the URL path component into two segments where first segment is the portion
@@ -40,55 +48,91 @@ func ShiftPath(p string) (head, tail string) {
 	return p[1:i], p[i:]
 }
 
-// HashPassword encrypts a string
+// HashPassword encrypts a string using DefaultHasher
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-
-	if err != nil {
-		return "", err
-	}
-
-	return string(hash), nil
+	return DefaultHasher.Hash(password)
 }
 
-// GetAuthorIdFromHeader returns the value of key "token" in the request's header
+// GetAuthorIdFromHeader returns the author ID of the session token in the request's "token"
+// header or else of the API key the request was authenticated with
 func GetAuthorIdFromHeader(req *http.Request) string {
 	// get session token from HEADER
 	tokenString := req.Header.Get("token")
 
 	if tokenString == "" {
-		return ""
+		auth, _ := req.Context().Value(apiKeyContextKey{}).(apiKeyAuth)
+
+		return auth.authorId
 	}
 
+	return GetAuthorIdFromToken(tokenString)
+}
+
+// GetAuthorIdFromToken returns the author ID stored in a session token created by CreateToken,
+// empty if the token isn't valid
+func GetAuthorIdFromToken(tokenString string) string {
 	// parse token
-	token, _ := jwt.ParseWithClaims(tokenString, &types.CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &types.CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(os.Getenv("GOBLOG_SIGNING_KEY")), nil
 	})
 
+	// malformed tokens are returned as nil
+	if err != nil {
+		return ""
+	}
+
 	// checking for claims, not expired, valid, etc.
-	if claims, ok := token.Claims.(*types.CustomClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*types.CustomClaims); ok && token.Valid && claims.Issuer == "goblog" {
 		return claims.Id
 	}
 
-	// ParseWithClaims returned error
+	// not a valid session token
 	return ""
 }
 
-// RangeIn returns a random number between low and hi
-func RangeIn(low, hi int) int {
-	// varying seed makes sure rand's sequence is not fixed
-	seed := rand.NewSource(time.Now().UnixNano())
-	tempRand := rand.New(seed)
+// CreatePendingToken creates a JSON web token storing authorId of an author who has entered the
+// correct password but not yet the second factor, it expires after 5 minutes and can't be used as
+// a session token
+func CreatePendingToken(authorId string) (string, error) {
+	claims := types.CustomClaims{
+		Id: authorId,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute * 5).Unix(),
+			Issuer:    "goblog-2fa",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(os.Getenv("GOBLOG_SIGNING_KEY")))
+}
+
+// GetAuthorIdFromPendingToken returns the authorId stored by CreatePendingToken
+func GetAuthorIdFromPendingToken(tokenString string) string {
+	token, err := jwt.ParseWithClaims(tokenString, &types.CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("GOBLOG_SIGNING_KEY")), nil
+	})
+
+	if err != nil {
+		return ""
+	}
+
+	if claims, ok := token.Claims.(*types.CustomClaims); ok && token.Valid && claims.Issuer == "goblog-2fa" {
+		return claims.Id
+	}
 
-	return low + tempRand.Intn(hi-low)
+	return ""
 }
 
-// CreateToken creates a JSON web token storing authorId that expires after 24 hours
+// validity of session tokens
+const TokenLifetime = time.Hour * 24
+
+// CreateToken creates a JSON web token storing authorId that expires after TokenLifetime
 func CreateToken(authorId string) (string, error) {
 	claims := types.CustomClaims{
 		Id: authorId,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
+			ExpiresAt: time.Now().Add(TokenLifetime).Unix(),
 			Issuer:    "goblog",
 		},
 	}
@@ -98,6 +142,88 @@ func CreateToken(authorId string) (string, error) {
 	return token.SignedString([]byte(os.Getenv("GOBLOG_SIGNING_KEY")))
 }
 
+// BaseURL returns the public URL the app is reachable at, used for building links in emails
+func BaseURL() string {
+	if url := os.Getenv("GOBLOG_BASE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+
+	return "http://localhost:8080"
+}
+
+// CreateSignedToken creates a random token for purpose, signed with the app's signing key, and the
+// hash under which it should be stored
+//
+// Result: <random>.<signature> 5e2b...
+func CreateSignedToken(purpose string) (token, hash string, err error) {
+	random := make([]byte, 32)
+
+	if _, err = rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(random)
+	sum := sha256.Sum256(random)
+
+	return encoded + "." + signToken(purpose, encoded), hex.EncodeToString(sum[:]), nil
+}
+
+// ParseSignedToken checks the signature of a token created by CreateSignedToken for the same
+// purpose and returns the hash under which it was stored
+func ParseSignedToken(token, purpose string) (string, bool) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signToken(purpose, parts[0]))) {
+		return "", false
+	}
+
+	random, err := base64.RawURLEncoding.DecodeString(parts[0])
+
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(random)
+
+	return hex.EncodeToString(sum[:]), true
+}
+
+// signToken returns the HMAC of value bound to purpose
+func signToken(purpose, value string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("GOBLOG_SIGNING_KEY")))
+	mac.Write([]byte(purpose + "." + value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NormaliseTags lowercases, trims and de-duplicates tags, dropping empty ones and limiting them to
+// 64 characters
+func NormaliseTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if runes := []rune(tag); len(runes) > 64 {
+			tag = string(runes[:64])
+		}
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		result = append(result, tag)
+	}
+
+	return result
+}
+
 // CreatePostWithoutAuthor removes the Author field from the array of Post
 func CreatePostWithoutAuthor(content types.AuthorPosts) interface{} {
 	type customAuthor struct {
@@ -112,6 +238,7 @@ func CreatePostWithoutAuthor(content types.AuthorPosts) interface{} {
 		Body      string    `json:"body"`
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
+		Tags      []string  `json:"tags"`
 	}
 
 	type customStruct struct {
@@ -135,68 +262,68 @@ func CreatePostWithoutAuthor(content types.AuthorPosts) interface{} {
 		returnVal.List[index].Body = item.Body
 		returnVal.List[index].CreatedAt = item.CreatedAt
 		returnVal.List[index].UpdatedAt = item.UpdatedAt
+		returnVal.List[index].Tags = item.Tags
 	}
 
 	return returnVal
 }
 
-// MethodNotAllowedResponse returns a JSON response indicating requested URL cannot be queried with
-// the given method type
+// MethodNotAllowedResponse returns a problem indicating requested URL cannot be queried with the
+// given method type
 //
 // Example - Doing a POST on a GET only URL
 func MethodNotAllowedResponse(res http.ResponseWriter) {
-	// set response header's content-type
-	res.Header().Set("Content-Type", "application/json")
-
-	res.WriteHeader(http.StatusMethodNotAllowed)
-	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: "Method not allowed!"})
+	ProblemResponse(res, NewProblem(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed!"))
 
 	return
 }
 
-// NotFoundResponse returns a JSON response indicating requested URL could not be found
+// NotFoundResponse returns a problem indicating requested URL could not be found
 func NotFoundResponse(res http.ResponseWriter) {
-	// set response header's content-type
-	res.Header().Set("Content-Type", "application/json")
-
-	res.WriteHeader(http.StatusNotFound)
-	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: "URL not found!"})
+	ProblemResponse(res, NewProblem(http.StatusNotFound, CodeNotFound, "URL not found!"))
 
 	return
 }
 
-// ForbiddenResponse returns a JSON response indicating token provided was invalid or authorId in
-// the token didn't match with the postId provided.
+// ForbiddenResponse returns a problem indicating the logged in author isn't allowed to do what was
+// requested
 //
-// Example - Session expired or post's author and logged in author mismatch
-func ForbiddenResponse(res http.ResponseWriter) {
-	// set response header's content-type
-	res.Header().Set("Content-Type", "application/json")
-
-	res.WriteHeader(http.StatusForbidden)
-	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: "Not logged in!"})
+// Example - post's author and logged in author mismatch
+func ForbiddenResponse(res http.ResponseWriter, message string) {
+	ProblemResponse(res, NewProblem(http.StatusForbidden, CodeForbidden, message))
 
 	return
 }
 
-// InternalServerError returns a JSON response indicating that some unexpected error occurred
-func InternalServerErrorResponse(res http.ResponseWriter, message string) {
-	// set response header's content-type
-	res.Header().Set("Content-Type", "application/json")
+// InternalServerError returns a problem indicating that some unexpected error occurred, err is
+// logged but not sent to the client
+func InternalServerErrorResponse(res http.ResponseWriter, err error) {
+	logInternalError(err)
 
-	res.WriteHeader(http.StatusInternalServerError)
-	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: message})
+	ProblemResponse(res, NewProblem(http.StatusInternalServerError, CodeInternalError, "An unexpected error occurred!"))
 
 	return
 }
 
-// BadRequestResponse returns a JSON response indicating that some unexpected error occurred
+// BadRequestResponse returns a problem indicating that the request was malformed
 func BadRequestResponse(res http.ResponseWriter, message string) {
-	// set response header's content-type
-	res.Header().Set("Content-Type", "application/json")
+	ProblemResponse(res, NewProblem(http.StatusBadRequest, CodeBadRequest, message))
+
+	return
+}
+
+// RequestEntityTooLargeResponse returns a problem indicating that the request body exceeded the
+// allowed size
+func RequestEntityTooLargeResponse(res http.ResponseWriter, message string) {
+	ProblemResponse(res, NewProblem(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, message))
+
+	return
+}
 
-	res.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(res).Encode(types.DefaultResponse{Status: "failure", Message: message})
+// UnsupportedMediaTypeResponse returns a problem indicating that the type of the request body isn't
+// accepted
+func UnsupportedMediaTypeResponse(res http.ResponseWriter, message string) {
+	ProblemResponse(res, NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, message))
 
 	return
 }
@@ -0,0 +1,221 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CachePolicy maps path prefixes to the Cache-Control header of their responses, the longest
+// matching prefix wins
+type CachePolicy map[string]string
+
+// policy used by CacheHandler, extended or overridden by GOBLOG_CACHE_CONTROL
+var DefaultCachePolicy = CachePolicy{
+	"/":      "public, max-age=60, s-maxage=300",
+	"/api/":  "no-cache",
+	"/auth/": "no-store",
+}
+
+// InitCachePolicy reads GOBLOG_CACHE_CONTROL, a ";" separated list of prefix=Cache-Control pairs,
+// e.g. "/post/=public, max-age=600;/api/=no-store", an empty value removes the prefix's policy
+func InitCachePolicy() {
+	for _, entry := range strings.Split(os.Getenv("GOBLOG_CACHE_CONTROL"), ";") {
+		prefix, value, ok := strings.Cut(entry, "=")
+
+		if !ok {
+			continue
+		}
+
+		prefix = strings.TrimSpace(prefix)
+
+		if value = strings.TrimSpace(value); value == "" {
+			delete(DefaultCachePolicy, prefix)
+		} else {
+			DefaultCachePolicy[prefix] = value
+		}
+	}
+}
+
+// CachePolicy's For returns the Cache-Control header for path, empty if no prefix matches
+func (p CachePolicy) For(path string) string {
+	match := ""
+
+	for prefix := range p {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+
+	if match == "" {
+		return ""
+	}
+
+	return p[match]
+}
+
+// CacheHandler adds validators and caching headers to successful GET responses of h
+//
+// Responses get the Cache-Control header of their path unless h sets one or a cookie. Responses
+// without an ETag are buffered and tagged with a hash of their body. Requests whose If-None-Match
+// or If-Modified-Since match the ETag or Last-Modified of the response are answered with 304.
+// Event streams are passed through as they are.
+func CacheHandler(policy CachePolicy, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			h.ServeHTTP(res, req)
+			return
+		}
+
+		writer := &cacheWriter{ResponseWriter: res, req: req, policy: policy.For(req.URL.Path)}
+
+		h.ServeHTTP(writer, req)
+
+		writer.finish()
+	})
+}
+
+// cacheWriter decides at the response's status whether it's passed through, answered with 304 or
+// buffered to be tagged
+type cacheWriter struct {
+	http.ResponseWriter
+	req       *http.Request
+	policy    string
+	status    int
+	buffer    bytes.Buffer
+	buffering bool // body is buffered until finish
+	discard   bool // 304 has been sent, the body is dropped
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+
+	w.status = status
+	header := w.Header()
+
+	if status != http.StatusOK || strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.policy != "" && header.Get("Cache-Control") == "" && header.Get("Set-Cookie") == "" {
+		header.Set("Cache-Control", w.policy)
+	}
+
+	if header.Get("ETag") == "" {
+		w.buffering = true
+		return
+	}
+
+	if notModified(w.req, header) {
+		w.notModified()
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return len(data), nil
+	}
+
+	if w.buffering {
+		return w.buffer.Write(data)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush sends what has been written so far unless the response is buffered
+func (w *cacheWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.buffering && !w.discard {
+		http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish tags and sends a buffered response
+func (w *cacheWriter) finish() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.buffering {
+		return
+	}
+
+	sum := sha256.Sum256(w.buffer.Bytes())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	if notModified(w.req, w.Header()) {
+		w.notModified()
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buffer.Bytes())
+}
+
+// notModified sends 304 instead of the response
+func (w *cacheWriter) notModified() {
+	w.discard = true
+	w.buffering = false
+
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+// notModified reports if the client's cached copy of a response with header is still fresh
+//
+// If-None-Match takes precedence over If-Modified-Since (RFC 7232 section 6).
+func notModified(req *http.Request, header http.Header) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		return etagListMatches(match, header.Get("ETag"))
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+
+	return err == nil && !modified.After(since)
+}
+
+// etagListMatches reports if etag is in list, compared weakly as If-None-Match requires
+func etagListMatches(list, etag string) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, tag := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,183 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdGenerator creates unique IDs for authors and posts, IDs created later sort after earlier ones
+type IdGenerator interface {
+	NewId() (string, error)
+}
+
+// Crockford's base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator creates 26 character ULIDs (48 bit millisecond timestamp and 80 random bits)
+//
+// IDs created within the same millisecond increment the random part so they stay ordered.
+type ULIDGenerator struct {
+	mu     sync.Mutex
+	lastMs uint64
+	last   [10]byte
+}
+
+// ULIDGenerator's NewId returns a new ULID
+//
+// Result: 01HZX3J8Q8T0N6V3W6Y9KQ2M4R
+func (g *ULIDGenerator) NewId() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+
+	if ms <= g.lastMs {
+		// same millisecond (or clock went back), increment the previous random part
+		ms = g.lastMs
+
+		i := len(g.last) - 1
+
+		for ; i >= 0; i-- {
+			g.last[i]++
+
+			if g.last[i] != 0 {
+				break
+			}
+		}
+
+		if i < 0 {
+			return "", errors.New("ULID random part overflowed within a millisecond")
+		}
+	} else if _, err := rand.Read(g.last[:]); err != nil {
+		return "", err
+	}
+
+	g.lastMs = ms
+
+	var id [16]byte
+
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> uint(40-8*i))
+	}
+
+	copy(id[6:], g.last[:])
+
+	return encodeULID(id), nil
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, most significant first
+func encodeULID(id [16]byte) string {
+	var b [26]byte
+
+	// 130 bits of output, the first character only carries 3 bits
+	var (
+		acc  uint
+		bits uint = 2
+	)
+
+	j := 0
+
+	for _, value := range id {
+		acc = acc<<8 | uint(value)
+		bits += 8
+
+		for bits >= 5 {
+			bits -= 5
+			b[j] = crockford[(acc>>bits)&31]
+			j++
+		}
+	}
+
+	return string(b[:])
+}
+
+// SnowflakeGenerator creates 64 bit numeric IDs (41 bit millisecond timestamp since Epoch, 10 bit
+// node and 12 bit sequence), Node must be unique among running instances
+type SnowflakeGenerator struct {
+	Node  int64     // ID of this instance, 0 to 1023
+	Epoch time.Time // start of the timestamp
+
+	mu       sync.Mutex
+	lastMs   int64
+	sequence int64
+}
+
+// SnowflakeGenerator's NewId returns a new Snowflake ID in decimal
+func (g *SnowflakeGenerator) NewId() (string, error) {
+	if g.Node < 0 || g.Node > 1023 {
+		return "", errors.New("Snowflake node must be between 0 and 1023")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Since(g.Epoch).Nanoseconds() / int64(time.Millisecond)
+
+	if ms < g.lastMs {
+		// clock went back, carry on from the last timestamp
+		ms = g.lastMs
+	}
+
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & 4095
+
+		// sequence exhausted, wait for the next millisecond
+		if g.sequence == 0 {
+			for ms <= g.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = time.Since(g.Epoch).Nanoseconds() / int64(time.Millisecond)
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+
+	g.lastMs = ms
+
+	return strconv.FormatInt(ms<<22|g.Node<<12|g.sequence, 10), nil
+}
+
+// generator used by NewId
+var DefaultIdGenerator IdGenerator = new(ULIDGenerator)
+
+// InitIdGenerator sets up DefaultIdGenerator from GOBLOG_ID_GENERATOR ("ulid" or "snowflake"),
+// Snowflake IDs use GOBLOG_NODE_ID as the node
+func InitIdGenerator() {
+	switch os.Getenv("GOBLOG_ID_GENERATOR") {
+	case "", "ulid":
+		DefaultIdGenerator = new(ULIDGenerator)
+	case "snowflake":
+		DefaultIdGenerator = &SnowflakeGenerator{
+			Node:  int64(envInt("GOBLOG_NODE_ID", 0)),
+			Epoch: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}
+	default:
+		panic("unknown GOBLOG_ID_GENERATOR " + os.Getenv("GOBLOG_ID_GENERATOR"))
+	}
+}
+
+// NewId returns a new ID from DefaultIdGenerator
+func NewId() (string, error) {
+	return DefaultIdGenerator.NewId()
+}
+
+// IsValidId checks whether id could have been created by any generator, including the numeric IDs
+// of the random generator used before
+func IsValidId(id string) bool {
+	// ULID
+	if len(id) == 26 {
+		return id[0] <= '7' && strings.Trim(strings.ToUpper(id), crockford) == ""
+	}
+
+	// old random IDs (15 digits) and Snowflake IDs
+	if len(id) == 0 || len(id) > 20 {
+		return false
+	}
+
+	return strings.Trim(id, "0123456789") == ""
+}
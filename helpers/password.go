@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownHashFormat is returned when a stored hash wasn't created by any known hasher
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into self-describing strings in PHC format
+// ($<id>$<params>$<salt>$<hash>), bcrypt keeps its own $2b$<cost>$ form which PHC is based on
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password using the hasher's current parameters
+	Hash(password string) (string, error)
+
+	// Identify returns whether encoded was created by this algorithm
+	Identify(encoded string) bool
+
+	// Verify checks password against an encoded hash created by this algorithm
+	Verify(password, encoded string) (bool, error)
+
+	// Outdated returns whether encoded was created with parameters other than the current ones
+	Outdated(encoded string) bool
+}
+
+// BcryptHasher hashes passwords using bcrypt
+type BcryptHasher struct {
+	Cost int // work factor, between bcrypt.MinCost and bcrypt.MaxCost
+}
+
+// BcryptHasher's Hash returns the bcrypt hash of password
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// BcryptHasher's Identify recognises the $2a$, $2b$ and $2y$ prefixes
+func (h *BcryptHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// BcryptHasher's Verify checks password against a bcrypt hash
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// BcryptHasher's Outdated compares the cost stored in encoded with Cost
+func (h *BcryptHasher) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes passwords using argon2id
+type Argon2idHasher struct {
+	Memory  uint32 // memory in KiB
+	Time    uint32 // number of passes over the memory
+	Threads uint8  // degree of parallelism
+	SaltLen uint32 // length of the random salt in bytes
+	KeyLen  uint32 // length of the hash in bytes
+}
+
+// Argon2idHasher's Hash returns the PHC string of password
+//
+// Result: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Argon2idHasher's Identify recognises the $argon2id$ prefix
+func (h *Argon2idHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// Argon2idHasher's Verify checks password against a PHC string using the parameters stored in it
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+// Argon2idHasher's Outdated compares the parameters stored in encoded with the current ones
+func (h *Argon2idHasher) Outdated(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory || params.Time != h.Time || params.Threads != h.Threads ||
+		uint32(len(salt)) != h.SaltLen || uint32(len(key)) != h.KeyLen
+}
+
+// decodeArgon2id splits a PHC string into its parameters, salt and hash
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	var (
+		params  Argon2idHasher
+		version int
+	)
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	return params, salt, key, nil
+}
+
+// hasher used for new passwords
+var DefaultHasher PasswordHasher = &Argon2idHasher{Memory: 64 * 1024, Time: 3, Threads: 2, SaltLen: 16, KeyLen: 32}
+
+// hashers able to verify stored passwords, whichever is the default
+var knownHashers = []PasswordHasher{
+	&Argon2idHasher{},
+	&BcryptHasher{},
+}
+
+// InitPasswordHasher sets up DefaultHasher from GOBLOG_PASSWORD_HASHER ("argon2id" or "bcrypt")
+// and its parameters
+//
+// argon2id: GOBLOG_ARGON2_MEMORY (KiB, at least 8 per thread), GOBLOG_ARGON2_TIME (at least 1),
+// GOBLOG_ARGON2_THREADS (1 to 255)
+//
+// bcrypt: GOBLOG_BCRYPT_COST
+func InitPasswordHasher() {
+	switch os.Getenv("GOBLOG_PASSWORD_HASHER") {
+	case "bcrypt":
+		DefaultHasher = &BcryptHasher{Cost: envInt("GOBLOG_BCRYPT_COST", bcrypt.DefaultCost)}
+	case "", "argon2id":
+		memory := envInt("GOBLOG_ARGON2_MEMORY", 64*1024)
+		passes := envInt("GOBLOG_ARGON2_TIME", 3)
+		threads := envInt("GOBLOG_ARGON2_THREADS", 2)
+
+		// argon2.IDKey panics on these, better now than on the first signup
+		if passes < 1 {
+			panic("GOBLOG_ARGON2_TIME must be at least 1")
+		}
+
+		if threads < 1 || threads > math.MaxUint8 {
+			panic("GOBLOG_ARGON2_THREADS must be between 1 and 255")
+		}
+
+		if memory < 8*threads || int64(memory) > math.MaxUint32 {
+			panic("GOBLOG_ARGON2_MEMORY must be at least 8 KiB per thread, and below 4 TiB")
+		}
+
+		DefaultHasher = &Argon2idHasher{
+			Memory:  uint32(memory),
+			Time:    uint32(passes),
+			Threads: uint8(threads),
+			SaltLen: 16,
+			KeyLen:  32,
+		}
+	default:
+		panic("unknown GOBLOG_PASSWORD_HASHER " + os.Getenv("GOBLOG_PASSWORD_HASHER"))
+	}
+}
+
+// PasswordNeedsRehash returns whether hash should be replaced by one made with DefaultHasher
+func PasswordNeedsRehash(hash string) bool {
+	return !DefaultHasher.Identify(hash) || DefaultHasher.Outdated(hash)
+}
+
+// envInt returns the integer value of an environment variable or fallback if not set or invalid
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import "testing"
+
+func TestInitPasswordHasherRejects(t *testing.T) {
+	defer func(hasher PasswordHasher) { DefaultHasher = hasher }(DefaultHasher)
+
+	tests := map[string]map[string]string{
+		"no passes":          {"GOBLOG_ARGON2_TIME": "0"},
+		"no threads":         {"GOBLOG_ARGON2_THREADS": "0"},
+		"256 threads":        {"GOBLOG_ARGON2_THREADS": "256", "GOBLOG_ARGON2_MEMORY": "4096"},
+		"too little memory":  {"GOBLOG_ARGON2_THREADS": "4", "GOBLOG_ARGON2_MEMORY": "31"},
+		"negative memory":    {"GOBLOG_ARGON2_MEMORY": "-1"},
+		"memory above 4 TiB": {"GOBLOG_ARGON2_MEMORY": "4294967296"},
+		"unknown hasher":     {"GOBLOG_PASSWORD_HASHER": "md5"},
+	}
+
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+
+			defer func() {
+				if recover() == nil {
+					t.Error("InitPasswordHasher accepted the parameters")
+				}
+			}()
+
+			InitPasswordHasher()
+		})
+	}
+}
+
+func TestInitPasswordHasher(t *testing.T) {
+	defer func(hasher PasswordHasher) { DefaultHasher = hasher }(DefaultHasher)
+
+	t.Setenv("GOBLOG_ARGON2_MEMORY", "32")
+	t.Setenv("GOBLOG_ARGON2_TIME", "1")
+	t.Setenv("GOBLOG_ARGON2_THREADS", "4")
+
+	InitPasswordHasher()
+
+	hash, err := DefaultHasher.Hash("correct horse battery")
+
+	if err != nil || !DefaultHasher.Identify(hash) {
+		t.Fatalf("Hash returned %q, %v", hash, err)
+	}
+
+	if want := "$argon2id$v=19$m=32,t=1,p=4$"; hash[:len(want)] != want {
+		t.Errorf("hash %q doesn't start with %q", hash, want)
+	}
+}
@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// stable error codes of API problems, clients may rely on these
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeOTPRequired          = "otp_required"
+	CodeForbidden            = "forbidden"
+	CodeInvalidAPIKey        = "invalid_api_key"
+	CodeInsufficientScope    = "insufficient_scope"
+	CodeIdentityNotLinked    = "identity_not_linked"
+	CodeIdentityLinked       = "identity_already_linked"
+	CodeNotFound             = "not_found"
+	CodeAuthorNotFound       = "author_not_found"
+	CodePostNotFound         = "post_not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeUsernameTaken        = "username_taken"
+	CodeEmailTaken           = "email_taken"
+	CodeInvalidToken         = "invalid_token"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeRateLimited          = "rate_limited"
+	CodeTOTPEnabled          = "totp_already_enabled"
+	CodeTOTPNotEnrolled      = "totp_not_enrolled"
+	CodeTOTPLocked           = "totp_locked"
+	CodeRequestTooLarge      = "request_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeMalformedBody        = "malformed_body"
+	CodeInternalError        = "internal_error"
+)
+
+// NewProblem creates a problem with the type URI derived from code and the title from status
+func NewProblem(status int, code, detail string) types.Problem {
+	return types.Problem{
+		Type:   "urn:goblog:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// ProblemResponse returns an application/problem+json response
+func ProblemResponse(res http.ResponseWriter, problem types.Problem) {
+	res.Header().Set("Content-Type", "application/problem+json")
+
+	res.WriteHeader(problem.Status)
+	json.NewEncoder(res).Encode(problem)
+
+	return
+}
+
+// NewValidationProblem creates a 422 problem listing the invalid fields
+func NewValidationProblem(errors []types.FieldError) types.Problem {
+	problem := NewProblem(http.StatusUnprocessableEntity, CodeValidationFailed, "Some fields are invalid!")
+	problem.Errors = errors
+
+	return problem
+}
+
+// ValidationErrorResponse returns a 422 problem listing the invalid fields
+func ValidationErrorResponse(res http.ResponseWriter, errors []types.FieldError) {
+	ProblemResponse(res, NewValidationProblem(errors))
+
+	return
+}
+
+// ConflictResponse returns a 409 problem, e.g. when a unique value is already taken
+func ConflictResponse(res http.ResponseWriter, code, detail string) {
+	ProblemResponse(res, NewProblem(http.StatusConflict, code, detail))
+
+	return
+}
+
+// ResourceNotFoundResponse returns a 404 problem for a resource that doesn't exist
+func ResourceNotFoundResponse(res http.ResponseWriter, code, detail string) {
+	ProblemResponse(res, NewProblem(http.StatusNotFound, code, detail))
+
+	return
+}
+
+// UnauthorizedResponse returns a 401 problem indicating that no valid token was provided
+//
+// Example - Session expired or token missing
+func UnauthorizedResponse(res http.ResponseWriter) {
+	ProblemResponse(res, NewProblem(http.StatusUnauthorized, CodeUnauthorized, "Not logged in!"))
+
+	return
+}
+
+// PreconditionFailedResponse returns a 412 problem for a resource modified since the client read it
+func PreconditionFailedResponse(res http.ResponseWriter) {
+	ProblemResponse(res, NewProblem(http.StatusPreconditionFailed, CodePreconditionFailed, "The resource has been modified since it was read!"))
+
+	return
+}
+
+// logInternalError logs the details of an unexpected error, they are never sent to clients
+func logInternalError(err error) {
+	log.Printf("internal error: %v", err)
+}
@@ -0,0 +1,255 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// largest accepted JSON or form body in bytes, unless set with LimitBody
+const maxJSONBodySize = 1 << 20
+
+// body of a request limited by LimitBody
+type limitedBody struct {
+	io.ReadCloser
+}
+
+// LimitBody limits the body of req to n bytes, for routes taking larger bodies than DecodeRequest
+// otherwise accepts, reading past it fails with an *http.MaxBytesError
+func LimitBody(res http.ResponseWriter, req *http.Request, n int64) {
+	req.Body = &limitedBody{http.MaxBytesReader(res, req.Body, n)}
+}
+
+// RequestError is returned by DecodeRequest when the body can't be read into the request struct
+type RequestError struct {
+	Status  int    // HTTP status to respond with
+	Message string // message for the client
+}
+
+// RequestError's Error returns the message
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// DecodeRequest reads the body of req into dst, a pointer to a struct
+//
+// application/json bodies are decoded using the fields' json tags, unknown fields are rejected.
+// Form encoded bodies (and bodiless requests) are decoded using the fields' form tags, supporting
+// string, []string, bool and int fields. Any other content type is rejected with 415. Bodies
+// larger than 1 MiB, or than set with LimitBody, are rejected with 413.
+func DecodeRequest(res http.ResponseWriter, req *http.Request, dst interface{}) error {
+	contentType := req.Header.Get("Content-Type")
+	mediaType := ""
+
+	if contentType != "" {
+		var err error
+
+		mediaType, _, err = mime.ParseMediaType(contentType)
+
+		if err != nil {
+			return &RequestError{Status: http.StatusUnsupportedMediaType, Message: "Invalid Content-Type!"}
+		}
+	}
+
+	switch mediaType {
+	case "application/json":
+		return decodeJSON(res, req, dst)
+	case "", "application/x-www-form-urlencoded", "multipart/form-data":
+		return decodeForm(res, req, dst)
+	default:
+		return &RequestError{Status: http.StatusUnsupportedMediaType, Message: "Content-Type " + mediaType + " not supported!"}
+	}
+}
+
+// decodeJSON decodes a single JSON object from the body of req
+func decodeJSON(res http.ResponseWriter, req *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxJSONBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+
+	if err != nil {
+		var (
+			tooLarge  *http.MaxBytesError
+			typeError *json.UnmarshalTypeError
+		)
+
+		switch {
+		case errors.As(err, &tooLarge):
+			return &RequestError{Status: http.StatusRequestEntityTooLarge, Message: "Request body too large!"}
+		case errors.As(err, &typeError):
+			return &RequestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Field %q must be of type %s!", typeError.Field, typeError.Type)}
+		case err == io.EOF:
+			return &RequestError{Status: http.StatusBadRequest, Message: "Request body must not be empty!"}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return &RequestError{Status: http.StatusBadRequest, Message: "Unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ") + "!"}
+		default:
+			return &RequestError{Status: http.StatusBadRequest, Message: "Malformed JSON!"}
+		}
+	}
+
+	// only one object allowed
+	if decoder.Decode(&struct{}{}) != io.EOF {
+		return &RequestError{Status: http.StatusBadRequest, Message: "Request body must contain a single JSON object!"}
+	}
+
+	return nil
+}
+
+// DecodeMergePatch applies the JSON merge patch (RFC 7396) in the body of req to dst, a pointer to
+// a struct holding the current values
+//
+// Members are matched to the fields' json tags, members set to null reset their field to its zero
+// value. Bodies that aren't application/merge-patch+json or application/json are rejected with 415.
+func DecodeMergePatch(res http.ResponseWriter, req *http.Request, dst interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+		return &RequestError{Status: http.StatusUnsupportedMediaType, Message: "Content-Type must be application/merge-patch+json!"}
+	}
+
+	var (
+		body  json.RawMessage
+		patch map[string]json.RawMessage
+	)
+
+	if err := decodeJSON(res, req, &body); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(string(body), "{") || json.Unmarshal(body, &patch) != nil {
+		return &RequestError{Status: http.StatusBadRequest, Message: "Request body must be a JSON object!"}
+	}
+
+	value := reflect.ValueOf(dst).Elem()
+	fields := map[string]reflect.Value{}
+
+	for i := 0; i < value.NumField(); i++ {
+		if name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			fields[name] = value.Field(i)
+		}
+	}
+
+	for name, raw := range patch {
+		field, ok := fields[name]
+
+		if !ok {
+			return &RequestError{Status: http.StatusBadRequest, Message: "Unknown field \"" + name + "\"!"}
+		}
+
+		if string(raw) == "null" {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+
+		if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+			return &RequestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Field %q must be of type %s!", name, field.Type())}
+		}
+	}
+
+	return nil
+}
+
+// decodeForm copies form values (query string and body) into the fields of dst tagged with form
+func decodeForm(res http.ResponseWriter, req *http.Request, dst interface{}) error {
+	// file parts larger than the memory of ParseMultipartForm go to temporary files
+	if _, ok := req.Body.(*limitedBody); !ok {
+		req.Body = http.MaxBytesReader(res, req.Body, maxJSONBodySize)
+	}
+
+	if err := req.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			return &RequestError{Status: http.StatusRequestEntityTooLarge, Message: "Request body too large!"}
+		}
+
+		return &RequestError{Status: http.StatusBadRequest, Message: "Malformed form!"}
+	}
+
+	value := reflect.ValueOf(dst).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("form")
+
+		if name == "" {
+			continue
+		}
+
+		values, ok := req.Form[name]
+
+		if !ok {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.String:
+			value.Field(i).SetString(values[0])
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.String {
+				value.Field(i).Set(reflect.ValueOf(splitFormList(values)))
+			}
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(values[0])
+
+			if err != nil {
+				return &RequestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Field %q must be a boolean!", name)}
+			}
+
+			value.Field(i).SetBool(parsed)
+		case reflect.Int, reflect.Int64:
+			parsed, err := strconv.ParseInt(values[0], 10, 64)
+
+			if err != nil {
+				return &RequestError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Field %q must be an integer!", name)}
+			}
+
+			value.Field(i).SetInt(parsed)
+		}
+	}
+
+	return nil
+}
+
+// splitFormList accepts both repeated fields (tags=a&tags=b) and comma separated lists (tags=a,b)
+func splitFormList(values []string) []string {
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+
+	return result
+}
+
+// RequestErrorResponse returns a problem for an error returned by DecodeRequest
+func RequestErrorResponse(res http.ResponseWriter, err error) {
+	requestError, ok := err.(*RequestError)
+
+	if !ok {
+		InternalServerErrorResponse(res, err)
+		return
+	}
+
+	switch requestError.Status {
+	case http.StatusUnsupportedMediaType:
+		UnsupportedMediaTypeResponse(res, requestError.Message)
+	case http.StatusRequestEntityTooLarge:
+		RequestEntityTooLargeResponse(res, requestError.Message)
+	default:
+		ProblemResponse(res, NewProblem(requestError.Status, CodeMalformedBody, requestError.Message))
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// multipartRequest returns a request with a multipart body holding a title field and a file part
+// of size bytes
+func multipartRequest(t *testing.T, size int) *http.Request {
+	var body bytes.Buffer
+
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("title", "Hello")
+	part, err := writer.CreateFormFile("file", "export.zip")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part.Write(make([]byte, size))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func TestDecodeRequestFormSize(t *testing.T) {
+	var dst struct {
+		Title string `form:"title"`
+	}
+
+	err := DecodeRequest(httptest.NewRecorder(), multipartRequest(t, maxJSONBodySize), &dst)
+
+	var requestError *RequestError
+
+	if !errors.As(err, &requestError) || requestError.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("decoding a form larger than 1 MiB returned %v", err)
+	}
+
+	// larger bodies of routes that allow them
+	req := multipartRequest(t, maxJSONBodySize)
+	LimitBody(httptest.NewRecorder(), req, 2*maxJSONBodySize)
+
+	if err := DecodeRequest(httptest.NewRecorder(), req, &dst); err != nil || dst.Title != "Hello" {
+		t.Fatalf("DecodeRequest returned %v, title %q", err, dst.Title)
+	}
+
+	if _, header, err := req.FormFile("file"); err != nil || header.Size != maxJSONBodySize {
+		t.Errorf("file part is %v, %v", header, err)
+	}
+}
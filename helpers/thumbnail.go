@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	// registering GIF for image.Decode
+	_ "image/gif"
+)
+
+// largest image (in pixels) a thumbnail is generated for, protects against decompression bombs
+const maxThumbnailSource = 40 * 1000 * 1000
+
+// ErrImageTooLarge is returned when an image has too many pixels to generate a thumbnail of
+var ErrImageTooLarge = errors.New("image too large")
+
+// Thumbnail scales a GIF, JPEG or PNG image down to fit within size x size pixels and returns the
+// encoded thumbnail with its MIME type
+//
+// Images with transparency (GIF, PNG) become PNG thumbnails, everything else JPEG.
+func Thumbnail(data []byte, size int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if config.Width*config.Height > maxThumbnailSource {
+		return nil, "", ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	dst := scaleDown(src, size)
+
+	var buf bytes.Buffer
+
+	if format == "png" || format == "gif" {
+		err = png.Encode(&buf, dst)
+
+		return buf.Bytes(), "image/png", err
+	}
+
+	err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+
+	return buf.Bytes(), "image/jpeg", err
+}
+
+// scaleDown resizes src to fit within size x size keeping its aspect ratio, each destination pixel
+// is the average of the source pixels it covers
+func scaleDown(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	newWidth, newHeight := width, height
+
+	if width > size || height > size {
+		if width >= height {
+			newWidth, newHeight = size, height*size/width
+		} else {
+			newWidth, newHeight = width*size/height, size
+		}
+	}
+
+	if newWidth < 1 {
+		newWidth = 1
+	}
+
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, newWidth, newHeight))
+
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			// averaged premultiplied values converted back to non-premultiplied
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,460 @@
+// Package importer reads posts exported by other blogs, WordPress (WXR), Jekyll or Hugo (Markdown
+// with front matter) and go-blog itself (JSON), and creates them
+//
+// Every imported post is recorded with the name of its source and its ID there, so running an
+// import again only creates the posts that are new since.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// formats of exports
+const (
+	FormatWXR      = "wxr"      // WordPress eXtended RSS, Tools > Export of WordPress
+	FormatMarkdown = "markdown" // directory or ZIP archive of Markdown files with front matter
+	FormatJSON     = "json"     // go-blog's own export, a JSON file or an archive written by package exporter
+)
+
+// archives of either Markdown files or go-blog's export
+const (
+	formatZip = "zip"
+	formatTar = "tar" // gzip compressed or not
+)
+
+var ErrUnknownFormat = errors.New("unknown format, expected wxr, markdown or json")
+
+// Post read from an export
+type Post struct {
+	types.ExportPost
+	ExternalId string // post's ID in the source, e.g. the WordPress post ID or the Markdown file's path
+	Skip       string // why the post isn't imported, e.g. "draft", empty if it is
+}
+
+// Content read from an export
+type Source struct {
+	Name        string                   // name of the blog, posts with the same external ID in other sources are different posts
+	Authors     []types.ExportAuthor     // authors listed by the export, if any
+	Posts       []Post                   // posts in the order of the export
+	Attachments []types.ExportAttachment // uploaded files of go-blog's exports, their files are in Files
+	Files       fs.FS                    // files of the attachments, nil if there are none
+}
+
+// Options of an import
+type Options struct {
+	Source        string            // overrides the source's name, e.g. to import two Markdown directories separately
+	DryRun        bool              // report what would be imported without writing
+	CreateAuthors bool              // create the authors that don't exist
+	DefaultAuthor string            // username of the author of posts whose author doesn't exist
+	AuthorMap     map[string]string // usernames of the source mapped to usernames of this blog
+}
+
+// ParseAuthorMap parses "source=username" pairs into Options.AuthorMap
+func ParseAuthorMap(pairs []string) (map[string]string, error) {
+	authorMap := map[string]string{}
+
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+
+		if from, to = strings.TrimSpace(from), strings.TrimSpace(to); !ok || from == "" || to == "" {
+			return nil, errors.New(`invalid author mapping "` + pair + `", expected "source=username"`)
+		}
+
+		authorMap[from] = to
+	}
+
+	return authorMap, nil
+}
+
+// Open reads the export at path, a file or a directory of Markdown files, in format or else the
+// format detected from its name and content
+func Open(path, format string) (*Source, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		if format != "" && format != FormatMarkdown && format != FormatJSON {
+			return nil, errors.New("directories can only be imported as markdown or json")
+		}
+
+		return readFS(os.DirFS(path), format)
+	}
+
+	// archives are read while importing, after the file would have been closed
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data), int64(len(data)), filepath.Base(path), format)
+}
+
+// Read reads an export of size bytes named name in format or else the format detected from its
+// name and content
+//
+// Markdown files are read from ZIP or tar archives, tar archives may be gzip compressed. An
+// archive with a manifest.json, as written by package exporter, is read as a JSON export with its
+// attachments unless format is markdown. r is read until the import has finished, tar archives are
+// unpacked in memory.
+func Read(r io.ReaderAt, size int64, name, format string) (*Source, error) {
+	detected := detectFormat(r, name)
+
+	if format == "" {
+		format = detected
+	}
+
+	switch format {
+	case FormatWXR:
+		return readWXR(io.NewSectionReader(r, 0, size))
+	case FormatJSON, FormatMarkdown, formatZip, formatTar:
+		if detected == formatTar {
+			archive, err := readTar(io.NewSectionReader(r, 0, size))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return readFS(archive, format)
+		}
+
+		archive, err := zip.NewReader(r, size)
+
+		switch {
+		case err == nil:
+			return readFS(archive, format)
+		case format == FormatJSON:
+			return readJSON(io.NewSectionReader(r, 0, size))
+		default:
+			return nil, errors.New("markdown exports must be ZIP or tar archives: " + err.Error())
+		}
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// readFS reads a directory or archive, as a JSON export if it has a manifest.json unless format
+// is markdown
+func readFS(fsys fs.FS, format string) (*Source, error) {
+	if format != FormatMarkdown {
+		if _, err := fs.Stat(fsys, types.ExportManifest); err == nil || format == FormatJSON {
+			return readExport(fsys)
+		}
+	}
+
+	return readMarkdown(fsys)
+}
+
+// detectFormat returns the format of an export by its extension or else its first bytes
+func detectFormat(r io.ReaderAt, name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xml", ".wxr":
+		return FormatWXR
+	case ".json":
+		return FormatJSON
+	case ".zip":
+		return formatZip
+	case ".tar", ".gz", ".tgz":
+		return formatTar
+	}
+
+	head := make([]byte, 512)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	// gzip, or the magic of POSIX tar headers
+	if bytes.HasPrefix(head, []byte("\x1f\x8b")) || (len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar"))) {
+		return formatTar
+	}
+
+	head = bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+
+	switch {
+	case bytes.HasPrefix(head, []byte("<?xml")) || bytes.HasPrefix(head, []byte("<rss")):
+		return FormatWXR
+	case bytes.HasPrefix(head, []byte("{")):
+		return FormatJSON
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return formatZip
+	}
+
+	return ""
+}
+
+// Run imports the posts of source, a report of every post's outcome is returned
+//
+// Posts whose author isn't found (after mapping) are imported as the author created for them with
+// CreateAuthors, else as DefaultAuthor, else they fail. Posts that fail don't stop the import, an
+// error is only returned if the database fails.
+func Run(source *Source, options Options) (types.ImportReport, error) {
+	report := types.ImportReport{Source: source.Name, DryRun: options.DryRun, AuthorsCreated: []string{}, Results: []types.ImportResult{}}
+
+	if options.Source != "" {
+		report.Source = options.Source
+	}
+
+	importer := &runner{source: source, options: options, report: &report, authorIds: map[string]string{}, posts: map[string]importedPost{}}
+
+	for _, post := range source.Posts {
+		result, err := importer.importPost(post)
+
+		if err != nil {
+			return report, err
+		}
+
+		switch result.Status {
+		case types.ImportCreated:
+			report.Created++
+		case types.ImportExists:
+			report.Existing++
+		case types.ImportSkipped:
+			report.Skipped++
+		case types.ImportFailed:
+			report.Failed++
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	for _, attachment := range source.Attachments {
+		if err := importer.importAttachment(attachment); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// runner keeps the state of an import
+type runner struct {
+	source    *Source
+	options   Options
+	report    *types.ImportReport
+	authorIds map[string]string       // IDs of the usernames found or created, empty in a dry run
+	posts     map[string]importedPost // posts created or imported before by their external IDs
+}
+
+// post created or imported before
+type importedPost struct {
+	postId   string // empty in a dry run
+	authorId string // empty in a dry run
+}
+
+// runner's importPost imports a post and returns its outcome
+func (r *runner) importPost(post Post) (types.ImportResult, error) {
+	result := types.ImportResult{ExternalId: post.ExternalId, Title: post.Title}
+
+	if post.Skip != "" {
+		result.Status, result.Reason = types.ImportSkipped, post.Skip
+		return result, nil
+	}
+
+	username, authorId, reason, err := r.author(post.Author)
+
+	if err != nil {
+		return result, err
+	}
+
+	if reason != "" {
+		result.Status, result.Reason = types.ImportFailed, reason
+		return result, nil
+	}
+
+	result.Author = username
+
+	if errors := (types.PostRequest{Title: post.Title, Body: post.Body}).Validate(); len(errors) > 0 {
+		result.Status, result.Reason = types.ImportFailed, errors[0].Message
+		return result, nil
+	}
+
+	if r.options.DryRun {
+		postId, err := models.GetImportedPostId(r.report.Source, post.ExternalId)
+
+		switch {
+		case err == sql.ErrNoRows:
+			result.Status = types.ImportCreated
+		case err != nil:
+			return result, err
+		default:
+			result.Status, result.PostId = types.ImportExists, postId
+		}
+
+		return result, nil
+	}
+
+	postId, created, err := models.ImportPost(r.report.Source, post.ExternalId, post.ExportPost, authorId)
+
+	if err != nil {
+		return result, err
+	}
+
+	result.PostId = postId
+	r.posts[post.ExternalId] = importedPost{postId: postId, authorId: authorId}
+
+	if created {
+		result.Status = types.ImportCreated
+	} else {
+		result.Status = types.ImportExists
+	}
+
+	return result, nil
+}
+
+// runner's importAttachment stores an attachment of go-blog's export and its files, attachments
+// of posts that haven't been imported and ones whose files are missing are left out
+func (r *runner) importAttachment(attachment types.ExportAttachment) error {
+	var authorId, postId string
+
+	if attachment.PostId != "" {
+		post, ok := r.posts[attachment.PostId]
+
+		if !ok {
+			return nil
+		}
+
+		authorId, postId = post.authorId, post.postId
+	} else {
+		_, id, reason, err := r.author(attachment.Author)
+
+		if err != nil || reason != "" {
+			return err
+		}
+
+		authorId = id
+	}
+
+	key := strings.TrimPrefix(attachment.File, types.ExportMediaDir)
+	thumbnailKey := strings.TrimPrefix(attachment.Thumbnail, types.ExportMediaDir)
+
+	if r.source.Files == nil || !storage.ValidKey(key) || (thumbnailKey != "" && !storage.ValidKey(thumbnailKey)) {
+		return nil
+	}
+
+	if r.options.DryRun {
+		r.report.Attachments++
+		return nil
+	}
+
+	for _, name := range []string{attachment.File, attachment.Thumbnail} {
+		if name == "" {
+			continue
+		}
+
+		if err := r.storeBlob(name); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	created, err := models.ImportAttachment(attachment, key, thumbnailKey, authorId, postId)
+
+	if created {
+		r.report.Attachments++
+	}
+
+	return err
+}
+
+// runner's storeBlob copies the file name (media/<key>) of the source to storage.Default
+func (r *runner) storeBlob(name string) error {
+	file, err := r.source.Files.Open(name)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	key := strings.TrimPrefix(name, types.ExportMediaDir)
+	contentType := mime.TypeByExtension(path.Ext(key))
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return storage.Default.Put(key, file, info.Size(), contentType)
+}
+
+// runner's author returns the username and ID a post of the source's author is imported as, or
+// the reason why it can't be
+func (r *runner) author(name string) (string, string, string, error) {
+	username := strings.TrimSpace(name)
+
+	if mapped, ok := r.options.AuthorMap[username]; ok {
+		username = mapped
+	}
+
+	if username == "" {
+		username = r.options.DefaultAuthor
+	}
+
+	if username == "" {
+		return "", "", "The post has no author", nil
+	}
+
+	if authorId, ok := r.authorIds[username]; ok {
+		return username, authorId, "", nil
+	}
+
+	authorId, err := models.GetAuthorIdByUsername(username)
+
+	switch {
+	case err == nil:
+		r.authorIds[username] = authorId
+		return username, authorId, "", nil
+	case err != sql.ErrNoRows:
+		return "", "", "", err
+	case r.options.CreateAuthors:
+		if errors := (types.CreateAuthorRequest{Username: username}).Validate(); len(errors) > 0 && errors[0].Field == "username" {
+			return "", "", "Author " + username + " can't be created: " + errors[0].Message, nil
+		}
+
+		author := types.ExportAuthor{Username: username}
+
+		for _, candidate := range r.source.Authors {
+			if candidate.Username == name {
+				author = candidate
+				author.Username = username
+			}
+		}
+
+		if !r.options.DryRun {
+			if authorId, err = models.ImportAuthor(author); err != nil {
+				return "", "", "", err
+			}
+		}
+
+		r.authorIds[username] = authorId
+		r.report.AuthorsCreated = append(r.report.AuthorsCreated, username)
+
+		return username, authorId, "", nil
+	case r.options.DefaultAuthor != "" && username != r.options.DefaultAuthor:
+		return r.author(r.options.DefaultAuthor)
+	default:
+		return "", "", "Author " + username + " does not exist", nil
+	}
+}
@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samkit-jain/go-blog/exporter"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// testExport returns an export with a post and an attachment, whose file is put in a store that is
+// storage.Default until the end of the test
+func testExport(t *testing.T) types.Export {
+	previous := storage.Default
+	storage.Default = &storage.LocalStore{Dir: t.TempDir()}
+	t.Cleanup(func() { storage.Default = previous })
+
+	if err := storage.Default.Put("ab/cd.txt", strings.NewReader("attached"), 8, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	return types.Export{
+		Version: 1,
+		Authors: []types.ExportAuthor{{Id: "01HV0000000000000000000A01", Username: "sam", CreatedAt: createdAt}},
+		Tags:    []string{"go"},
+		Posts: []types.ExportPost{{
+			Id: "01HV0000000000000000000P01", Title: "Hello", Body: "Some *text*", Tags: []string{"go"},
+			Author: "sam", CreatedAt: createdAt, UpdatedAt: createdAt,
+		}},
+		Attachments: []types.ExportAttachment{{
+			Id: "01HV0000000000000000000T01", PostId: "01HV0000000000000000000P01", Author: "sam", Filename: "notes.txt",
+			ContentType: "text/plain", Size: 8, File: "media/ab/cd.txt", CreatedAt: createdAt,
+		}},
+	}
+}
+
+func TestReadExportRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string // of the export
+		name   string // of the file read
+	}{
+		{exporter.FormatZip, "goblog-sam-20240301.zip"},
+		{exporter.FormatZip, "upload"},
+		{exporter.FormatTar, "goblog-sam-20240301.tar.gz"},
+		{exporter.FormatTar, "goblog-sam-20240301.tgz"},
+		{exporter.FormatTar, "upload"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format+" "+test.name, func(t *testing.T) {
+			export := testExport(t)
+
+			var archive bytes.Buffer
+
+			if err := exporter.Write(&archive, export, test.format); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, format := range []string{"", FormatJSON} {
+				source, err := Read(bytes.NewReader(archive.Bytes()), int64(archive.Len()), test.name, format)
+
+				if err != nil {
+					t.Fatalf("Read as %q: %v", format, err)
+				}
+
+				if len(source.Posts) != 1 || !reflect.DeepEqual(source.Posts[0].ExportPost, export.Posts[0]) {
+					t.Errorf("read posts %+v, want %+v", source.Posts, export.Posts)
+				}
+
+				if !reflect.DeepEqual(source.Authors, export.Authors) || !reflect.DeepEqual(source.Attachments, export.Attachments) {
+					t.Errorf("read authors %+v and attachments %+v", source.Authors, source.Attachments)
+				}
+
+				if data, err := fs.ReadFile(source.Files, "media/ab/cd.txt"); err != nil || string(data) != "attached" {
+					t.Errorf("file of the attachment is %q, %v", data, err)
+				}
+			}
+
+			// the posts are Markdown files too
+			source, err := Read(bytes.NewReader(archive.Bytes()), int64(archive.Len()), test.name, FormatMarkdown)
+
+			if err != nil {
+				t.Fatalf("Read as markdown: %v", err)
+			}
+
+			if len(source.Posts) != 1 || source.Posts[0].Title != "Hello" || source.Posts[0].ExternalId != "posts/01HV0000000000000000000P01" {
+				t.Errorf("read Markdown posts %+v", source.Posts)
+			}
+		})
+	}
+}
+
+func TestReadTarWalk(t *testing.T) {
+	export := testExport(t)
+
+	var archive bytes.Buffer
+
+	if err := exporter.Write(&archive, export, exporter.FormatTar); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := readTar(&archive)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	err = fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		names = append(names, name)
+		return err
+	})
+
+	want := []string{".", "manifest.json", "media", "media/ab", "media/ab/cd.txt", "posts", "posts/01HV0000000000000000000P01.md"}
+
+	if err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("walked %v, %v, want %v", names, err, want)
+	}
+
+	if _, err := fsys.Open("../manifest.json"); err == nil {
+		t.Error("opened a path outside the archive")
+	}
+
+	if _, err := fsys.Open("missing.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("opening a missing file returned %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// version of the JSON export read
+const exportVersion = 1
+
+// readExport reads an archive of go-blog's export, the manifest and the files of the attachments
+func readExport(fsys fs.FS) (*Source, error) {
+	manifest, err := fsys.Open(types.ExportManifest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer manifest.Close()
+
+	source, err := readJSON(manifest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source.Files = fsys
+
+	return source, nil
+}
+
+// readJSON reads go-blog's JSON export, posts keep their IDs unless taken by other posts
+func readJSON(r io.Reader) (*Source, error) {
+	var export types.Export
+
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+
+	if export.Version != exportVersion {
+		return nil, errors.New("unsupported export version " + strconv.Itoa(export.Version))
+	}
+
+	source := &Source{Name: "goblog", Authors: export.Authors, Attachments: export.Attachments}
+
+	for _, post := range export.Posts {
+		source.Posts = append(source.Posts, Post{ExportPost: post, ExternalId: post.Id})
+	}
+
+	return source, nil
+}
@@ -0,0 +1,305 @@
+package importer
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jekyll's post filenames, YYYY-MM-DD-slug
+var jekyllFilename = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// layouts of the dates of front matter
+var frontMatterDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// readMarkdown reads the posts of the Markdown files in fsys, as written for Jekyll or Hugo
+//
+// Posts get their title, dates, tags (and categories), slug, author and draft state from the
+// front matter, YAML between "---" or TOML between "+++" lines. Files named YYYY-MM-DD-slug.md
+// get their date and slug from the name, Hugo's page bundles (dir/index.md) their slug from the
+// directory. Files in _drafts are drafts, hidden directories and Hugo's _index.md are left out.
+func readMarkdown(fsys fs.FS) (*Source, error) {
+	source := &Source{Name: FormatMarkdown}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		base := entry.Name()
+
+		if entry.IsDir() {
+			if name != "." && strings.HasPrefix(base, ".") {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		extension := path.Ext(base)
+
+		if (extension != ".md" && extension != ".markdown") || base == "_index"+extension {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err
+		}
+
+		source.Posts = append(source.Posts, markdownPost(name, string(data), info.ModTime()))
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// markdownPost reads the post of the Markdown file at name, modified at modTime
+func markdownPost(name, content string, modTime time.Time) Post {
+	externalId := strings.TrimSuffix(name, path.Ext(name))
+	slug := path.Base(externalId)
+
+	// page bundles are named after their directory
+	if slug == "index" && path.Dir(externalId) != "." {
+		externalId = path.Dir(externalId)
+		slug = path.Base(externalId)
+	}
+
+	post := Post{ExternalId: externalId}
+
+	if match := jekyllFilename.FindStringSubmatch(slug); match != nil {
+		post.CreatedAt, _ = time.Parse("2006-01-02", match[1])
+		slug = match[2]
+	}
+
+	matter, body := splitFrontMatter(content)
+
+	post.Body = strings.TrimSpace(body)
+	post.Title = matter.value("title")
+	post.Author = matter.value("author")
+	post.Tags = append(matter.list("tags"), matter.list("categories")...)
+	post.Slug = slug
+
+	if value := matter.value("slug"); value != "" {
+		post.Slug = value
+	} else if value := strings.Trim(matter.value("permalink"), "/"); value != "" {
+		post.Slug = path.Base(value)
+	}
+
+	if value := matter.value("id"); value != "" {
+		post.Id = value
+	}
+
+	if date := frontMatterDate(matter.value("date")); !date.IsZero() {
+		post.CreatedAt = date
+	} else if post.CreatedAt.IsZero() {
+		post.CreatedAt = modTime
+	}
+
+	for _, key := range []string{"lastmod", "last_modified_at", "updated"} {
+		if date := frontMatterDate(matter.value(key)); !date.IsZero() {
+			post.UpdatedAt = date
+			break
+		}
+	}
+
+	draft, _ := strconv.ParseBool(matter.value("draft"))
+	published, err := strconv.ParseBool(matter.value("published"))
+
+	if draft || (err == nil && !published) || strings.HasPrefix(name, "_drafts/") || strings.Contains(name, "/_drafts/") {
+		post.Skip = "Draft"
+	}
+
+	return post
+}
+
+// frontMatterDate parses a date of front matter, the zero time if it isn't one
+func frontMatterDate(value string) time.Time {
+	for _, layout := range frontMatterDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+
+	return time.Time{}
+}
+
+// value of a key of front matter
+type frontMatterEntry struct {
+	items []string // the value, an element per item of lists
+	list  bool     // whether the value is a list
+}
+
+// values of the front matter's keys
+type frontMatter map[string]*frontMatterEntry
+
+// frontMatter's value returns the (first) value of key, empty if there is none
+func (m frontMatter) value(key string) string {
+	if m[key] == nil || len(m[key].items) == 0 {
+		return ""
+	}
+
+	return m[key].items[0]
+}
+
+// frontMatter's list returns the items of key, a value that isn't a list is split at whitespace
+// as Jekyll does for tags
+func (m frontMatter) list(key string) []string {
+	switch {
+	case m[key] == nil:
+		return nil
+	case !m[key].list:
+		return strings.Fields(m.value(key))
+	default:
+		return m[key].items
+	}
+}
+
+// splitFrontMatter separates the front matter of a Markdown file from its body
+//
+// Only the parts of YAML and TOML front matter used are read: scalars, inline lists and (YAML)
+// lists of "- item" lines. Nested keys are ignored.
+func splitFrontMatter(content string) (frontMatter, string) {
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\ufeff")
+	matter := frontMatter{}
+
+	var delimiter, separator string
+
+	switch {
+	case strings.HasPrefix(content, "---\n"):
+		delimiter, separator = "---", ":"
+	case strings.HasPrefix(content, "+++\n"):
+		delimiter, separator = "+++", "="
+	default:
+		return matter, content
+	}
+
+	lines := strings.Split(content, "\n")
+	key := ""
+
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimRight(line, " \t") == delimiter {
+			return matter, strings.Join(lines[i+1:], "\n")
+		}
+
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "- ") && key != "":
+			matter[key].items = append(matter[key].items, frontMatterScalar(strings.TrimPrefix(trimmed, "- ")))
+			matter[key].list = true
+		case line != trimmed || strings.HasPrefix(trimmed, "["):
+			// nested keys and TOML tables
+			key = ""
+		default:
+			name, value, ok := strings.Cut(trimmed, separator)
+
+			if !ok {
+				key = ""
+				continue
+			}
+
+			key = strings.ToLower(strings.TrimSpace(name))
+			matter[key] = frontMatterValue(strings.TrimSpace(value))
+		}
+	}
+
+	// no closing delimiter, not front matter
+	return frontMatter{}, content
+}
+
+// frontMatterValue parses a value, inline lists into their items
+func frontMatterValue(value string) *frontMatterEntry {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		if value = frontMatterScalar(value); value == "" {
+			return &frontMatterEntry{}
+		}
+
+		return &frontMatterEntry{items: []string{value}}
+	}
+
+	entry := &frontMatterEntry{list: true}
+
+	for _, item := range splitInlineList(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")) {
+		if item = frontMatterScalar(item); item != "" {
+			entry.items = append(entry.items, item)
+		}
+	}
+
+	return entry
+}
+
+// splitInlineList splits the items of an inline list at the commas that aren't quoted
+func splitInlineList(list string) []string {
+	var (
+		items []string
+		quote byte
+		start int
+	)
+
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, list[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(items, list[start:])
+}
+
+// frontMatterScalar unquotes a scalar and strips its trailing comment
+func frontMatterScalar(value string) string {
+	value = strings.TrimSpace(value)
+
+	if quoted, err := strconv.QuotedPrefix(value); err == nil && value[0] == '"' {
+		unquoted, _ := strconv.Unquote(quoted)
+		return unquoted
+	}
+
+	if len(value) >= 2 && value[0] == '\'' {
+		if end := strings.IndexByte(value[1:], '\''); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = value[:index]
+	}
+
+	return strings.TrimSpace(value)
+}
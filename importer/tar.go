@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// limit of the unpacked size of tar archives, which are held in memory
+const maxTarBytes = 1 << 30
+
+var errTarTooLarge = errors.New("tar archive is larger than 1 GiB unpacked")
+
+// tarFS is the content of a tar archive, as written by package exporter, held in memory
+type tarFS struct {
+	entries map[string]*tarEntry // files and directories by path, "." is the root
+}
+
+// file or directory of a tarFS
+type tarEntry struct {
+	name     string // path in the archive
+	data     []byte
+	dir      bool
+	modTime  time.Time
+	children []fs.DirEntry // of directories, sorted by name
+}
+
+// readTar unpacks a tar archive, gzip compressed or not, only its regular files and directories
+// are kept
+func readTar(r io.Reader) (*tarFS, error) {
+	buffered := bufio.NewReader(r)
+
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte("\x1f\x8b")) {
+		decompressed, err := gzip.NewReader(buffered)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer decompressed.Close()
+
+		r = decompressed
+	} else {
+		r = buffered
+	}
+
+	fsys := &tarFS{entries: map[string]*tarEntry{".": {name: ".", dir: true}}}
+	archive := tar.NewReader(r)
+	var unpacked int64
+
+	for {
+		header, err := archive.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			fsys.directory(name).modTime = header.ModTime
+		case tar.TypeReg:
+			if unpacked += header.Size; unpacked > maxTarBytes {
+				return nil, errTarTooLarge
+			}
+
+			data, err := io.ReadAll(archive)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := fsys.entries[name]; ok {
+				// later entries replace earlier ones, like when extracting
+				fsys.entries[name].data = data
+				continue
+			}
+
+			entry := &tarEntry{name: name, data: data, modTime: header.ModTime}
+			fsys.entries[name] = entry
+			parent := fsys.directory(path.Dir(name))
+			parent.children = append(parent.children, fs.FileInfoToDirEntry(entry))
+		}
+	}
+
+	for _, entry := range fsys.entries {
+		sort.Slice(entry.children, func(i, j int) bool { return entry.children[i].Name() < entry.children[j].Name() })
+	}
+
+	return fsys, nil
+}
+
+// tarFS's directory returns the directory at name, creating it and its parents if missing
+func (f *tarFS) directory(name string) *tarEntry {
+	if entry, ok := f.entries[name]; ok {
+		return entry
+	}
+
+	entry := &tarEntry{name: name, dir: true}
+	f.entries[name] = entry
+	parent := f.directory(path.Dir(name))
+	parent.children = append(parent.children, fs.FileInfoToDirEntry(entry))
+
+	return entry
+}
+
+func (f *tarFS) Open(name string) (fs.File, error) {
+	entry, err := f.lookup("open", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tarFile{entry: entry, reader: bytes.NewReader(entry.data)}, nil
+}
+
+func (f *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := f.lookup("readdir", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	return append([]fs.DirEntry(nil), entry.children...), nil
+}
+
+func (f *tarFS) ReadFile(name string) ([]byte, error) {
+	entry, err := f.lookup("read", name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.dir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	return append([]byte(nil), entry.data...), nil
+}
+
+// tarFS's lookup returns the entry at name, op names the operation in errors
+func (f *tarFS) lookup(op, name string) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	entry, ok := f.entries[name]
+
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return entry, nil
+}
+
+// tarEntry is its own fs.FileInfo
+func (e *tarEntry) Name() string {
+	return path.Base(e.name)
+}
+
+func (e *tarEntry) Size() int64 {
+	return int64(len(e.data))
+}
+
+func (e *tarEntry) ModTime() time.Time {
+	return e.modTime
+}
+
+func (e *tarEntry) IsDir() bool {
+	return e.dir
+}
+
+func (e *tarEntry) Sys() interface{} {
+	return nil
+}
+
+func (e *tarEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+// tarFile is an opened tarEntry
+type tarFile struct {
+	entry  *tarEntry
+	reader *bytes.Reader
+	read   int // directory entries returned by ReadDir
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.entry, nil
+}
+
+func (f *tarFile) Read(b []byte) (int, error) {
+	return f.reader.Read(b)
+}
+
+func (f *tarFile) Close() error {
+	return nil
+}
+
+func (f *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.name, Err: errors.New("not a directory")}
+	}
+
+	entries := f.entry.children[f.read:]
+
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+	}
+
+	f.read += len(entries)
+
+	return append([]fs.DirEntry(nil), entries...), nil
+}
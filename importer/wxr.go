@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// WordPress export, elements are matched by their local names as the wp namespace differs by
+// WXR version
+type wxrFile struct {
+	Channel struct {
+		Links   []string    `xml:"link"` // atom:link is empty
+		Authors []wxrAuthor `xml:"author"`
+		Items   []wxrItem   `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrAuthor struct {
+	Login string `xml:"author_login"`
+	Email string `xml:"author_email"`
+}
+
+type wxrItem struct {
+	Title       string        `xml:"title"`
+	Creator     string        `xml:"creator"`
+	Content     string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostId      string        `xml:"post_id"`
+	PostDate    string        `xml:"post_date"`
+	PostDateGMT string        `xml:"post_date_gmt"`
+	ModifiedGMT string        `xml:"post_modified_gmt"`
+	PostName    string        `xml:"post_name"`
+	Status      string        `xml:"status"`
+	PostType    string        `xml:"post_type"`
+	Categories  []wxrCategory `xml:"category"`
+}
+
+// category or tag of an item
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"` // category or post_tag
+	Name   string `xml:",chardata"`
+}
+
+// layout of WordPress' dates, *_gmt ones are UTC
+const wxrDateLayout = "2006-01-02 15:04:05"
+
+// readWXR reads the published posts of a WordPress export, pages, attachments and menu items are
+// left out and categories become tags
+func readWXR(r io.Reader) (*Source, error) {
+	var file wxrFile
+
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	source := &Source{Name: "wxr"}
+
+	for _, link := range file.Channel.Links {
+		if link = strings.TrimSpace(link); link != "" {
+			source.Name = "wxr:" + strings.TrimSuffix(link, "/")
+			break
+		}
+	}
+
+	for _, author := range file.Channel.Authors {
+		source.Authors = append(source.Authors, types.ExportAuthor{Username: author.Login, Email: author.Email})
+	}
+
+	for _, item := range file.Channel.Items {
+		if item.PostType != "" && item.PostType != "post" {
+			continue
+		}
+
+		post := Post{ExternalId: strings.TrimSpace(item.PostId)}
+		post.Title = strings.TrimSpace(item.Title)
+		post.Body = strings.TrimSpace(item.Content)
+		post.Author = strings.TrimSpace(item.Creator)
+		post.Slug = strings.TrimSpace(item.PostName)
+
+		// drafts have no GMT date
+		if post.CreatedAt = wxrDate(item.PostDateGMT); post.CreatedAt.IsZero() {
+			post.CreatedAt = wxrDate(item.PostDate)
+		}
+
+		post.UpdatedAt = wxrDate(item.ModifiedGMT)
+
+		for _, category := range item.Categories {
+			if category.Domain == "category" || category.Domain == "post_tag" {
+				post.Tags = append(post.Tags, strings.TrimSpace(category.Name))
+			}
+		}
+
+		if item.Status != "publish" {
+			post.Skip = "Status is " + item.Status
+		}
+
+		source.Posts = append(source.Posts, post)
+	}
+
+	return source, nil
+}
+
+// wxrDate parses a date of a WordPress export, the zero time if there is none
+func wxrDate(value string) time.Time {
+	date, err := time.Parse(wxrDateLayout, strings.TrimSpace(value))
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return date
+}
@@ -0,0 +1,141 @@
+// Package mailer sends outgoing emails through SMTP or, for local development and tests, a log file
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object containing an outgoing email
+type Message struct {
+	To      string // recipient's address
+	Subject string // subject line
+	Body    string // plain text body
+}
+
+// Mailer is implemented by anything that can deliver a Message
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer delivers emails through an SMTP server
+type SMTPMailer struct {
+	Host     string // SMTP server's host
+	Port     int    // SMTP server's port
+	Username string // user to authenticate as, no authentication if empty
+	Password string // password of the user
+	From     string // sender's address
+}
+
+// SMTPMailer's Send delivers msg using PLAIN authentication (if credentials provided)
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := m.Host + ":" + strconv.Itoa(m.Port)
+
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, compose(m.From, msg))
+}
+
+// LogMailer writes emails to Writer instead of delivering them
+//
+// Useful for local development and tests where the links sent in emails need to be read back.
+type LogMailer struct {
+	Writer io.Writer // destination of the emails
+	From   string    // sender's address
+
+	mu sync.Mutex
+}
+
+// LogMailer's Send writes msg to the underlying writer
+func (m *LogMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.Writer, "%s\n", compose(m.From, msg))
+
+	return err
+}
+
+// compose creates the raw email with headers
+func compose(from string, msg Message) []byte {
+	var b strings.Builder
+
+	// line breaks in header values would allow injecting extra headers
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	b.WriteString("From: " + header.Replace(from) + "\r\n")
+	b.WriteString("To: " + header.Replace(msg.To) + "\r\n")
+	b.WriteString("Subject: " + header.Replace(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+
+	return []byte(b.String())
+}
+
+// mailer instance used by Send
+var Default Mailer
+
+// InitMailer sets up Default as an SMTPMailer if GOBLOG_SMTP_HOST is set, otherwise as a LogMailer
+// writing to GOBLOG_MAIL_FILE (standard output if not set)
+func InitMailer() {
+	from := os.Getenv("GOBLOG_MAIL_FROM")
+
+	if from == "" {
+		from = "goblog@localhost"
+	}
+
+	if host := os.Getenv("GOBLOG_SMTP_HOST"); host != "" {
+		port, err := strconv.Atoi(os.Getenv("GOBLOG_SMTP_PORT"))
+
+		if err != nil {
+			port = 587
+		}
+
+		Default = &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("GOBLOG_SMTP_USER"),
+			Password: os.Getenv("GOBLOG_SMTP_PASSWORD"),
+			From:     from,
+		}
+
+		return
+	}
+
+	var writer io.Writer = os.Stdout
+
+	if name := os.Getenv("GOBLOG_MAIL_FILE"); name != "" {
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+
+		// can't write to the file
+		if err != nil {
+			panic(err)
+		}
+
+		writer = file
+	}
+
+	Default = &LogMailer{Writer: writer, From: from}
+}
+
+// Send delivers msg through the Default mailer
+func Send(msg Message) error {
+	if Default == nil {
+		return fmt.Errorf("mailer not initialised")
+	}
+
+	return Default.Send(msg)
+}
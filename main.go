@@ -3,10 +3,17 @@ package main
 
 import (
 	"net/http"
+	"os"
 
 	"github.com/samkit-jain/go-blog/api"
+	"github.com/samkit-jain/go-blog/cache"
 	"github.com/samkit-jain/go-blog/config"
 	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/mailer"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/ratelimit"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/webhooks"
 	"github.com/samkit-jain/go-blog/website"
 )
 
@@ -34,15 +41,55 @@ func (h *App) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 }
 
 func main() {
+	// e.g. "go-blog import", see commands.go
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// initialise database connection
 	config.InitDB()
 
+	// initialise password hashing algorithm
+	helpers.InitPasswordHasher()
+
+	// initialise ID generator for new authors and posts
+	helpers.InitIdGenerator()
+
+	// initialise outgoing mail
+	mailer.InitMailer()
+
+	// initialise read cache of posts and authors
+	cache.InitCache()
+
+	// initialise storage of uploaded files
+	storage.InitBlobStore()
+
+	// initialise Cache-Control headers of GET responses
+	helpers.InitCachePolicy()
+
+	// initialise counting of API requests for rate limiting
+	ratelimit.InitStore()
+
+	// initialise single sign-on through an OpenID Connect identity provider
+	oidc.InitProvider()
+
+	// initialise delivery of events to webhooks
+	webhooks.InitDispatcher()
+
+	// initialise templates and static assets of the website
+	website.InitTheme()
+
 	// initialise main handler
 	app := &App{
 		ApiHandler:     api.NewApiHandler(),
 		WebsiteHandler: website.NewWebsiteHandler(),
 	}
 
+	// expvars (e.g. cache hits and misses) on a separate, private address
+	if addr := os.Getenv("GOBLOG_DEBUG_ADDR"); addr != "" {
+		go http.ListenAndServe(addr, http.DefaultServeMux)
+	}
+
 	// start listening
-	http.ListenAndServe(":8080", app)
+	http.ListenAndServe(":8080", helpers.CacheHandler(helpers.DefaultCachePolicy, app))
 }
@@ -0,0 +1,17 @@
+-- optional email address of an author and whether it has been verified
+ALTER TABLE authors
+    ADD COLUMN email VARCHAR(254) UNIQUE,
+    ADD COLUMN email_verified_at TIMESTAMP;
+
+-- single-use, time-limited tokens sent to authors (email verification, password reset)
+CREATE TABLE author_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    author_id VARCHAR(64) NOT NULL REFERENCES authors(author_id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    data TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX author_tokens_author_id_idx ON author_tokens (author_id, purpose);
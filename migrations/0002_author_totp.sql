@@ -0,0 +1,17 @@
+-- TOTP second factor of an author, enabled once confirmed_at is set
+CREATE TABLE author_totp (
+    author_id VARCHAR(64) PRIMARY KEY REFERENCES authors(author_id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    confirmed_at TIMESTAMP,
+    -- last accepted time step, codes can't be replayed
+    last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+-- one-time recovery codes used when the authenticator is lost
+CREATE TABLE author_recovery_codes (
+    author_id VARCHAR(64) NOT NULL REFERENCES authors(author_id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    PRIMARY KEY (author_id, code_hash)
+);
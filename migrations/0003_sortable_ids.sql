@@ -0,0 +1,11 @@
+-- IDs are now ULIDs (26 characters) or Snowflake IDs (up to 20 digits) created by the app, the
+-- numeric IDs created before stay valid
+--
+-- only needed if the ID columns were created with a numeric type or shorter length
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_author_id_fkey;
+
+ALTER TABLE authors ALTER COLUMN author_id TYPE VARCHAR(64) USING author_id::text;
+ALTER TABLE posts ALTER COLUMN author_id TYPE VARCHAR(64) USING author_id::text;
+ALTER TABLE posts ALTER COLUMN post_id TYPE VARCHAR(64) USING post_id::text;
+
+ALTER TABLE posts ADD CONSTRAINT posts_author_id_fkey FOREIGN KEY (author_id) REFERENCES authors(author_id);
@@ -0,0 +1,14 @@
+-- files uploaded by authors, optionally linked to one of their posts
+CREATE TABLE attachments (
+    attachment_id VARCHAR(64) PRIMARY KEY,
+    author_id VARCHAR(64) NOT NULL REFERENCES authors(author_id) ON DELETE CASCADE,
+    post_id VARCHAR(64) REFERENCES posts(post_id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(127) NOT NULL,
+    size BIGINT NOT NULL,
+    blob_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX attachments_post_id_idx ON attachments (post_id);
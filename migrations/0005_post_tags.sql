@@ -0,0 +1,8 @@
+-- tags of a post
+CREATE TABLE post_tags (
+    post_id VARCHAR(64) NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+    tag VARCHAR(64) NOT NULL,
+    PRIMARY KEY (post_id, tag)
+);
+
+CREATE INDEX post_tags_tag_idx ON post_tags (tag);
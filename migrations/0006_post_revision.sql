@@ -0,0 +1,2 @@
+-- revision of a post, incremented on every update and used as its ETag
+ALTER TABLE posts ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
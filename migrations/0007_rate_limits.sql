@@ -0,0 +1,10 @@
+-- request counts of API clients, only used with GOBLOG_RATE_LIMIT_STORE=postgres
+CREATE UNLOGGED TABLE rate_limits (
+    key VARCHAR(255) NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX rate_limits_window_end_idx ON rate_limits (window_end);
@@ -0,0 +1,14 @@
+-- long-lived API keys of authors, only the SHA-256 hash of a key is stored
+CREATE TABLE api_keys (
+    key_id VARCHAR(64) PRIMARY KEY,
+    author_id VARCHAR(64) NOT NULL REFERENCES authors(author_id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_author_id_idx ON api_keys (author_id);
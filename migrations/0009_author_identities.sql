@@ -0,0 +1,10 @@
+-- identities at OpenID Connect providers linked to authors for single sign-on
+CREATE TABLE author_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    author_id VARCHAR(64) NOT NULL REFERENCES authors(author_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX author_identities_author_id_idx ON author_identities (author_id);
@@ -0,0 +1,11 @@
+-- posts created by imports, re-running an import skips them and links to their old slugs keep working
+CREATE TABLE post_imports (
+    source VARCHAR(255) NOT NULL,
+    external_id VARCHAR(512) NOT NULL,
+    post_id VARCHAR(64) NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+    slug VARCHAR(255),
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, external_id)
+);
+
+CREATE INDEX post_imports_slug_idx ON post_imports (slug);
@@ -0,0 +1,4 @@
+-- invalid second factor codes in a row, too many lock the second factor until locked_until
+ALTER TABLE author_totp
+    ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN locked_until TIMESTAMP;
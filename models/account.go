@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/mailer"
+)
+
+// purposes of the tokens stored in author_tokens
+const (
+	verifyEmailPurpose   = "verify_email"
+	resetPasswordPurpose = "reset_password"
+)
+
+// validity of the tokens stored in author_tokens
+const (
+	verifyEmailValidity   = 48 * time.Hour
+	resetPasswordValidity = time.Hour
+)
+
+// ErrInvalidToken is returned when a token is malformed, expired or already used
+var ErrInvalidToken = errors.New("Invalid or expired token!")
+
+// ErrInvalidEmail is returned when an email address can't be parsed
+var ErrInvalidEmail = errors.New("Invalid email address!")
+
+// NormaliseEmail validates email and returns it in the form stored in the database
+func NormaliseEmail(email string) (string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+
+	// also rejecting "Name <address>" forms
+	if err != nil || address.Name != "" || address.Address != strings.TrimSpace(email) {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(address.Address), nil
+}
+
+// GetAuthorEmail returns the email address of an author and whether it has been verified
+func GetAuthorEmail(authorId string) (string, bool, error) {
+	row := config.DB.QueryRow("SELECT email, email_verified_at FROM authors WHERE author_id=$1;", authorId)
+
+	var (
+		email      sql.NullString
+		verifiedAt *time.Time
+	)
+
+	err := row.Scan(&email, &verifiedAt)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return email.String, verifiedAt != nil, nil
+}
+
+// SetAuthorEmail changes the email address of an author and sends a verification link to it
+func SetAuthorEmail(authorId, email string) error {
+	email, err := NormaliseEmail(email)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := "UPDATE authors SET email=$1, email_verified_at=NULL WHERE author_id=$2 RETURNING author_id;"
+
+	err = config.DB.QueryRow(sqlStatement, email, authorId).Scan(&authorId)
+
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	return RequestEmailVerification(authorId)
+}
+
+// RequestEmailVerification emails a single-use verification link to the author's address
+func RequestEmailVerification(authorId string) error {
+	email, verified, err := GetAuthorEmail(authorId)
+
+	if err != nil {
+		return err
+	}
+
+	if email == "" || verified {
+		return nil
+	}
+
+	token, err := createAuthorToken(authorId, verifyEmailPurpose, email, verifyEmailValidity)
+
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: "Please verify your email address by opening the link below.\n\n" +
+			helpers.BaseURL() + "/auth/verify/?token=" + token + "\n\n" +
+			"The link expires in 48 hours.",
+	})
+}
+
+// VerifyEmail consumes an email verification token and marks the address it was sent to as verified
+func VerifyEmail(token string) (string, error) {
+	authorId, email, err := consumeAuthorToken(token, verifyEmailPurpose)
+
+	if err != nil {
+		return "", err
+	}
+
+	sqlStatement := "UPDATE authors SET email_verified_at=now() WHERE author_id=$1 AND email=$2 RETURNING author_id;"
+
+	err = config.DB.QueryRow(sqlStatement, authorId, email).Scan(&authorId)
+
+	// author changed the address after the link was sent
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return authorId, nil
+}
+
+// RequestPasswordReset emails a time-limited password reset link to the verified address of the
+// author identified by username or email
+//
+// No error is returned if no such author exists so that callers can't probe for accounts.
+func RequestPasswordReset(usernameOrEmail string) error {
+	sqlStatement := "SELECT author_id, email FROM authors WHERE (username=$1 OR email=$2) AND email_verified_at IS NOT NULL;"
+
+	var (
+		authorId string
+		email    string
+	)
+
+	err := config.DB.QueryRow(sqlStatement, usernameOrEmail, strings.ToLower(usernameOrEmail)).Scan(&authorId, &email)
+
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	token, err := createAuthorToken(authorId, resetPasswordPurpose, "", resetPasswordValidity)
+
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password of your account. If it was you, open the link below.\n\n" +
+			helpers.BaseURL() + "/auth/reset/?token=" + token + "\n\n" +
+			"The link expires in 1 hour. If you didn't ask for this, you can ignore this email.",
+	})
+}
+
+// ResetPassword consumes a password reset token and changes the author's password
+func ResetPassword(token, password string) (string, error) {
+	authorId, _, err := consumeAuthorToken(token, resetPasswordPurpose)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err = UpdatePassword(authorId, password); err != nil {
+		return "", err
+	}
+
+	return authorId, nil
+}
+
+// UpdatePassword changes the password of an author and invalidates any pending reset links
+func UpdatePassword(authorId, password string) error {
+	hash, err := helpers.HashPassword(password)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := "UPDATE authors SET password=$1 WHERE author_id=$2 RETURNING author_id;"
+
+	err = config.DB.QueryRow(sqlStatement, hash, authorId).Scan(&authorId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = config.DB.Exec("UPDATE author_tokens SET used_at=now() WHERE author_id=$1 AND purpose=$2 AND used_at IS NULL;", authorId, resetPasswordPurpose)
+
+	return err
+}
+
+// createAuthorToken stores a new single-use token of an author and returns the signed token
+func createAuthorToken(authorId, purpose, data string, validity time.Duration) (string, error) {
+	token, hash, err := helpers.CreateSignedToken(purpose)
+
+	if err != nil {
+		return "", err
+	}
+
+	sqlStatement := `
+	INSERT INTO author_tokens (token_hash, author_id, purpose, data, expires_at)
+	VALUES ($1, $2, $3, $4, $5);`
+
+	_, err = config.DB.Exec(sqlStatement, hash, authorId, purpose, data, time.Now().Add(validity))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeAuthorToken marks a token as used and returns the author and data it was stored with
+func consumeAuthorToken(token, purpose string) (string, string, error) {
+	hash, ok := helpers.ParseSignedToken(token, purpose)
+
+	if !ok {
+		return "", "", ErrInvalidToken
+	}
+
+	sqlStatement := `
+	UPDATE author_tokens SET used_at=now()
+	WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > now()
+	RETURNING author_id, data;`
+
+	var (
+		authorId string
+		data     string
+	)
+
+	err := config.DB.QueryRow(sqlStatement, hash, purpose).Scan(&authorId, &data)
+
+	if err == sql.ErrNoRows {
+		return "", "", ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return authorId, data, nil
+}
+
+// sendVerificationAfterSignup requests verification of a new author's email without failing the
+// signup if the email can't be sent
+func sendVerificationAfterSignup(authorId string) {
+	if err := RequestEmailVerification(authorId); err != nil {
+		log.Printf("sending verification email to author %s: %v", authorId, err)
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// CreateAPIKey creates an API key of an author and returns it, the key itself is only returned here
+func CreateAPIKey(authorId, name string, scopes []string) (types.APIKey, error) {
+	keyId, err := helpers.NewId()
+
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	key, prefix, hash, err := helpers.GenerateAPIKey()
+
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	result := types.APIKey{Id: keyId, Name: name, Prefix: prefix, Scopes: scopes, Key: key}
+
+	sqlStatement := `
+	INSERT INTO api_keys (key_id, author_id, name, prefix, key_hash, scopes)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at;`
+
+	err = config.DB.QueryRow(sqlStatement, keyId, authorId, name, prefix, hash, pq.Array(scopes)).Scan(&result.CreatedAt)
+
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	return result, nil
+}
+
+// GetAPIKeys returns the API keys of an author that haven't been revoked, newest first
+func GetAPIKeys(authorId string) ([]types.APIKey, error) {
+	result := make([]types.APIKey, 0)
+	rows, err := config.DB.Query("SELECT key_id, name, prefix, scopes, created_at, last_used_at FROM api_keys WHERE author_id=$1 AND revoked_at IS NULL ORDER BY created_at DESC;", authorId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key types.APIKey
+
+		if err := rows.Scan(&key.Id, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &key.LastUsedAt); err != nil {
+			return nil, err
+		}
+
+		result = append(result, key)
+	}
+
+	return result, rows.Err()
+}
+
+// RevokeAPIKey revokes an API key of an author, sql.ErrNoRows is returned if the author has no
+// such key
+func RevokeAPIKey(authorId, keyId string) error {
+	result, err := config.DB.Exec("UPDATE api_keys SET revoked_at=NOW() WHERE key_id=$1 AND author_id=$2 AND revoked_at IS NULL;", keyId, authorId)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the ID, author and scopes of a key that hasn't been revoked,
+// sql.ErrNoRows is returned for unknown keys
+//
+// The key's last use is recorded to the minute so that busy keys don't write on every request.
+func AuthenticateAPIKey(key string) (string, string, []string, error) {
+	var (
+		keyId      string
+		authorId   string
+		scopes     []string
+		lastUsedAt *time.Time
+	)
+
+	if !helpers.IsAPIKey(key) {
+		return "", "", nil, sql.ErrNoRows
+	}
+
+	err := config.DB.QueryRow("SELECT key_id, author_id, scopes, last_used_at FROM api_keys WHERE key_hash=$1 AND revoked_at IS NULL;", helpers.HashAPIKey(key)).Scan(&keyId, &authorId, pq.Array(&scopes), &lastUsedAt)
+
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if lastUsedAt == nil || time.Since(*lastUsedAt) >= time.Minute {
+		if _, err := config.DB.Exec("UPDATE api_keys SET last_used_at=NOW() WHERE key_id=$1;", keyId); err != nil {
+			return "", "", nil, err
+		}
+	}
+
+	return keyId, authorId, scopes, nil
+}
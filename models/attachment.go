@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// CreateAttachment stores the metadata of an uploaded file, postId is optional
+//
+// Linking a file modifies the post, so its revision and modification date are updated.
+func CreateAttachment(attachment types.Attachment, authorId string) (types.Attachment, error) {
+	id, err := helpers.NewId()
+
+	if err != nil {
+		return types.Attachment{}, err
+	}
+
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return types.Attachment{}, err
+	}
+
+	defer tx.Rollback()
+
+	sqlStatement := `
+	INSERT INTO attachments (attachment_id, author_id, post_id, filename, content_type, size, blob_key, thumbnail_key)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING created_at;`
+
+	err = tx.QueryRow(sqlStatement, id, authorId, nullString(attachment.PostId), attachment.Filename,
+		attachment.ContentType, attachment.Size, attachment.Key, nullString(attachment.ThumbnailKey)).Scan(&attachment.CreatedAt)
+
+	if err != nil {
+		return types.Attachment{}, err
+	}
+
+	if attachment.PostId != "" {
+		if _, err = tx.Exec("UPDATE posts SET updated_at=NOW(), revision=revision+1 WHERE post_id=$1;", attachment.PostId); err != nil {
+			return types.Attachment{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return types.Attachment{}, err
+	}
+
+	if attachment.PostId != "" {
+		invalidatePost(attachment.PostId, authorId)
+		publishPost(types.EventPostUpdated, attachment.PostId)
+	}
+
+	attachment.Id = id
+
+	return withMediaURLs(attachment), nil
+}
+
+// GetPostAttachments returns the attachments linked to a post, oldest first
+func GetPostAttachments(postId string) ([]types.Attachment, error) {
+	attachments, err := GetPostsAttachments([]string{postId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if attachments[postId] == nil {
+		return make([]types.Attachment, 0), nil
+	}
+
+	return attachments[postId], nil
+}
+
+// GetPostsAttachments returns the attachments linked to each of the posts, oldest first, posts
+// without attachments are left out
+func GetPostsAttachments(postIds []string) (map[string][]types.Attachment, error) {
+	result := make(map[string][]types.Attachment)
+	rows, err := config.DB.Query("SELECT attachment_id, post_id, filename, content_type, size, blob_key, thumbnail_key, created_at FROM attachments WHERE post_id=ANY($1) ORDER BY created_at;", pq.Array(postIds))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			attachment   types.Attachment
+			thumbnailKey sql.NullString
+			createdAt    time.Time
+		)
+
+		err = rows.Scan(&attachment.Id, &attachment.PostId, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.Key, &thumbnailKey, &createdAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attachment.ThumbnailKey = thumbnailKey.String
+		attachment.CreatedAt = createdAt
+
+		result[attachment.PostId] = append(result[attachment.PostId], withMediaURLs(attachment))
+	}
+
+	// get any error encountered during iteration
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// withMediaURLs fills in the paths the attachment's blobs are served at
+func withMediaURLs(attachment types.Attachment) types.Attachment {
+	attachment.URL = "/media/" + attachment.Key
+
+	if attachment.ThumbnailKey != "" {
+		attachment.ThumbnailURL = "/media/" + attachment.ThumbnailKey
+	}
+
+	return attachment
+}
+
+// nullString stores empty strings as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -1,12 +1,16 @@
 package models
 
 import (
-	"strconv"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 
+	"github.com/samkit-jain/go-blog/cache"
 	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/events"
 	"github.com/samkit-jain/go-blog/helpers"
 	"github.com/samkit-jain/go-blog/types"
 )
@@ -49,8 +53,57 @@ func GetAllAuthors() ([]types.Author, error) {
 	return result, nil
 }
 
+// GetAuthorsPage returns up to limit authors ordered by username, following the author named
+// after, together with the number of all authors
+func GetAuthorsPage(after string, limit int) ([]types.Author, int, error) {
+	var total int
+
+	if err := config.DB.QueryRow("SELECT COUNT(*) FROM authors;").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]types.Author, 0)
+	rows, err := config.DB.Query("SELECT author_id, username, created_at FROM authors WHERE username > $1 ORDER BY username LIMIT $2;", after, limit)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var author types.Author
+
+		if err := rows.Scan(&author.AuthorId, &author.Username, &author.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+
+		result = append(result, author)
+	}
+
+	// get any error encountered during iteration
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
 // GetAuthorById searches by authorId and returns information of the author and all the its posts
 func GetAuthorById(authorId string) (types.AuthorPosts, error) {
+	value, err := cache.Default.Fetch(authorKey(authorId), func() (interface{}, error) {
+		return getAuthorById(authorId)
+	})
+
+	if err != nil {
+		return types.AuthorPosts{}, err
+	}
+
+	return value.(types.AuthorPosts), nil
+}
+
+// getAuthorById is GetAuthorById without the cache
+func getAuthorById(authorId string) (types.AuthorPosts, error) {
 	// getting author's info
 	row := config.DB.QueryRow("SELECT username, created_at FROM authors WHERE author_id=$1;", authorId)
 
@@ -69,7 +122,7 @@ func GetAuthorById(authorId string) (types.AuthorPosts, error) {
 	result := types.AuthorPosts{AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}, List: posts}
 
 	// getting author's posts
-	rows, err := config.DB.Query("SELECT post_id, title, body, created_at, updated_at FROM posts WHERE author_id=$1 ORDER BY created_at DESC;", authorId)
+	rows, err := config.DB.Query("SELECT post_id, title, body, created_at, updated_at, COALESCE((SELECT array_agg(tag ORDER BY tag) FROM post_tags WHERE post_tags.post_id=posts.post_id), '{}') FROM posts WHERE author_id=$1 ORDER BY created_at DESC;", authorId)
 
 	if err != nil {
 		return result, err
@@ -84,15 +137,16 @@ func GetAuthorById(authorId string) (types.AuthorPosts, error) {
 			body      string
 			createdAt time.Time
 			updatedAt time.Time
+			tags      []string
 		)
 
-		err = rows.Scan(&postId, &title, &body, &createdAt, &updatedAt)
+		err = rows.Scan(&postId, &title, &body, &createdAt, &updatedAt, pq.Array(&tags))
 
 		if err != nil {
 			return result, err
 		}
 
-		result.List = append(result.List, types.Post{Id: postId, Title: title, Body: body, CreatedAt: createdAt, UpdatedAt: updatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}})
+		result.List = append(result.List, types.Post{Id: postId, Title: title, Body: body, CreatedAt: createdAt, UpdatedAt: updatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}, Tags: tags})
 	}
 
 	// get any error encountered during iteration
@@ -135,38 +189,91 @@ func GetPasswordHash(username string) (string, error) {
 	return password, nil
 }
 
-// CreateAuthor creates an author
-func CreateAuthor(un, ps string) (string, error) {
-	hash, err := helpers.HashPassword(ps)
+// UpgradePasswordHash replaces the stored hash of an author who has just logged in with password
+// if it was created by an outdated algorithm or with outdated parameters
+func UpgradePasswordHash(username, password, hash string) error {
+	if !helpers.PasswordNeedsRehash(hash) {
+		return nil
+	}
+
+	newHash, err := helpers.HashPassword(password)
 
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	i := 1
+	// only replaced if the password wasn't changed in the meantime
+	_, err = config.DB.Exec("UPDATE authors SET password=$1 WHERE username=$2 AND password=$3;", newHash, username, hash)
 
-	// loop till unique ID created but not till infinity
-	for {
-		sqlStatement := `
-		INSERT INTO authors (author_id, username, password)
-		VALUES ($1, $2, $3)
-		RETURNING author_id`
+	return err
+}
 
-		var id string
+// CreateAuthor creates an author, email is optional and a verification link is sent to it if given
+func CreateAuthor(un, ps, email string) (string, error) {
+	var address sql.NullString
 
-		err = config.DB.QueryRow(sqlStatement, "100000"+strconv.Itoa(helpers.RangeIn(100000000, 999999999)), un, hash).Scan(&id)
+	if email != "" {
+		normalised, err := NormaliseEmail(email)
 
-		if err == nil {
-			return id, nil
-		} else if i == 100 {
+		if err != nil {
 			return "", err
-		} else if pgerr, ok := err.(*pq.Error); ok {
-			// 23505 -> primary key exists (unique_violation)
-			if pgerr.Code != "23505" {
-				return "", err
-			}
 		}
 
-		i += 1
+		address = sql.NullString{String: normalised, Valid: true}
+	}
+
+	hash, err := helpers.HashPassword(ps)
+
+	if err != nil {
+		return "", err
+	}
+
+	id, err := helpers.NewId()
+
+	if err != nil {
+		return "", err
+	}
+
+	sqlStatement := `
+	INSERT INTO authors (author_id, username, password, email)
+	VALUES ($1, $2, $3, $4)
+	RETURNING author_id, created_at`
+
+	var createdAt time.Time
+
+	err = config.DB.QueryRow(sqlStatement, id, un, hash, address).Scan(&id, &createdAt)
+
+	if err != nil {
+		return "", uniqueViolation(err)
 	}
+
+	events.Default.Publish(types.EventAuthorCreated, types.Author{Username: un, AuthorId: id, CreatedAt: createdAt})
+
+	if address.Valid {
+		sendVerificationAfterSignup(id)
+	}
+
+	return id, nil
+}
+
+// ErrUsernameTaken is returned when creating an author with a username that already exists
+var ErrUsernameTaken = errors.New("Username already taken!")
+
+// ErrEmailTaken is returned when an email address is already used by another author
+var ErrEmailTaken = errors.New("Email address already used by another author!")
+
+// uniqueViolation turns unique constraint violations on authors into ErrUsernameTaken or
+// ErrEmailTaken
+func uniqueViolation(err error) error {
+	// 23505 -> unique_violation
+	if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == "23505" {
+		switch {
+		case strings.Contains(pgerr.Constraint, "username"):
+			return ErrUsernameTaken
+		case strings.Contains(pgerr.Constraint, "email"):
+			return ErrEmailTaken
+		}
+	}
+
+	return err
 }
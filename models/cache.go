@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/samkit-jain/go-blog/cache"
+)
+
+// key of the cached list of all posts
+const allPostsKey = "posts"
+
+// postKey returns the key of a cached post
+func postKey(postId string) string {
+	return "post:" + postId
+}
+
+// authorKey returns the key of a cached author including posts
+func authorKey(authorId string) string {
+	return "author:" + authorId
+}
+
+// invalidatePost removes the cached values containing an author's post
+func invalidatePost(postId, authorId string) {
+	cache.Default.Invalidate(postKey(postId), allPostsKey, authorKey(authorId))
+}
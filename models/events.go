@@ -0,0 +1,30 @@
+package models
+
+import (
+	"log"
+
+	"github.com/samkit-jain/go-blog/events"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// publishPost publishes an event with the current state of a post, nothing is loaded if no one is
+// subscribed
+func publishPost(eventType, postId string) {
+	if !events.Default.Active() {
+		return
+	}
+
+	post, err := GetPostById(postId)
+
+	if err != nil {
+		log.Printf("events: loading post %s for %s: %v", postId, eventType, err)
+		return
+	}
+
+	events.Default.Publish(eventType, post)
+}
+
+// publishPostDeleted publishes the deletion of an author's post
+func publishPostDeleted(postId, authorId string) {
+	events.Default.Publish(types.EventPostDeleted, types.PostRef{Id: postId, AuthorId: authorId})
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// GetExportAuthors returns the author authorId including the email address, all authors if
+// authorId is empty
+func GetExportAuthors(authorId string) ([]types.ExportAuthor, error) {
+	result := make([]types.ExportAuthor, 0)
+	rows, err := config.DB.Query("SELECT author_id, username, COALESCE(email, ''), created_at FROM authors WHERE ($1='' OR author_id=$1) ORDER BY username;", authorId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var author types.ExportAuthor
+
+		if err := rows.Scan(&author.Id, &author.Username, &author.Email, &author.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		result = append(result, author)
+	}
+
+	// get any error encountered during iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetExportPosts returns the posts of the author authorId, of all authors if authorId is empty,
+// newest first
+func GetExportPosts(authorId string) ([]types.Post, error) {
+	rows, err := config.DB.Query("SELECT "+postColumns+" FROM authors JOIN posts ON(authors.author_id=posts.author_id) WHERE ($1='' OR posts.author_id=$1) ORDER BY posts.created_at DESC, posts.post_id DESC;", authorId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetExportAttachments returns the attachments uploaded by the author authorId, by all authors if
+// authorId is empty, oldest first
+//
+// Their files are named media/<key> after the keys of their blobs.
+func GetExportAttachments(authorId string) ([]types.ExportAttachment, error) {
+	result := make([]types.ExportAttachment, 0)
+	rows, err := config.DB.Query("SELECT attachments.attachment_id, COALESCE(attachments.post_id, ''), authors.username, attachments.filename, attachments.content_type, attachments.size, attachments.blob_key, attachments.thumbnail_key, attachments.created_at FROM attachments JOIN authors ON(authors.author_id=attachments.author_id) WHERE ($1='' OR attachments.author_id=$1) ORDER BY attachments.created_at;", authorId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			attachment   types.ExportAttachment
+			thumbnailKey sql.NullString
+		)
+
+		err = rows.Scan(&attachment.Id, &attachment.PostId, &attachment.Author, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.File, &thumbnailKey, &attachment.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attachment.File = types.ExportMediaDir + attachment.File
+
+		if thumbnailKey.Valid {
+			attachment.Thumbnail = types.ExportMediaDir + thumbnailKey.String
+		}
+
+		result = append(result, attachment)
+	}
+
+	// get any error encountered during iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
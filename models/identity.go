@@ -0,0 +1,195 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/events"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// ErrIdentityNotLinked is returned when logging in with an identity that isn't linked to an author
+// and provisioning is disabled
+var ErrIdentityNotLinked = errors.New("No author is linked to this identity!")
+
+// ErrIdentityLinked is returned when linking an identity that is linked to another author
+var ErrIdentityLinked = errors.New("Identity already linked to another author!")
+
+// LoginWithIdentity returns the author linked to an identity, creating one if provision is set and
+// none is linked yet
+func LoginWithIdentity(identity oidc.Identity, provision bool) (string, error) {
+	var authorId string
+
+	err := config.DB.QueryRow("SELECT author_id FROM author_identities WHERE issuer=$1 AND subject=$2;", identity.Issuer, identity.Subject).Scan(&authorId)
+
+	if err == nil {
+		return authorId, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if !provision {
+		return "", ErrIdentityNotLinked
+	}
+
+	return provisionAuthor(identity)
+}
+
+// LinkIdentity links an identity to an author, linking it again to the same author does nothing
+func LinkIdentity(authorId string, identity oidc.Identity) error {
+	var linkedTo string
+
+	sqlStatement := `
+	INSERT INTO author_identities (issuer, subject, author_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (issuer, subject) DO UPDATE SET issuer=EXCLUDED.issuer
+	RETURNING author_id;`
+
+	err := config.DB.QueryRow(sqlStatement, identity.Issuer, identity.Subject, authorId).Scan(&linkedTo)
+
+	if err != nil {
+		return err
+	}
+
+	if linkedTo != authorId {
+		return ErrIdentityLinked
+	}
+
+	return nil
+}
+
+// UnlinkIdentities removes the links of an author to identities at issuer, sql.ErrNoRows is
+// returned if there were none
+func UnlinkIdentities(authorId, issuer string) error {
+	result, err := config.DB.Exec("DELETE FROM author_identities WHERE author_id=$1 AND issuer=$2;", authorId, issuer)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// provisionAuthor creates an author for an identity and links it
+//
+// The author gets an unguessable password, it can be set through the password reset flow once the
+// email address is verified. Taken usernames get a random suffix, a taken email address is left out.
+func provisionAuthor(identity oidc.Identity) (string, error) {
+	password, err := oidc.NewRandom()
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := helpers.HashPassword(password)
+
+	if err != nil {
+		return "", err
+	}
+
+	var email sql.NullString
+
+	if normalised, err := NormaliseEmail(identity.Email); err == nil {
+		email = sql.NullString{String: normalised, Valid: true}
+	}
+
+	base := provisionedUsername(identity)
+	username := base
+
+	for attempt := 0; ; attempt++ {
+		authorId, err := insertProvisionedAuthor(identity, username, hash, email)
+
+		switch {
+		case err == ErrUsernameTaken && attempt < 5:
+			username = fmt.Sprintf("%s-%04d", base, rand.Intn(10000))
+		case err == ErrEmailTaken && email.Valid:
+			email = sql.NullString{}
+		default:
+			return authorId, err
+		}
+	}
+}
+
+// insertProvisionedAuthor creates an author and links it to identity in one transaction, the
+// email address counts as verified if the identity provider verified it
+func insertProvisionedAuthor(identity oidc.Identity, username, hash string, email sql.NullString) (string, error) {
+	authorId, err := helpers.NewId()
+
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	sqlStatement := `
+	INSERT INTO authors (author_id, username, password, email, email_verified_at)
+	VALUES ($1, $2, $3, $4, CASE WHEN $5::boolean AND $4::text IS NOT NULL THEN now() END)
+	RETURNING created_at;`
+
+	var createdAt time.Time
+
+	if err = tx.QueryRow(sqlStatement, authorId, username, hash, email, identity.EmailVerified).Scan(&createdAt); err != nil {
+		return "", uniqueViolation(err)
+	}
+
+	if _, err = tx.Exec("INSERT INTO author_identities (issuer, subject, author_id) VALUES ($1, $2, $3);", identity.Issuer, identity.Subject, authorId); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	events.Default.Publish(types.EventAuthorCreated, types.Author{Username: username, AuthorId: authorId, CreatedAt: createdAt})
+
+	return authorId, nil
+}
+
+// provisionedUsername derives a username from the identity's username claim, or else its email
+func provisionedUsername(identity oidc.Identity) string {
+	username := identity.Username
+
+	if username == "" {
+		username = strings.Split(identity.Email, "@")[0]
+	}
+
+	username = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '/' {
+			return '-'
+		}
+
+		return r
+	}, strings.TrimSpace(username))
+
+	// leaving room for a suffix
+	if runes := []rune(username); len(runes) > 59 {
+		username = string(runes[:59])
+	}
+
+	if username == "" {
+		username = "author"
+	}
+
+	return username
+}
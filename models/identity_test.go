@@ -0,0 +1,165 @@
+package models
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samkit-jain/go-blog/dbtest"
+	"github.com/samkit-jain/go-blog/oidc"
+)
+
+var (
+	usernameTaken = &pq.Error{Code: "23505", Constraint: "authors_username_key"}
+	emailTaken    = &pq.Error{Code: "23505", Constraint: "authors_email_key"}
+)
+
+var testIdentity = oidc.Identity{Issuer: "https://idp.example.com", Subject: "subject-1", Username: "sam", Email: "Sam@Example.com", EmailVerified: true}
+
+// provisioned returns the arguments of the authors created by provisioning, in order
+func provisioned(db *dbtest.DB) (usernames []string, emails []interface{}) {
+	for _, query := range db.Ran("INSERT INTO authors") {
+		usernames = append(usernames, query.Args[1].(string))
+		emails = append(emails, query.Args[3])
+	}
+
+	return usernames, emails
+}
+
+func TestLoginWithIdentityLinked(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=").Rows(dbtest.Row("01HV0000000000000000000A01"))
+
+	authorId, err := LoginWithIdentity(testIdentity, true)
+
+	if err != nil || authorId != "01HV0000000000000000000A01" {
+		t.Fatalf("LoginWithIdentity returned %q, %v", authorId, err)
+	}
+
+	if len(db.Ran("INSERT")) > 0 {
+		t.Error("an author was provisioned for a linked identity")
+	}
+}
+
+func TestLoginWithIdentityNotLinked(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=")
+
+	if _, err := LoginWithIdentity(testIdentity, false); err != ErrIdentityNotLinked {
+		t.Errorf("LoginWithIdentity returned %v without provisioning, want ErrIdentityNotLinked", err)
+	}
+}
+
+func TestLoginWithIdentityProvisions(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=")
+	db.On("INSERT INTO authors").Rows(dbtest.Row(time.Now()))
+	db.On("INSERT INTO author_identities")
+
+	authorId, err := LoginWithIdentity(testIdentity, true)
+
+	if err != nil {
+		t.Fatalf("LoginWithIdentity: %v", err)
+	}
+
+	usernames, emails := provisioned(db)
+
+	if len(usernames) != 1 || usernames[0] != "sam" || emails[0] != "sam@example.com" {
+		t.Errorf("provisioned %v %v, want sam with the normalised email", usernames, emails)
+	}
+
+	links := db.Ran("INSERT INTO author_identities")
+
+	if len(links) != 1 || links[0].Args[0] != testIdentity.Issuer || links[0].Args[1] != testIdentity.Subject || links[0].Args[2] != authorId {
+		t.Errorf("identity linked with %v, want the provisioned author %s", links, authorId)
+	}
+}
+
+func TestLoginWithIdentityUsernameTaken(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=")
+	db.On("INSERT INTO authors").Once().Err(usernameTaken)
+	db.On("INSERT INTO authors").Once().Err(usernameTaken)
+	db.On("INSERT INTO authors").Rows(dbtest.Row(time.Now()))
+	db.On("INSERT INTO author_identities")
+
+	if _, err := LoginWithIdentity(testIdentity, true); err != nil {
+		t.Fatalf("LoginWithIdentity: %v", err)
+	}
+
+	usernames, emails := provisioned(db)
+	suffixed := regexp.MustCompile(`^sam-[0-9]{4}$`)
+
+	if len(usernames) != 3 || usernames[0] != "sam" || !suffixed.MatchString(usernames[1]) || !suffixed.MatchString(usernames[2]) {
+		t.Errorf("tried usernames %v, want sam then sam-<4 digits>", usernames)
+	}
+
+	if emails[2] != "sam@example.com" {
+		t.Errorf("email %v dropped for a taken username", emails[2])
+	}
+}
+
+func TestLoginWithIdentityUsernameTakenRepeatedly(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=")
+	db.On("INSERT INTO authors").Err(usernameTaken)
+
+	if _, err := LoginWithIdentity(testIdentity, true); err != ErrUsernameTaken {
+		t.Errorf("LoginWithIdentity returned %v, want ErrUsernameTaken", err)
+	}
+
+	if usernames, _ := provisioned(db); len(usernames) != 6 {
+		t.Errorf("tried %d usernames, want 6", len(usernames))
+	}
+}
+
+func TestLoginWithIdentityEmailTaken(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("FROM author_identities WHERE issuer=")
+	db.On("INSERT INTO authors").Once().Err(emailTaken)
+	db.On("INSERT INTO authors").Rows(dbtest.Row(time.Now()))
+	db.On("INSERT INTO author_identities")
+
+	if _, err := LoginWithIdentity(testIdentity, true); err != nil {
+		t.Fatalf("LoginWithIdentity: %v", err)
+	}
+
+	usernames, emails := provisioned(db)
+
+	if len(usernames) != 2 || usernames[1] != "sam" || emails[1] != nil {
+		t.Errorf("provisioned %v %v, want sam again without an email", usernames, emails)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		linkedTo string // author the identity is linked to after the insert
+		want     error
+	}{
+		{"new or same author", "01HV0000000000000000000A01", nil},
+		{"other author", "01HV0000000000000000000A02", ErrIdentityLinked},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := dbtest.Open(t)
+			db.On("INSERT INTO author_identities").Rows(dbtest.Row(test.linkedTo))
+
+			if err := LinkIdentity("01HV0000000000000000000A01", testIdentity); err != test.want {
+				t.Errorf("LinkIdentity returned %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestUnlinkIdentities(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("DELETE FROM author_identities").Affected(0)
+
+	if err := UnlinkIdentities("01HV0000000000000000000A01", testIdentity.Issuer); err == nil {
+		t.Error("UnlinkIdentities of an author without identities succeeded")
+	}
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samkit-jain/go-blog/cache"
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// GetImportedPostId returns the ID of the post imported from source as externalId, sql.ErrNoRows
+// if it hasn't been imported
+func GetImportedPostId(source, externalId string) (string, error) {
+	var postId string
+
+	err := config.DB.QueryRow("SELECT post_id FROM post_imports WHERE source=$1 AND external_id=$2;", source, externalId).Scan(&postId)
+
+	if err != nil {
+		return "", err
+	}
+
+	return postId, nil
+}
+
+// GetPostIdBySlug returns the ID of the latest post imported with slug
+func GetPostIdBySlug(slug string) (string, error) {
+	var postId string
+
+	err := config.DB.QueryRow("SELECT post_id FROM post_imports WHERE slug=$1 ORDER BY imported_at DESC LIMIT 1;", slug).Scan(&postId)
+
+	if err != nil {
+		return "", err
+	}
+
+	return postId, nil
+}
+
+// ImportPost creates a post of author read from source as externalId and returns its ID, unless it
+// has been imported before, the existing post's ID and false are returned then
+//
+// The post keeps its ID if it's valid and not taken, and its dates. A post having its ID already
+// counts as imported before. No events are published for imported posts.
+func ImportPost(source, externalId string, post types.ExportPost, author string) (string, bool, error) {
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return "", false, err
+	}
+
+	defer tx.Rollback()
+
+	var existing string
+
+	err = tx.QueryRow("SELECT post_id FROM post_imports WHERE source=$1 AND external_id=$2;", source, externalId).Scan(&existing)
+
+	if err == nil {
+		return existing, false, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return "", false, err
+	}
+
+	id := post.Id
+
+	if helpers.IsValidId(id) {
+		var exists bool
+
+		if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM posts WHERE post_id=$1);", id).Scan(&exists); err != nil {
+			return "", false, err
+		}
+
+		// e.g. an export of this blog imported into itself
+		if exists {
+			return id, false, nil
+		}
+	} else if id, err = helpers.NewId(); err != nil {
+		return "", false, err
+	}
+
+	createdAt, updatedAt := post.CreatedAt, post.UpdatedAt
+
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	if updatedAt.Before(createdAt) {
+		updatedAt = createdAt
+	}
+
+	sqlStatement := `
+	INSERT INTO posts (post_id, title, body, author_id, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+
+	if _, err = tx.Exec(sqlStatement, id, post.Title, post.Body, author, createdAt.UTC(), updatedAt.UTC()); err != nil {
+		return "", false, err
+	}
+
+	if err = setPostTags(tx, id, post.Tags); err != nil {
+		return "", false, err
+	}
+
+	slug := sql.NullString{String: post.Slug, Valid: post.Slug != ""}
+
+	if _, err = tx.Exec("INSERT INTO post_imports (source, external_id, post_id, slug) VALUES ($1, $2, $3, $4);", source, externalId, id, slug); err != nil {
+		return "", false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", false, err
+	}
+
+	cache.Default.Invalidate(allPostsKey, authorKey(author))
+
+	return id, true, nil
+}
+
+// ImportAuthor creates an author read from another blog and returns its ID
+//
+// The author gets a random password and has to reset it. The email address isn't verified and
+// dropped if invalid or taken, no mail is sent and no event published. The ID is kept if it's
+// valid and not taken.
+func ImportAuthor(author types.ExportAuthor) (string, error) {
+	password, err := helpers.NewSecret("")
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := helpers.HashPassword(password)
+
+	if err != nil {
+		return "", err
+	}
+
+	var email sql.NullString
+
+	if normalised, err := NormaliseEmail(author.Email); err == nil {
+		email = sql.NullString{String: normalised, Valid: true}
+	}
+
+	id := author.Id
+
+	if !helpers.IsValidId(id) {
+		if id, err = helpers.NewId(); err != nil {
+			return "", err
+		}
+	}
+
+	var createdAt interface{}
+
+	if !author.CreatedAt.IsZero() {
+		createdAt = author.CreatedAt.UTC()
+	}
+
+	for {
+		sqlStatement := `
+		INSERT INTO authors (author_id, username, password, email, created_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5, now()));`
+
+		_, err = config.DB.Exec(sqlStatement, id, author.Username, hash, email, createdAt)
+
+		switch err = uniqueViolation(err); {
+		case err == ErrEmailTaken && email.Valid:
+			email = sql.NullString{}
+		case isPrimaryKeyViolation(err):
+			// the author's ID is taken by another author
+			if id, err = helpers.NewId(); err != nil {
+				return "", err
+			}
+		case err != nil:
+			return "", err
+		default:
+			return id, nil
+		}
+	}
+}
+
+// ImportAttachment stores the metadata of a file read from an export, uploaded by author and linked
+// to postId unless empty, and returns false if an attachment with its ID exists already
+//
+// Its blob (and thumbnail) must have been stored under the keys given. No events are published.
+func ImportAttachment(attachment types.ExportAttachment, key, thumbnailKey, author, postId string) (bool, error) {
+	id := attachment.Id
+
+	if !helpers.IsValidId(id) {
+		var err error
+
+		if id, err = helpers.NewId(); err != nil {
+			return false, err
+		}
+	}
+
+	var createdAt interface{}
+
+	if !attachment.CreatedAt.IsZero() {
+		createdAt = attachment.CreatedAt.UTC()
+	}
+
+	sqlStatement := `
+	INSERT INTO attachments (attachment_id, author_id, post_id, filename, content_type, size, blob_key, thumbnail_key, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, now()))
+	ON CONFLICT (attachment_id) DO NOTHING;`
+
+	result, err := config.DB.Exec(sqlStatement, id, author, nullString(postId), attachment.Filename, attachment.ContentType,
+		attachment.Size, key, nullString(thumbnailKey), createdAt)
+
+	if err != nil {
+		return false, err
+	}
+
+	created, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	if created > 0 && postId != "" {
+		invalidatePost(postId, author)
+	}
+
+	return created > 0, nil
+}
+
+// isPrimaryKeyViolation reports if err is the violation of a table's primary key
+func isPrimaryKeyViolation(err error) bool {
+	// 23505 -> unique_violation
+	pgerr, ok := err.(*pq.Error)
+
+	return ok && pgerr.Code == "23505" && strings.HasSuffix(pgerr.Constraint, "_pkey")
+}
@@ -3,20 +3,37 @@ package models
 
 import (
 	"database/sql"
-	"strconv"
+	"errors"
 	"time"
 
 	"github.com/lib/pq"
 
+	"github.com/samkit-jain/go-blog/cache"
 	"github.com/samkit-jain/go-blog/config"
 	"github.com/samkit-jain/go-blog/helpers"
 	"github.com/samkit-jain/go-blog/types"
 )
 
+// ErrPostModified is returned when a post's revision isn't one the client expected
+var ErrPostModified = errors.New("Post has been modified since it was read!")
+
 // GetAllPosts returns a list of all the posts
 func GetAllPosts() ([]types.Post, error) {
+	value, err := cache.Default.Fetch(allPostsKey, func() (interface{}, error) {
+		return getAllPosts()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return value.([]types.Post), nil
+}
+
+// getAllPosts is GetAllPosts without the cache
+func getAllPosts() ([]types.Post, error) {
 	result := make([]types.Post, 0)
-	rows, err := config.DB.Query("SELECT authors.username, authors.author_id, authors.created_at, posts.post_id, posts.title, posts.body, posts.created_at, posts.updated_at FROM authors JOIN posts ON(authors.author_id=posts.author_id) ORDER BY posts.updated_at DESC;")
+	rows, err := config.DB.Query("SELECT authors.username, authors.author_id, authors.created_at, posts.post_id, posts.title, posts.body, posts.created_at, posts.updated_at, COALESCE((SELECT array_agg(tag ORDER BY tag) FROM post_tags WHERE post_tags.post_id=posts.post_id), '{}') FROM authors JOIN posts ON(authors.author_id=posts.author_id) ORDER BY posts.updated_at DESC;")
 
 	// ooh, an error
 	if err != nil {
@@ -36,15 +53,16 @@ func GetAllPosts() ([]types.Post, error) {
 			postBody        string
 			postCreatedAt   time.Time
 			postUpdatedAt   time.Time
+			postTags        []string
 		)
 
-		err := rows.Scan(&authorName, &authorId, &authorCreatedAt, &postId, &postTitle, &postBody, &postCreatedAt, &postUpdatedAt)
+		err := rows.Scan(&authorName, &authorId, &authorCreatedAt, &postId, &postTitle, &postBody, &postCreatedAt, &postUpdatedAt, pq.Array(&postTags))
 
 		if err != nil {
 			return nil, err
 		}
 
-		result = append(result, types.Post{Id: postId, Title: postTitle, Body: postBody, CreatedAt: postCreatedAt, UpdatedAt: postUpdatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}})
+		result = append(result, types.Post{Id: postId, Title: postTitle, Body: postBody, CreatedAt: postCreatedAt, UpdatedAt: postUpdatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}, Tags: postTags})
 	}
 
 	// get any error encountered during iteration
@@ -59,7 +77,20 @@ func GetAllPosts() ([]types.Post, error) {
 
 // GetPostById returns the post specified by postId
 func GetPostById(postId string) (types.Post, error) {
-	row := config.DB.QueryRow("SELECT authors.username, authors.author_id, authors.created_at, posts.title, posts.body, posts.created_at, posts.updated_at FROM authors JOIN posts ON(authors.author_id=posts.author_id) WHERE posts.post_id=$1;", postId)
+	value, err := cache.Default.Fetch(postKey(postId), func() (interface{}, error) {
+		return getPostById(postId)
+	})
+
+	if err != nil {
+		return types.Post{}, err
+	}
+
+	return value.(types.Post), nil
+}
+
+// getPostById is GetPostById without the cache
+func getPostById(postId string) (types.Post, error) {
+	row := config.DB.QueryRow("SELECT authors.username, authors.author_id, authors.created_at, posts.title, posts.body, posts.created_at, posts.updated_at, posts.revision, COALESCE((SELECT array_agg(tag ORDER BY tag) FROM post_tags WHERE post_tags.post_id=posts.post_id), '{}') FROM authors JOIN posts ON(authors.author_id=posts.author_id) WHERE posts.post_id=$1;", postId)
 
 	var (
 		authorName      string
@@ -69,87 +100,363 @@ func GetPostById(postId string) (types.Post, error) {
 		postBody        string
 		postCreatedAt   time.Time
 		postUpdatedAt   time.Time
+		postRevision    int
+		postTags        []string
 	)
 
-	err := row.Scan(&authorName, &authorId, &authorCreatedAt, &postTitle, &postBody, &postCreatedAt, &postUpdatedAt)
+	err := row.Scan(&authorName, &authorId, &authorCreatedAt, &postTitle, &postBody, &postCreatedAt, &postUpdatedAt, &postRevision, pq.Array(&postTags))
 
 	// an error including sql.ErrNoRows
 	if err != nil {
 		return types.Post{}, err
 	}
 
-	result := types.Post{Id: postId, Title: postTitle, Body: postBody, CreatedAt: postCreatedAt, UpdatedAt: postUpdatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}}
+	result := types.Post{Id: postId, Title: postTitle, Body: postBody, CreatedAt: postCreatedAt, UpdatedAt: postUpdatedAt, AuthorInfo: types.Author{Username: authorName, AuthorId: authorId, CreatedAt: authorCreatedAt}, Tags: postTags, Revision: postRevision}
+
+	result.Attachments, err = GetPostAttachments(postId)
+
+	if err != nil {
+		return types.Post{}, err
+	}
 
 	return result, nil
 }
 
-// CreatePost creates a post for author with title and body
-func CreatePost(title, body, author string) (string, error) {
-	i := 1
+// GetPostAuthorId returns the ID of the author of a post
+func GetPostAuthorId(postId string) (string, error) {
+	row := config.DB.QueryRow("SELECT author_id FROM posts WHERE post_id=$1;", postId)
+
+	var authorId string
+
+	err := row.Scan(&authorId)
+
+	if err != nil {
+		return "", err
+	}
+
+	return authorId, nil
+}
+
+// CreatePost creates a post for author with title, body and tags
+func CreatePost(title, body, author string, tags []string) (string, error) {
+	id, err := helpers.NewId()
+
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	sqlStatement := `
+	INSERT INTO posts (post_id, title, body, author_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING post_id;`
+
+	err = tx.QueryRow(sqlStatement, id, title, body, author).Scan(&id)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err = setPostTags(tx, id, tags); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	cache.Default.Invalidate(allPostsKey, authorKey(author))
+	publishPost(types.EventPostCreated, id)
+
+	return id, nil
+}
+
+// UpdatePost modifies an author's post and returns its new revision, tags are left unchanged if nil
+//
+// If revisions isn't nil the post is only modified if its current revision is one of them,
+// ErrPostModified is returned otherwise.
+func UpdatePost(postId, title, body, author string, tags []string, revisions []int) (int, error) {
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	sqlStatement := "UPDATE posts SET title=$1, body=$2, updated_at=NOW(), revision=revision+1 WHERE author_id=$3 AND post_id=$4 AND ($5::int[] IS NULL OR revision=ANY($5)) RETURNING revision;"
 
-	// loop till unique ID created but not till infinity
-	for {
-		sqlStatement := `
-		INSERT INTO posts (post_id, title, body, author_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING post_id;`
+	var revision int
 
-		var id string
+	err = tx.QueryRow(sqlStatement, title, body, author, postId, revisionsParam(revisions)).Scan(&revision)
 
-		err := config.DB.QueryRow(sqlStatement, "500000"+strconv.Itoa(helpers.RangeIn(100000000, 999999999)), title, body, author).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, postWriteError(postId, author)
+	}
 
-		if err == nil {
-			return id, nil
-		} else if i == 100 {
-			return "", err
-		} else if pgerr, ok := err.(*pq.Error); ok {
-			// 23505 -> primary key exists (unique_violation)
-			if pgerr.Code != "23505" {
-				return "", err
-			}
+	if err != nil {
+		return 0, err
+	}
+
+	if tags != nil {
+		if err = setPostTags(tx, postId, tags); err != nil {
+			return 0, err
 		}
+	}
 
-		i += 1
+	if err = tx.Commit(); err != nil {
+		return 0, err
 	}
+
+	invalidatePost(postId, author)
+	publishPost(types.EventPostUpdated, postId)
+
+	return revision, nil
 }
 
-// UpdatePost modifies an author's post
-func UpdatePost(postId, title, body, author string) (string, error) {
-	sqlStatement := "UPDATE posts SET title=$1, body=$2 WHERE author_id=$3 AND post_id=$4 RETURNING post_id;"
+// revisionsParam returns revisions as query parameter, NULL if nil
+func revisionsParam(revisions []int) interface{} {
+	if revisions == nil {
+		return nil
+	}
+
+	values := make([]int64, len(revisions))
+
+	for index, revision := range revisions {
+		values[index] = int64(revision)
+	}
+
+	return pq.Array(values)
+}
 
-	var id string
+// postWriteError returns why a conditional write to an author's post changed nothing,
+// ErrPostModified if the author has the post, sql.ErrNoRows otherwise
+func postWriteError(postId, author string) error {
+	var exists bool
 
-	err := config.DB.QueryRow(sqlStatement, title, body, author, postId).Scan(&id)
+	err := config.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM posts WHERE author_id=$1 AND post_id=$2);", author, postId).Scan(&exists)
 
-	if err == nil {
-		return id, nil
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrPostModified
 	}
 
-	return "", err
+	return sql.ErrNoRows
 }
 
-// DeletePost deletes an author's post
-func DeletePost(postId, author string) error {
-	sqlStatement := "DELETE FROM posts WHERE author_id=$1 AND post_id=$2;"
+// setPostTags replaces the tags of a post
+func setPostTags(tx *sql.Tx, postId string, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE post_id=$1;", postId); err != nil {
+		return err
+	}
 
-	err := config.DB.QueryRow(sqlStatement, author, postId).Scan()
+	for _, tag := range helpers.NormaliseTags(tags) {
+		if _, err := tx.Exec("INSERT INTO post_tags (post_id, tag) VALUES ($1, $2);", postId, tag); err != nil {
+			return err
+		}
+	}
 
-	if err == sql.ErrNoRows {
-		err = nil
+	return nil
+}
+
+// DeletePost deletes an author's post, sql.ErrNoRows is returned if the author has no such post
+//
+// If revisions isn't nil the post is only deleted if its current revision is one of them,
+// ErrPostModified is returned otherwise.
+func DeletePost(postId, author string, revisions []int) error {
+	sqlStatement := "DELETE FROM posts WHERE author_id=$1 AND post_id=$2 AND ($3::int[] IS NULL OR revision=ANY($3));"
+
+	result, err := config.DB.Exec(sqlStatement, author, postId, revisionsParam(revisions))
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
 	}
 
-	return err
+	if deleted == 0 {
+		return postWriteError(postId, author)
+	}
+
+	invalidatePost(postId, author)
+	publishPostDeleted(postId, author)
+
+	return nil
 }
 
 // DeletePost deletes an author's all posts
 func DeletePosts(author string) error {
-	sqlStatement := "DELETE FROM posts WHERE author_id=$1;"
+	sqlStatement := "DELETE FROM posts WHERE author_id=$1 RETURNING post_id;"
 
-	err := config.DB.QueryRow(sqlStatement, author).Scan()
+	rows, err := config.DB.Query(sqlStatement, author)
 
-	if err == sql.ErrNoRows {
-		err = nil
+	if err != nil {
+		return err
 	}
 
-	return err
+	defer rows.Close()
+
+	keys := []string{allPostsKey, authorKey(author)}
+	postIds := []string{}
+
+	for rows.Next() {
+		var postId string
+
+		if err := rows.Scan(&postId); err != nil {
+			return err
+		}
+
+		keys = append(keys, postKey(postId))
+		postIds = append(postIds, postId)
+	}
+
+	cache.Default.Invalidate(keys...)
+
+	for _, postId := range postIds {
+		publishPostDeleted(postId, author)
+	}
+
+	return rows.Err()
+}
+
+// columns of a post and its author read by scanPosts, from posts joined with authors
+const postColumns = "authors.username, authors.author_id, authors.created_at, posts.post_id, posts.title, posts.body, posts.created_at, posts.updated_at, posts.revision, COALESCE((SELECT array_agg(tag ORDER BY tag) FROM post_tags WHERE post_tags.post_id=posts.post_id), '{}')"
+
+// GetPostsPage returns up to limit posts, newest first, following the post at after and, unless tag
+// is empty, having tag together with the number of posts having tag (of all posts if empty)
+func GetPostsPage(tag string, after types.PostCursor, limit int) ([]types.Post, int, error) {
+	// tags are stored normalised, a tag normalised away (e.g. " ") matches no post
+	if tags := helpers.NormaliseTags([]string{tag}); len(tags) > 0 {
+		tag = tags[0]
+	}
+
+	var total int
+
+	err := config.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE ($1='' OR EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id=posts.post_id AND post_tags.tag=$1));", tag).Scan(&total)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := config.DB.Query("SELECT "+postColumns+" FROM authors JOIN posts ON(authors.author_id=posts.author_id) WHERE ($1='' OR EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id=posts.post_id AND post_tags.tag=$1)) AND ($2='' OR (posts.created_at, posts.post_id) < ($3, $2)) ORDER BY posts.created_at DESC, posts.post_id DESC LIMIT $4;", tag, after.Id, after.CreatedAt, limit)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	result, err := scanPosts(rows)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
+// GetTagPosts returns the posts having tag, newest first
+func GetTagPosts(tag string) ([]types.Post, error) {
+	rows, err := config.DB.Query("SELECT "+postColumns+" FROM authors JOIN posts ON(authors.author_id=posts.author_id) WHERE EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id=posts.post_id AND post_tags.tag=$1) ORDER BY posts.created_at DESC, posts.post_id DESC;", tag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+// GetAuthorsPosts returns up to limit posts of each of the authors, newest first and following the
+// post at after, together with the number of posts of each author
+//
+// The pages of all authors are read with one query, authors without posts are left out.
+func GetAuthorsPosts(authorIds []string, after types.PostCursor, limit int) (map[string][]types.Post, map[string]int, error) {
+	posts := make(map[string][]types.Post)
+	totals := make(map[string]int)
+
+	rows, err := config.DB.Query("SELECT author_id, COUNT(*) FROM posts WHERE author_id=ANY($1) GROUP BY author_id;", pq.Array(authorIds))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			authorId string
+			total    int
+		)
+
+		if err := rows.Scan(&authorId, &total); err != nil {
+			return nil, nil, err
+		}
+
+		totals[authorId] = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// numbering each author's posts to cut every page at limit
+	rows, err = config.DB.Query("SELECT "+postColumns+" FROM (SELECT posts.*, ROW_NUMBER() OVER (PARTITION BY posts.author_id ORDER BY posts.created_at DESC, posts.post_id DESC) AS position FROM posts WHERE posts.author_id=ANY($1) AND ($2='' OR (posts.created_at, posts.post_id) < ($3, $2))) AS posts JOIN authors ON(authors.author_id=posts.author_id) WHERE posts.position <= $4 ORDER BY posts.created_at DESC, posts.post_id DESC;", pq.Array(authorIds), after.Id, after.CreatedAt, limit)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	result, err := scanPosts(rows)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, post := range result {
+		posts[post.AuthorInfo.AuthorId] = append(posts[post.AuthorInfo.AuthorId], post)
+	}
+
+	return posts, totals, nil
+}
+
+// scanPosts reads rows of postColumns
+func scanPosts(rows *sql.Rows) ([]types.Post, error) {
+	result := make([]types.Post, 0)
+
+	for rows.Next() {
+		var post types.Post
+
+		err := rows.Scan(&post.AuthorInfo.Username, &post.AuthorInfo.AuthorId, &post.AuthorInfo.CreatedAt, &post.Id, &post.Title, &post.Body, &post.CreatedAt, &post.UpdatedAt, &post.Revision, pq.Array(&post.Tags))
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, post)
+	}
+
+	// get any error encountered during iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/helpers"
+)
+
+// number of recovery codes given to an author on enabling TOTP
+const recoveryCodeCount = 10
+
+// invalid second factor codes in a row after which the second factor of an author is locked, and
+// for how long
+const (
+	maxSecondFactorAttempts = 5
+	secondFactorLockout     = 15 * time.Minute
+)
+
+// ErrTOTPEnabled is returned when enrolling an author who already has TOTP enabled
+var ErrTOTPEnabled = errors.New("Two-factor authentication already enabled!")
+
+// ErrTOTPNotEnrolled is returned when confirming or disabling TOTP of an author who hasn't enrolled
+var ErrTOTPNotEnrolled = errors.New("Two-factor authentication not enrolled!")
+
+// ErrInvalidCode is returned when a TOTP or recovery code doesn't match
+var ErrInvalidCode = errors.New("Invalid code!")
+
+// ErrSecondFactorLocked is returned when checking a code of an author after too many invalid ones
+var ErrSecondFactorLocked = errors.New("Too many invalid codes, try again later!")
+
+// EnrolTOTP creates a new unconfirmed TOTP secret for an author and returns it with its
+// provisioning URI
+func EnrolTOTP(authorId string) (string, string, error) {
+	enabled, err := TOTPEnabled(authorId)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if enabled {
+		return "", "", ErrTOTPEnabled
+	}
+
+	var username string
+
+	err = config.DB.QueryRow("SELECT username FROM authors WHERE author_id=$1;", authorId).Scan(&username)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	sqlStatement := `
+	INSERT INTO author_totp (author_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (author_id) DO UPDATE SET secret=EXCLUDED.secret, created_at=now(), last_used_step=0, failed_attempts=0, locked_until=NULL;`
+
+	if _, err = config.DB.Exec(sqlStatement, authorId, secret); err != nil {
+		return "", "", err
+	}
+
+	return secret, helpers.TOTPProvisioningURI(secret, "goblog", username), nil
+}
+
+// ConfirmTOTP enables the enrolled TOTP secret of an author if code matches it and returns fresh
+// recovery codes
+func ConfirmTOTP(authorId, code string) ([]string, error) {
+	var (
+		secret      string
+		confirmedAt *time.Time
+	)
+
+	err := config.DB.QueryRow("SELECT secret, confirmed_at FROM author_totp WHERE author_id=$1;", authorId).Scan(&secret, &confirmedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmedAt != nil {
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := helpers.ValidateTOTP(secret, code, time.Now())
+
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("UPDATE author_totp SET confirmed_at=now(), last_used_step=$1 WHERE author_id=$2;", step, authorId); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("DELETE FROM author_recovery_codes WHERE author_id=$1;", authorId); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		if _, err = tx.Exec("INSERT INTO author_recovery_codes (author_id, code_hash) VALUES ($1, $2);", authorId, hashRecoveryCode(code)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP removes the TOTP secret and recovery codes of an author after checking code
+func DisableTOTP(authorId, code string) error {
+	ok, err := CheckSecondFactor(authorId, code)
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	if _, err = config.DB.Exec("DELETE FROM author_recovery_codes WHERE author_id=$1;", authorId); err != nil {
+		return err
+	}
+
+	_, err = config.DB.Exec("DELETE FROM author_totp WHERE author_id=$1;", authorId)
+
+	return err
+}
+
+// TOTPEnabled returns whether an author must supply a second factor when logging in
+func TOTPEnabled(authorId string) (bool, error) {
+	var enabled bool
+
+	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM author_totp WHERE author_id=$1 AND confirmed_at IS NOT NULL);", authorId).Scan(&enabled)
+
+	return enabled, err
+}
+
+// CheckSecondFactor checks code as either a TOTP code or an unused recovery code of an author
+//
+// Accepted codes can't be used again. After maxSecondFactorAttempts invalid codes in a row no code
+// is checked for secondFactorLockout and ErrSecondFactorLocked is returned.
+func CheckSecondFactor(authorId, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if code == "" {
+		return false, nil
+	}
+
+	// the attempt is counted before checking the code, so that concurrent guesses can't get past the
+	// limit, and locks the second factor if it's the last one allowed, until a valid code resets it
+	sqlStatement := `
+	UPDATE author_totp SET
+		failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+		locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN now() + $3 * interval '1 second' END
+	WHERE author_id=$1 AND confirmed_at IS NOT NULL AND (locked_until IS NULL OR locked_until <= now())
+	RETURNING secret;`
+
+	var secret string
+
+	err := config.DB.QueryRow(sqlStatement, authorId, maxSecondFactorAttempts, int(secondFactorLockout.Seconds())).Scan(&secret)
+
+	if err == sql.ErrNoRows {
+		enabled, err := TOTPEnabled(authorId)
+
+		if err != nil {
+			return false, err
+		}
+
+		if enabled {
+			return false, ErrSecondFactorLocked
+		}
+
+		return false, ErrTOTPNotEnrolled
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	matched, err := matchSecondFactor(authorId, secret, code)
+
+	if err != nil || !matched {
+		return false, err
+	}
+
+	_, err = config.DB.Exec("UPDATE author_totp SET failed_attempts=0, locked_until=NULL WHERE author_id=$1;", authorId)
+
+	return err == nil, err
+}
+
+// matchSecondFactor checks code as either a TOTP code of secret or an unused recovery code of an
+// author, and marks it as used
+func matchSecondFactor(authorId, secret, code string) (bool, error) {
+	if step, ok := helpers.ValidateTOTP(secret, code, time.Now()); ok {
+		// only accepted if no code of this or a later step has been used
+		result, err := config.DB.Exec("UPDATE author_totp SET last_used_step=$1 WHERE author_id=$2 AND last_used_step < $1;", step, authorId)
+
+		if err != nil {
+			return false, err
+		}
+
+		updated, err := result.RowsAffected()
+
+		return updated == 1, err
+	}
+
+	result, err := config.DB.Exec("UPDATE author_recovery_codes SET used_at=now() WHERE author_id=$1 AND code_hash=$2 AND used_at IS NULL;", authorId, hashRecoveryCode(code))
+
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+
+	return updated == 1, err
+}
+
+// hashRecoveryCode returns the form in which recovery codes are stored
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+
+	return hex.EncodeToString(sum[:])
+}
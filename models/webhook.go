@@ -0,0 +1,307 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samkit-jain/go-blog/config"
+	"github.com/samkit-jain/go-blog/events"
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookJob is a pending delivery claimed for sending
+type WebhookJob struct {
+	DeliveryId string
+	EventId    string
+	Event      string
+	URL        string
+	Secret     string
+	Payload    []byte
+	Attempts   int // attempts made before this one
+}
+
+// CreateWebhook creates a webhook of an author with a random secret and returns it, the secret is
+// only returned here
+func CreateWebhook(authorId, url string, eventTypes []string) (types.Webhook, error) {
+	webhookId, err := helpers.NewId()
+
+	if err != nil {
+		return types.Webhook{}, err
+	}
+
+	secret, err := helpers.NewSecret("whsec_")
+
+	if err != nil {
+		return types.Webhook{}, err
+	}
+
+	webhook := types.Webhook{Id: webhookId, URL: url, Events: eventTypes, Secret: secret}
+
+	sqlStatement := `
+	INSERT INTO webhooks (webhook_id, author_id, url, secret, events)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at;`
+
+	err = config.DB.QueryRow(sqlStatement, webhookId, authorId, url, webhook.Secret, pq.Array(eventTypes)).Scan(&webhook.CreatedAt)
+
+	if err != nil {
+		return types.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks returns the webhooks of an author, newest first
+func GetWebhooks(authorId string) ([]types.Webhook, error) {
+	result := make([]types.Webhook, 0)
+	rows, err := config.DB.Query("SELECT webhook_id, url, events, created_at FROM webhooks WHERE author_id=$1 ORDER BY created_at DESC;", authorId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook types.Webhook
+
+		if err := rows.Scan(&webhook.Id, &webhook.URL, pq.Array(&webhook.Events), &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		result = append(result, webhook)
+	}
+
+	return result, rows.Err()
+}
+
+// DeleteWebhook deletes a webhook of an author with its deliveries, sql.ErrNoRows is returned if
+// the author has no such webhook
+func DeleteWebhook(authorId, webhookId string) error {
+	result, err := config.DB.Exec("DELETE FROM webhooks WHERE webhook_id=$1 AND author_id=$2;", webhookId, authorId)
+
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a webhook of an author, newest first,
+// sql.ErrNoRows is returned if the author has no such webhook
+func GetWebhookDeliveries(authorId, webhookId string, limit int) ([]types.WebhookDelivery, error) {
+	if err := checkWebhookOwner(authorId, webhookId); err != nil {
+		return nil, err
+	}
+
+	result := make([]types.WebhookDelivery, 0)
+	rows, err := config.DB.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id=$1 ORDER BY created_at DESC, delivery_id DESC LIMIT $2;", webhookId, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, delivery)
+	}
+
+	return result, rows.Err()
+}
+
+// RedeliverWebhook queues a new delivery of the event of a past delivery and returns it,
+// sql.ErrNoRows is returned if the author has no such webhook or delivery
+func RedeliverWebhook(authorId, webhookId, deliveryId string) (types.WebhookDelivery, error) {
+	if err := checkWebhookOwner(authorId, webhookId); err != nil {
+		return types.WebhookDelivery{}, err
+	}
+
+	newId, err := helpers.NewId()
+
+	if err != nil {
+		return types.WebhookDelivery{}, err
+	}
+
+	sqlStatement := `
+	INSERT INTO webhook_deliveries (delivery_id, webhook_id, event_id, event, payload)
+	SELECT $1, webhook_id, event_id, event, payload FROM webhook_deliveries WHERE delivery_id=$2 AND webhook_id=$3
+	RETURNING ` + deliveryColumns + `;`
+
+	return scanDelivery(config.DB.QueryRow(sqlStatement, newId, deliveryId, webhookId))
+}
+
+// EnqueueWebhookDeliveries queues a delivery of event to every webhook subscribed to its type
+func EnqueueWebhookDeliveries(event events.Event) error {
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := config.DB.Query("SELECT webhook_id FROM webhooks WHERE $1=ANY(events);", event.Type)
+
+	if err != nil {
+		return err
+	}
+
+	var webhookIds []string
+
+	for rows.Next() {
+		var webhookId string
+
+		if err := rows.Scan(&webhookId); err != nil {
+			rows.Close()
+			return err
+		}
+
+		webhookIds = append(webhookIds, webhookId)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, webhookId := range webhookIds {
+		deliveryId, err := helpers.NewId()
+
+		if err != nil {
+			return err
+		}
+
+		_, err = config.DB.Exec("INSERT INTO webhook_deliveries (delivery_id, webhook_id, event_id, event, payload) VALUES ($1, $2, $3, $4, $5);",
+			deliveryId, webhookId, event.Id, event.Type, string(payload))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimWebhookJobs returns up to limit due pending deliveries and holds them back from other
+// claimers for lease, so that several instances can send deliveries without sending one twice
+func ClaimWebhookJobs(limit int, lease time.Duration) ([]WebhookJob, error) {
+	sqlStatement := `
+	UPDATE webhook_deliveries SET next_attempt_at=NOW() + $2 * INTERVAL '1 second'
+	FROM webhooks
+	WHERE webhooks.webhook_id=webhook_deliveries.webhook_id AND delivery_id IN (
+		SELECT delivery_id FROM webhook_deliveries
+		WHERE status='pending' AND next_attempt_at<=NOW()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING delivery_id, event_id, event, webhooks.url, webhooks.secret, payload, attempts;`
+
+	rows, err := config.DB.Query(sqlStatement, limit, lease.Seconds())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var jobs []WebhookJob
+
+	for rows.Next() {
+		var (
+			job     WebhookJob
+			payload string
+		)
+
+		if err := rows.Scan(&job.DeliveryId, &job.EventId, &job.Event, &job.URL, &job.Secret, &payload, &job.Attempts); err != nil {
+			return nil, err
+		}
+
+		job.Payload = []byte(payload)
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FinishWebhookJob records an attempt of a delivery, it's retried at nextAttempt if still pending
+func FinishWebhookJob(deliveryId, status string, responseStatus int, message string, nextAttempt time.Time) error {
+	sqlStatement := `
+	UPDATE webhook_deliveries
+	SET status=$2, attempts=attempts+1, response_status=$3, error=$4, next_attempt_at=$5,
+		delivered_at=CASE WHEN $2='succeeded' THEN NOW() END
+	WHERE delivery_id=$1;`
+
+	_, err := config.DB.Exec(sqlStatement, deliveryId, status, sql.NullInt64{Int64: int64(responseStatus), Valid: responseStatus != 0}, nullString(message), nextAttempt)
+
+	return err
+}
+
+// checkWebhookOwner returns sql.ErrNoRows unless the author has the webhook
+func checkWebhookOwner(authorId, webhookId string) error {
+	var exists bool
+
+	err := config.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM webhooks WHERE webhook_id=$1 AND author_id=$2);", webhookId, authorId).Scan(&exists)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// columns read by scanDelivery
+const deliveryColumns = "delivery_id, event_id, event, status, attempts, response_status, error, created_at, next_attempt_at, delivered_at"
+
+// scanDelivery reads a delivery selected with deliveryColumns
+func scanDelivery(row interface{ Scan(...interface{}) error }) (types.WebhookDelivery, error) {
+	var (
+		delivery       types.WebhookDelivery
+		responseStatus sql.NullInt64
+		message        sql.NullString
+		nextAttemptAt  time.Time
+	)
+
+	err := row.Scan(&delivery.Id, &delivery.EventId, &delivery.Event, &delivery.Status, &delivery.Attempts,
+		&responseStatus, &message, &delivery.CreatedAt, &nextAttemptAt, &delivery.DeliveredAt)
+
+	if err != nil {
+		return types.WebhookDelivery{}, err
+	}
+
+	delivery.ResponseStatus = int(responseStatus.Int64)
+	delivery.Error = message.String
+
+	if delivery.Status == DeliveryPending {
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+
+	return delivery, nil
+}
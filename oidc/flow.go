@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// time an author has to log in at the identity provider
+const flowLifetime = 10 * time.Minute
+
+// ErrInvalidFlow is returned for flows that have expired or been tampered with
+var ErrInvalidFlow = errors.New("Sign in expired, please try again!")
+
+// Flow holds what the app needs to remember between redirecting an author to the identity
+// provider and the provider redirecting back
+type Flow struct {
+	State    string `json:"state"`               // sent along and compared on the way back against CSRF
+	Nonce    string `json:"nonce"`               // binds the ID token to this flow
+	Verifier string `json:"verifier"`            // PKCE code verifier
+	AuthorId string `json:"author_id,omitempty"` // author to link the identity to, empty to log in
+	jwt.StandardClaims
+}
+
+// NewFlow starts a flow with a random state, nonce and verifier
+func NewFlow(authorId string) (Flow, error) {
+	flow := Flow{AuthorId: authorId}
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := NewRandom()
+
+		if err != nil {
+			return Flow{}, err
+		}
+
+		*value = random
+	}
+
+	return flow, nil
+}
+
+// Flow's Encode returns the flow as a token signed with the app's signing key, to be stored in a
+// cookie until the identity provider redirects back
+func (f Flow) Encode() (string, error) {
+	f.StandardClaims = jwt.StandardClaims{
+		ExpiresAt: time.Now().Add(flowLifetime).Unix(),
+		Issuer:    "goblog-oidc",
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, f).SignedString([]byte(os.Getenv("GOBLOG_SIGNING_KEY")))
+}
+
+// DecodeFlow returns the flow encoded by Encode
+func DecodeFlow(tokenString string) (Flow, error) {
+	var flow Flow
+
+	token, err := jwt.ParseWithClaims(tokenString, &flow, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidFlow
+		}
+
+		return []byte(os.Getenv("GOBLOG_SIGNING_KEY")), nil
+	})
+
+	if err != nil || !token.Valid || flow.Issuer != "goblog-oidc" {
+		return Flow{}, ErrInvalidFlow
+	}
+
+	return flow, nil
+}
@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// minimum time between fetches of the JWKS, unknown key IDs refetch it to pick up rotated keys
+const keysRefreshInterval = time.Minute
+
+// JSON web key as found in a JWKS (RFC 7517), only the members of RSA and EC public keys
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyId   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// Provider's key returns the public key with ID keyId, an empty ID matches a JWKS of a single key
+func (p *Provider) key(keyId string) (interface{}, error) {
+	d, err := p.discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := lookupKey(p.keys, keyId); key != nil {
+		return key, nil
+	}
+
+	if time.Since(p.keysAt) < keysRefreshInterval {
+		return nil, errors.New("unknown key " + keyId)
+	}
+
+	req, err := http.NewRequest("GET", d.JWKSURI, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := p.getJSON(req, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// unsupported key types are skipped, another key may still be usable
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyId] = key
+		}
+	}
+
+	p.keys, p.keysAt = keys, time.Now()
+
+	if key := lookupKey(p.keys, keyId); key != nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unknown key " + keyId)
+}
+
+// lookupKey returns the key with ID keyId, or the only key if keyId is empty
+func lookupKey(keys map[string]interface{}, keyId string) interface{} {
+	if keyId == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+
+	return keys[keyId]
+}
+
+// jsonWebKey's publicKey returns the *rsa.PublicKey or *ecdsa.PublicKey of the key
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeInt(k.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInt(k.E)
+
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve " + k.Curve)
+		}
+
+		x, err := decodeInt(k.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInt(k.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, errors.New("unsupported key type " + k.KeyType)
+	}
+}
+
+// decodeInt decodes a base64url encoded big-endian integer
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,361 @@
+// Package oidc logs authors in through an OpenID Connect identity provider using the authorization
+// code flow with PKCE
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrInvalidIDToken is returned for ID tokens that fail verification
+var ErrInvalidIDToken = errors.New("Invalid ID token!")
+
+// Identity of an author at the identity provider, read from a verified ID token
+type Identity struct {
+	Issuer        string // identity provider
+	Subject       string // stable ID of the user at the identity provider
+	Username      string // value of the username claim, used when provisioning an author
+	Email         string // value of the email claim
+	EmailVerified bool   // whether the identity provider verified Email
+}
+
+// Provider is an OpenID Connect identity provider the app is registered at as a client
+type Provider struct {
+	Issuer        string   // issuer URL, the discovery document is read from below it
+	ClientID      string   // client ID of the app
+	ClientSecret  string   // client secret of the app, empty for a public client
+	Scopes        []string // scopes requested, "openid" is always requested
+	UsernameClaim string   // claim mapped to the username of provisioned authors
+	EmailClaim    string   // claim mapped to the email of provisioned authors
+	Provision     bool     // whether authors are created for identities not linked to any
+	RedirectURIs  []string // redirect URIs API clients may use besides loopback ones
+	Client        *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]interface{} // public keys of the JWKS by key ID
+	keysAt    time.Time              // when keys were fetched
+}
+
+// endpoints read from the discovery document
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// provider used for logging in, nil if single sign-on is disabled
+var Default *Provider
+
+// InitProvider sets up Default from GOBLOG_OIDC_ISSUER, GOBLOG_OIDC_CLIENT_ID,
+// GOBLOG_OIDC_CLIENT_SECRET, GOBLOG_OIDC_SCOPES, GOBLOG_OIDC_USERNAME_CLAIM,
+// GOBLOG_OIDC_EMAIL_CLAIM, GOBLOG_OIDC_PROVISION and GOBLOG_OIDC_REDIRECT_URIS, single sign-on is disabled unless
+// GOBLOG_OIDC_ISSUER is set
+//
+// The discovery document is only read on the first login so the app starts while the identity
+// provider is unreachable.
+func InitProvider() {
+	issuer := os.Getenv("GOBLOG_OIDC_ISSUER")
+
+	if issuer == "" {
+		Default = nil
+		return
+	}
+
+	provider := &Provider{
+		Issuer:        strings.TrimRight(issuer, "/"),
+		ClientID:      os.Getenv("GOBLOG_OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("GOBLOG_OIDC_CLIENT_SECRET"),
+		Scopes:        strings.Fields(strings.Replace(os.Getenv("GOBLOG_OIDC_SCOPES"), ",", " ", -1)),
+		UsernameClaim: os.Getenv("GOBLOG_OIDC_USERNAME_CLAIM"),
+		EmailClaim:    os.Getenv("GOBLOG_OIDC_EMAIL_CLAIM"),
+		Client:        &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if provider.ClientID == "" {
+		panic("GOBLOG_OIDC_CLIENT_ID is required with GOBLOG_OIDC_ISSUER")
+	}
+
+	if provider.Scopes == nil {
+		provider.Scopes = []string{"profile", "email"}
+	}
+
+	if provider.UsernameClaim == "" {
+		provider.UsernameClaim = "preferred_username"
+	}
+
+	if provider.EmailClaim == "" {
+		provider.EmailClaim = "email"
+	}
+
+	provider.Provision, _ = strconv.ParseBool(os.Getenv("GOBLOG_OIDC_PROVISION"))
+	provider.RedirectURIs = strings.Fields(strings.Replace(os.Getenv("GOBLOG_OIDC_REDIRECT_URIS"), ",", " ", -1))
+
+	Default = provider
+}
+
+// Provider's AuthCodeURL returns the URL of the identity provider's login page, it redirects back
+// to redirectURI with the code and state
+func (p *Provider) AuthCodeURL(redirectURI, state, nonce, verifier string) (string, error) {
+	d, err := p.discover()
+
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Provider's RedirectAllowed reports if an API client may redeem a code issued for redirectURI,
+// loopback URIs of native apps (RFC 8252) are always allowed
+func (p *Provider) RedirectAllowed(redirectURI string) bool {
+	for _, allowed := range p.RedirectURIs {
+		if redirectURI == allowed {
+			return true
+		}
+	}
+
+	u, err := url.Parse(redirectURI)
+
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+
+	host := u.Hostname()
+
+	return host == "127.0.0.1" || host == "::1" || host == "localhost"
+}
+
+// Provider's Exchange redeems an authorization code and returns the identity of its verified ID
+// token, nonce is checked unless empty
+func (p *Provider) Exchange(code, verifier, redirectURI, nonce string) (Identity, error) {
+	d, err := p.discover()
+
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+		"client_id":     {p.ClientID},
+	}
+
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return Identity{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := p.getJSON(req, &body); err != nil && body.Error == "" {
+		return Identity{}, err
+	}
+
+	if body.Error != "" {
+		return Identity{}, fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+
+	return p.Verify(body.IDToken, nonce)
+}
+
+// Provider's Verify checks the signature, issuer, audience, expiry and, unless empty, nonce of an
+// ID token and returns the identity it asserts
+func (p *Provider) Verify(idToken, nonce string) (Identity, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		keyId, _ := token.Header["kid"].(string)
+
+		return p.key(keyId)
+	})
+
+	if err != nil || !token.Valid {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if !claims.VerifyIssuer(p.Issuer, true) || !p.audienceValid(claims) {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	// ID tokens must expire
+	if _, ok := claims["exp"]; !ok {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	if nonce != "" && claims["nonce"] != nonce {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	identity := Identity{Issuer: p.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Username, _ = claims[p.UsernameClaim].(string)
+	identity.Email, _ = claims[p.EmailClaim].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+
+	if identity.Subject == "" {
+		return Identity{}, ErrInvalidIDToken
+	}
+
+	return identity, nil
+}
+
+// Provider's audienceValid reports if the client is an audience of the token and, if there are
+// several, its authorized party
+func (p *Provider) audienceValid(claims jwt.MapClaims) bool {
+	var audiences []string
+
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+
+	found := false
+
+	for _, audience := range audiences {
+		if audience == p.ClientID {
+			found = true
+		}
+	}
+
+	if azp, ok := claims["azp"].(string); ok && azp != p.ClientID {
+		return false
+	}
+
+	return found
+}
+
+// Provider's discover returns the endpoints of the discovery document, reading it on first use
+func (p *Provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest("GET", p.Issuer+"/.well-known/openid-configuration", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var d discovery
+
+	if err := p.getJSON(req, &d); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %s", p.Issuer, d.Issuer)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document lacks endpoints")
+	}
+
+	p.discovery = &d
+
+	return p.discovery, nil
+}
+
+// Provider's getJSON sends req and decodes the JSON response into v, error responses are decoded
+// too but reported as an error
+func (p *Provider) getJSON(req *http.Request, v interface{}) error {
+	client := p.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	decodeErr := json.NewDecoder(http.MaxBytesReader(nil, res.Body, 1<<20)).Decode(v)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, res.Status)
+	}
+
+	return decodeErr
+}
+
+// NewRandom returns a random URL safe string, used for states, nonces and PKCE verifiers
+func NewRandom() (string, error) {
+	random := make([]byte, 32)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// Challenge returns the S256 PKCE code challenge of verifier (RFC 7636)
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,231 @@
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testRedirectURI = "http://127.0.0.1:8400/callback"
+
+// newTestProvider starts a stub identity provider and returns it with a provider using it
+func newTestProvider(t *testing.T) (*Stub, *Provider) {
+	stub, err := NewStub("http://idp.invalid", "goblog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	stub.Issuer = server.URL
+
+	provider := &Provider{
+		Issuer:        server.URL,
+		ClientID:      "goblog",
+		UsernameClaim: "preferred_username",
+		EmailClaim:    "email",
+		RedirectURIs:  []string{"https://app.example.com/callback"},
+		Client:        server.Client(),
+	}
+
+	return stub, provider
+}
+
+// authorize logs in at the stub through the provider's authorization URL, with the parameters of
+// extra (e.g. login_hint), and returns the code it redirects to redirectURI with
+func authorize(t *testing.T, p *Provider, redirectURI, nonce, verifier string, extra url.Values) string {
+	authURL, err := p.AuthCodeURL(redirectURI, "state-1", nonce, verifier)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, values := range extra {
+		for _, value := range values {
+			authURL += "&" + url.QueryEscape(name) + "=" + url.QueryEscape(value)
+		}
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil || res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize responded %s, location %q", res.Status, res.Header.Get("Location"))
+	}
+
+	if location.Query().Get("state") != "state-1" {
+		t.Errorf("state %q not passed back", location.Query().Get("state"))
+	}
+
+	return location.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	code := authorize(t, p, testRedirectURI, "nonce-1", "verifier-1", url.Values{
+		"login_hint":     {"subject-1"},
+		"email":          {"sam@example.com"},
+		"email_verified": {"true"},
+	})
+
+	identity, err := p.Exchange(code, "verifier-1", testRedirectURI, "nonce-1")
+
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := Identity{Issuer: p.Issuer, Subject: "subject-1", Username: "subject-1", Email: "sam@example.com", EmailVerified: true}
+
+	if identity != want {
+		t.Errorf("identity is %+v, want %+v", identity, want)
+	}
+
+	// codes can only be redeemed once
+	if _, err := p.Exchange(code, "verifier-1", testRedirectURI, "nonce-1"); err == nil {
+		t.Error("code redeemed twice")
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		verifier    string // sent with the code issued for "verifier-1"
+		redirectURI string // sent with the code issued for testRedirectURI
+		nonce       string // expected, the code was issued for "nonce-1"
+	}{
+		{"wrong nonce", "verifier-1", testRedirectURI, "nonce-2"},
+		{"bad verifier", "verifier-2", testRedirectURI, "nonce-1"},
+		{"other redirect URI", "verifier-1", "http://127.0.0.1:8401/callback", "nonce-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, p := newTestProvider(t)
+
+			code := authorize(t, p, testRedirectURI, "nonce-1", "verifier-1", url.Values{"login_hint": {"subject-1"}})
+
+			if identity, err := p.Exchange(code, test.verifier, test.redirectURI, test.nonce); err == nil {
+				t.Errorf("Exchange accepted the code, identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	stub, p := newTestProvider(t)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   stub.Issuer,
+			"aud":   "goblog",
+			"sub":   "subject-1",
+			"nonce": "nonce-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(jwt.MapClaims)
+		valid  bool
+	}{
+		{"valid", func(jwt.MapClaims) {}, true},
+		{"several audiences", func(c jwt.MapClaims) { c["aud"] = []interface{}{"other", "goblog"}; c["azp"] = "goblog" }, true},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other" }, false},
+		{"other authorized party", func(c jwt.MapClaims) { c["aud"] = []interface{}{"other", "goblog"}; c["azp"] = "other" }, false},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, false},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "nonce-2" }, false},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, false},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }, false},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := valid()
+			test.change(claims)
+
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = "stub"
+
+			idToken, err := token.SignedString(stub.key)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.Verify(idToken, "nonce-1")
+
+			if test.valid && err != nil {
+				t.Errorf("Verify rejected the token: %v", err)
+			} else if !test.valid && err != ErrInvalidIDToken {
+				t.Errorf("Verify returned %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyOtherKey(t *testing.T) {
+	_, p := newTestProvider(t)
+	other, err := NewStub(p.Issuer, "goblog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": p.Issuer,
+		"aud": "goblog",
+		"sub": "subject-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "stub"
+
+	idToken, err := token.SignedString(other.key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Verify(idToken, ""); err != ErrInvalidIDToken {
+		t.Errorf("Verify returned %v for a token signed by another key, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestRedirectAllowed(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	tests := map[string]bool{
+		"https://app.example.com/callback":        true,
+		"http://127.0.0.1:8400/callback":          true,
+		"http://localhost/callback":               true,
+		"http://[::1]:8400/callback":              true,
+		"https://app.example.com/other":           false,
+		"https://evil.example.com/callback":       false,
+		"https://127.0.0.1/callback":              false,
+		"http://127.0.0.1.evil.example.com/":      false,
+		"javascript:alert(1)":                     false,
+		"http://app.example.com/callback":         false,
+		"https://app.example.com/callback?x=evil": false,
+	}
+
+	for redirectURI, want := range tests {
+		if got := p.RedirectAllowed(redirectURI); got != want {
+			t.Errorf("RedirectAllowed(%q) is %v, want %v", redirectURI, got, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Stub is a minimal identity provider for local development and tests
+//
+// Its login page asks for the claims of the user to log in as, without a password. Authorization
+// requests with a "login_hint" skip the page and log in as that subject right away, which lets
+// scripts run the whole flow without a browser.
+type Stub struct {
+	Issuer   string // URL the stub is reachable at
+	ClientID string // only client accepted
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]stubCode
+}
+
+// authorization code issued by the stub and what it was issued for
+type stubCode struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]interface{}
+	expiresAt   time.Time
+}
+
+// NewStub creates a stub identity provider with a fresh signing key
+func NewStub(issuer, clientID string) (*Stub, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stub{Issuer: strings.TrimRight(issuer, "/"), ClientID: clientID, key: key, codes: map[string]stubCode{}}, nil
+}
+
+// Stub's ServeHTTP serves the discovery document, the login page, the token endpoint and the JWKS
+func (s *Stub) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/.well-known/openid-configuration":
+		s.writeJSON(res, http.StatusOK, map[string]interface{}{
+			"issuer":                                s.Issuer,
+			"authorization_endpoint":                s.Issuer + "/authorize",
+			"token_endpoint":                        s.Issuer + "/token",
+			"jwks_uri":                              s.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/authorize":
+		s.authorize(res, req)
+	case "/token":
+		s.token(res, req)
+	case "/jwks":
+		s.writeJSON(res, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}}})
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+}
+
+var stubLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<title>Stub identity provider</title>
+<h1>Log in as</h1>
+<form method="post">
+	<label>Subject <input name="sub" required></label>
+	<label>Username <input name="preferred_username"></label>
+	<label>Email <input name="email" type="email"></label>
+	<label><input name="email_verified" type="checkbox" value="true" checked> Email verified</label>
+	<button>Log in</button>
+</form>`))
+
+// Stub's authorize shows the login page and redirects back with a code once submitted, the page
+// posts to its own URL so the authorization request's query is kept
+func (s *Stub) authorize(res http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	form := req.Form
+
+	if form.Get("client_id") != s.ClientID || form.Get("response_type") != "code" || form.Get("redirect_uri") == "" {
+		http.Error(res, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	if form.Get("code_challenge") == "" || form.Get("code_challenge_method") != "S256" {
+		http.Error(res, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	subject := form.Get("sub")
+
+	if subject == "" {
+		subject = form.Get("login_hint")
+	}
+
+	if subject == "" {
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+		stubLoginPage.Execute(res, nil)
+		return
+	}
+
+	claims := map[string]interface{}{"sub": subject, "preferred_username": subject}
+
+	if username := form.Get("preferred_username"); username != "" {
+		claims["preferred_username"] = username
+	}
+
+	if email := form.Get("email"); email != "" {
+		claims["email"] = email
+		claims["email_verified"] = form.Get("email_verified") == "true"
+	}
+
+	code, err := NewRandom()
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.codes[code] = stubCode{
+		redirectURI: form.Get("redirect_uri"),
+		challenge:   form.Get("code_challenge"),
+		nonce:       form.Get("nonce"),
+		claims:      claims,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	query := url.Values{"code": {code}, "state": {form.Get("state")}}
+
+	http.Redirect(res, req, form.Get("redirect_uri")+"?"+query.Encode(), http.StatusFound)
+}
+
+// Stub's token redeems a code for an ID token, checking the PKCE verifier
+func (s *Stub) token(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(res, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientId, _, ok := req.BasicAuth()
+
+	if !ok {
+		clientId = req.FormValue("client_id")
+	}
+
+	s.mu.Lock()
+	code, found := s.codes[req.FormValue("code")]
+	delete(s.codes, req.FormValue("code"))
+	s.mu.Unlock()
+
+	if !found || time.Now().After(code.expiresAt) || clientId != s.ClientID || req.FormValue("redirect_uri") != code.redirectURI || Challenge(req.FormValue("code_verifier")) != code.challenge {
+		s.writeJSON(res, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss": s.Issuer,
+		"aud": s.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+
+	for name, value := range code.claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub"
+
+	idToken, err := token.SignedString(s.key)
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(res, http.StatusOK, map[string]interface{}{"access_token": idToken, "token_type": "Bearer", "id_token": idToken, "expires_in": 300})
+}
+
+// Stub's writeJSON responds with v encoded as JSON
+func (s *Stub) writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(v)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore counts requests in memory, counts aren't shared between instances
+type MemoryStore struct {
+	mutex     sync.Mutex
+	windows   map[string]*memoryWindow
+	lastSweep time.Time
+}
+
+// counter of a key's current window
+type memoryWindow struct {
+	start time.Time
+	end   time.Time
+	count int
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: map[string]*memoryWindow{}}
+}
+
+// MemoryStore's Take counts a request for key
+func (s *MemoryStore) Take(key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+	start := windowStart(now, window)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// dropping ended windows once a minute keeps the map from growing with every client seen
+	if now.Sub(s.lastSweep) > time.Minute {
+		for windowKey, counter := range s.windows {
+			if !now.Before(counter.end) {
+				delete(s.windows, windowKey)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	counter, ok := s.windows[key]
+
+	if !ok || !counter.start.Equal(start) {
+		counter = &memoryWindow{start: start, end: start.Add(window)}
+		s.windows[key] = counter
+	}
+
+	counter.count++
+
+	return result(counter.count, limit, start, window, now), nil
+}
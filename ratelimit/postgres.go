@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/samkit-jain/go-blog/config"
+)
+
+// PostgresStore counts requests in the rate_limits table, shared by all instances using the
+// database
+type PostgresStore struct {
+}
+
+// PostgresStore's Take counts a request for key
+func (s *PostgresStore) Take(key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+	start := windowStart(now, window)
+
+	sqlStatement := `
+	INSERT INTO rate_limits (key, window_start, window_end, count)
+	VALUES ($1, $2, $3, 1)
+	ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limits.count + 1
+	RETURNING count;`
+
+	var count int
+
+	if err := config.DB.QueryRow(sqlStatement, key, start, start.Add(window)).Scan(&count); err != nil {
+		return Result{}, err
+	}
+
+	// ended windows are removed now and then instead of by a separate job
+	if rand.Intn(1000) == 0 {
+		config.DB.Exec("DELETE FROM rate_limits WHERE window_end < NOW();")
+	}
+
+	return result(count, limit, start, window, now), nil
+}
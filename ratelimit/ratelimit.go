@@ -0,0 +1,68 @@
+// Package ratelimit counts requests of clients in fixed time windows, in memory or in the database
+// so that several instances share the counts
+package ratelimit
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Result of counting a request
+type Result struct {
+	Limit     int           // requests allowed per window
+	Remaining int           // requests left in the current window
+	Reset     time.Duration // time until the current window ends
+	Allowed   bool          // whether the request is within the limit
+}
+
+// Store is implemented by anything that can count requests per key and window
+type Store interface {
+	// Take counts a request for key in the current window of length window and returns whether it
+	// exceeds limit
+	Take(key string, limit int, window time.Duration) (Result, error)
+}
+
+// windowStart returns the start of the window of length window that now falls in
+func windowStart(now time.Time, window time.Duration) time.Time {
+	return now.Truncate(window)
+}
+
+// result returns the Result of the count-th request of a window
+func result(count, limit int, start time.Time, window time.Duration, now time.Time) Result {
+	remaining := limit - count
+
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return Result{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     start.Add(window).Sub(now),
+		Allowed:   count <= limit,
+	}
+}
+
+// store used by the API, nil if rate limiting is disabled
+var Default Store
+
+// InitStore sets up Default from GOBLOG_RATE_LIMIT_STORE
+//
+// "memory" (default): counts of this instance only
+//
+// "postgres": counts shared by all instances in the rate_limits table
+//
+// "off": no rate limiting
+func InitStore() {
+	switch kind := os.Getenv("GOBLOG_RATE_LIMIT_STORE"); kind {
+	case "", "memory":
+		Default = NewMemoryStore()
+	case "postgres":
+		Default = new(PostgresStore)
+	case "off":
+		Default = nil
+	default:
+		log.Fatalf("unknown GOBLOG_RATE_LIMIT_STORE %q", kind)
+	}
+}
@@ -0,0 +1,420 @@
+// Package staticsite renders the website into a directory of HTML files and feeds, which can be
+// hosted without the app
+//
+// Pages and the active theme's static assets are rendered by website.WebsiteHandler, so they're the
+// same as the ones it serves. Every page is fingerprinted with the content it shows and the theme,
+// and a build only renders the pages whose fingerprints changed since the last build in the same
+// directory.
+package staticsite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/storage"
+	"github.com/samkit-jain/go-blog/types"
+	"github.com/samkit-jain/go-blog/website"
+)
+
+// file keeping the state of the last build in the output directory
+const stateFile = ".goblog-build.json"
+
+// version of the pages' layout, a build with another version renders every page
+const buildVersion = 1
+
+// Options of a build
+type Options struct {
+	Out   string // directory the site is written to
+	Force bool   // render every page, even unchanged ones
+}
+
+// Report of a build
+type Report struct {
+	Rendered  []string // paths of the pages and static assets rendered
+	Unchanged int      // pages and static assets left as they were
+	Removed   []string // paths of the pages removed as their posts, authors, tags or assets are gone
+	Skipped   []string // tags that can't be a directory, e.g. containing a slash
+	Files     int      // uploaded files copied
+}
+
+// state of the last build
+type state struct {
+	Version int               `json:"version"`
+	Pages   map[string]string `json:"pages"` // fingerprints of the pages by path
+}
+
+// page of the site
+type page struct {
+	path        string // URL path, pages ending in a slash are written as index.html
+	fingerprint string // hash of what the page shows
+}
+
+// Build renders the site into options.Out, the pages that changed since the last build there and
+// the uploaded files of posts that are new to it
+func Build(options Options) (Report, error) {
+	report := Report{Rendered: []string{}, Removed: []string{}, Skipped: []string{}}
+
+	pages, media, skipped, err := collect()
+
+	if err != nil {
+		return report, err
+	}
+
+	report.Skipped = skipped
+
+	if err = os.MkdirAll(options.Out, 0755); err != nil {
+		return report, err
+	}
+
+	previous := readState(options.Out)
+	current := state{Version: buildVersion, Pages: map[string]string{}}
+	handler := website.NewWebsiteHandler()
+
+	for _, page := range pages {
+		current.Pages[page.path] = page.fingerprint
+
+		if !options.Force && previous.Pages[page.path] == page.fingerprint && exists(filepath.Join(options.Out, filename(page.path))) {
+			report.Unchanged++
+			continue
+		}
+
+		content, err := render(handler, page.path)
+
+		if err != nil {
+			return report, err
+		}
+
+		if err = writeFile(filepath.Join(options.Out, filename(page.path)), bytes.NewReader(content)); err != nil {
+			return report, err
+		}
+
+		report.Rendered = append(report.Rendered, page.path)
+	}
+
+	for pagePath := range previous.Pages {
+		if _, ok := current.Pages[pagePath]; ok {
+			continue
+		}
+
+		if err = removePage(options.Out, pagePath); err != nil {
+			return report, err
+		}
+
+		report.Removed = append(report.Removed, pagePath)
+	}
+
+	sort.Strings(report.Removed)
+
+	report.Files, err = copyMedia(options.Out, media)
+
+	if err != nil {
+		return report, err
+	}
+
+	return report, writeState(options.Out, current)
+}
+
+// collect returns the pages and static assets of the site, the keys of the uploaded files the pages
+// show and the tags left out
+func collect() ([]page, []string, []string, error) {
+	theme, err := website.CurrentTheme()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	posts, err := models.GetAllPosts()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	authors, err := models.GetAllAuthors()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	postIds := make([]string, len(posts))
+
+	for index, post := range posts {
+		postIds[index] = post.Id
+	}
+
+	attachments, err := models.GetPostsAttachments(postIds)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// feeds link to the base URL, dates are formatted in a language and time zone
+	site := []string{fmt.Sprint(buildVersion), theme.Version, helpers.BaseURL(), website.Formats()}
+
+	var (
+		pages   []page
+		media   []string
+		skipped []string
+		all     []string
+	)
+
+	byAuthor := map[string][]string{}
+	byTag := map[string][]string{}
+
+	for _, post := range posts {
+		entry := postEntry(post)
+		all = append(all, entry)
+		byAuthor[post.AuthorInfo.AuthorId] = append(byAuthor[post.AuthorInfo.AuthorId], entry)
+
+		for _, tag := range post.Tags {
+			byTag[tag] = append(byTag[tag], entry)
+		}
+
+		parts := append([]string{entry}, post.Tags...)
+
+		for _, attachment := range attachments[post.Id] {
+			parts = append(parts, attachment.Id, attachment.Filename, attachment.Key, attachment.ThumbnailKey)
+			media = append(media, attachment.Key)
+
+			if attachment.ThumbnailKey != "" {
+				media = append(media, attachment.ThumbnailKey)
+			}
+		}
+
+		pages = append(pages, page{path: "/post/" + post.Id + "/", fingerprint: fingerprint(site, parts)})
+	}
+
+	pages = append(pages,
+		page{path: "/", fingerprint: fingerprint(site, all)},
+		page{path: "/feed.xml", fingerprint: fingerprint(site, all)},
+	)
+
+	for _, author := range authors {
+		parts := append([]string{author.Username, author.CreatedAt.UTC().Format(time.RFC3339Nano)}, byAuthor[author.AuthorId]...)
+
+		pages = append(pages,
+			page{path: "/author/" + author.AuthorId + "/", fingerprint: fingerprint(site, parts)},
+			page{path: "/author/" + author.AuthorId + "/feed.xml", fingerprint: fingerprint(site, parts)},
+		)
+	}
+
+	for tag, entries := range byTag {
+		if strings.ContainsAny(tag, `/\`) || tag == "." || tag == ".." {
+			skipped = append(skipped, tag)
+			continue
+		}
+
+		pages = append(pages,
+			page{path: "/tag/" + tag + "/", fingerprint: fingerprint(site, entries)},
+			page{path: "/tag/" + tag + "/feed.xml", fingerprint: fingerprint(site, entries)},
+		)
+	}
+
+	for name, hash := range theme.Assets() {
+		pages = append(pages, page{path: "/static/" + name, fingerprint: hash})
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].path < pages[j].path })
+	sort.Strings(skipped)
+
+	return pages, media, skipped, nil
+}
+
+// postEntry returns what lists of posts show of post, which changes whenever the post is updated
+func postEntry(post types.Post) string {
+	return strings.Join([]string{post.Id, post.UpdatedAt.UTC().Format(time.RFC3339Nano), post.AuthorInfo.AuthorId, post.AuthorInfo.Username}, " ")
+}
+
+// fingerprint returns a hash of the parts of the site and of a page
+func fingerprint(site, parts []string) string {
+	hash := sha256.New()
+
+	for _, part := range append(append([]string{}, site...), parts...) {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// filename returns the file of the page at pagePath, relative to the output directory
+func filename(pagePath string) string {
+	if strings.HasSuffix(pagePath, "/") {
+		pagePath += "index.html"
+	}
+
+	return filepath.FromSlash(strings.TrimPrefix(pagePath, "/"))
+}
+
+// pageRecorder keeps the response of a page rendered by the website's handler
+type pageRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *pageRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *pageRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.body.Write(data)
+}
+
+func (r *pageRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// render returns the page at pagePath as served by handler
+func render(handler http.Handler, pagePath string) ([]byte, error) {
+	req, err := http.NewRequest("GET", "/", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL.Path = pagePath
+	recorder := &pageRecorder{header: http.Header{}}
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.status != http.StatusOK {
+		return nil, fmt.Errorf("rendering %s: status %d: %s", pagePath, recorder.status, strings.TrimSpace(recorder.body.String()))
+	}
+
+	return recorder.body.Bytes(), nil
+}
+
+// writeFile writes the content read from r to name, replacing the file at once so that it's never
+// served half written
+func writeFile(name string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp's files are only readable by their owner
+	if err = os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+// removePage removes the file of the page at pagePath and its directory if it's left empty
+func removePage(out, pagePath string) error {
+	name := filepath.Join(out, filename(pagePath))
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// fails if other pages are left in it, e.g. the feed of a removed author page
+	os.Remove(filepath.Dir(name))
+
+	return nil
+}
+
+// copyMedia copies the uploaded files under keys from the blob store to <out>/media/, the ones
+// already there are skipped as keys are derived from the content
+func copyMedia(out string, keys []string) (int, error) {
+	copied := 0
+
+	for _, key := range keys {
+		name := filepath.Join(out, "media", filepath.FromSlash(key))
+
+		if !storage.ValidKey(key) || exists(name) {
+			continue
+		}
+
+		blob, _, err := storage.Default.Get(key)
+
+		if err == storage.ErrNotFound {
+			continue
+		}
+
+		if err != nil {
+			return copied, err
+		}
+
+		err = writeFile(name, blob)
+		blob.Close()
+
+		if err != nil {
+			return copied, err
+		}
+
+		copied++
+	}
+
+	return copied, nil
+}
+
+// readState returns the state of the last build in out, an empty state if there's none
+func readState(out string) state {
+	var previous state
+
+	content, err := os.ReadFile(filepath.Join(out, stateFile))
+
+	if err != nil || json.Unmarshal(content, &previous) != nil || previous.Version != buildVersion {
+		return state{Pages: map[string]string{}}
+	}
+
+	if previous.Pages == nil {
+		previous.Pages = map[string]string{}
+	}
+
+	return previous
+}
+
+// writeState saves the state of a build in out
+func writeState(out string, current state) error {
+	content, err := json.MarshalIndent(current, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(out, stateFile), bytes.NewReader(content))
+}
+
+// exists checks whether the file name exists
+func exists(name string) bool {
+	_, err := os.Stat(name)
+
+	return err == nil
+}
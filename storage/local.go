@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore stores blobs as files under Dir
+type LocalStore struct {
+	Dir string // root directory of the blobs
+}
+
+// LocalStore's Put writes the blob to a temporary file and renames it into place so that readers
+// never see partial files
+func (s *LocalStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(name), ".upload-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err = io.CopyN(file, r, size); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+// LocalStore's Get opens the file of the blob
+func (s *LocalStore) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	name, err := s.path(key)
+
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	file, err := os.Open(name)
+
+	if os.IsNotExist(err) {
+		return nil, BlobInfo{}, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	stat, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, BlobInfo{}, err
+	}
+
+	return file, BlobInfo{Key: key, ContentType: contentTypeOf(key), Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// LocalStore's Delete removes the file of the blob
+func (s *LocalStore) Delete(key string) error {
+	name, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// path returns the file name of key
+func (s *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store stores blobs in a bucket of an S3-compatible service (AWS S3, MinIO, etc.)
+//
+// Requests use path-style URLs (<endpoint>/<bucket>/<key>) signed with AWS Signature Version 4,
+// so a local MinIO container can stand in for S3 during development.
+type S3Store struct {
+	Endpoint  string       // base URL of the service, https://s3.<region>.amazonaws.com if empty
+	Region    string       // region of the bucket
+	Bucket    string       // name of the bucket
+	AccessKey string       // access key ID
+	SecretKey string       // secret access key
+	Client    *http.Client // client used for requests, http.DefaultClient if nil
+}
+
+// S3Store's Put uploads the blob with a single PUT request
+func (s *S3Store) Put(key string, r io.Reader, size int64, contentType string) error {
+	body, err := ioutil.ReadAll(io.LimitReader(r, size))
+
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request("PUT", key, body)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := s.do(req, body)
+
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+// S3Store's Get downloads the blob
+func (s *S3Store) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	req, err := s.request("GET", key, nil)
+
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	res, err := s.do(req, nil)
+
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+
+	info := BlobInfo{Key: key, ContentType: res.Header.Get("Content-Type"), Size: res.ContentLength}
+
+	if modTime, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+
+	if info.ContentType == "" {
+		info.ContentType = contentTypeOf(key)
+	}
+
+	return res.Body, info, nil
+}
+
+// S3Store's Delete removes the blob
+func (s *S3Store) Delete(key string) error {
+	req, err := s.request("DELETE", key, nil)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req, nil)
+
+	if err == ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+// request creates an unsigned request for key
+func (s *S3Store) request(method, key string, body []byte) (*http.Request, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	endpoint := s.Endpoint
+
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+
+	return http.NewRequest(method, strings.TrimRight(endpoint, "/")+"/"+url.PathEscape(s.Bucket)+"/"+key, bytes.NewReader(body))
+}
+
+// do signs and sends req, responses other than 2xx are turned into errors
+func (s *S3Store) do(req *http.Request, body []byte) (*http.Response, error) {
+	s.sign(req, body, time.Now().UTC())
+
+	client := s.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, res.Status, message)
+	}
+
+	return res, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// sha256Hex returns the hex encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
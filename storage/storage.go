@@ -0,0 +1,102 @@
+// Package storage stores uploaded files (blobs) on the local filesystem or an S3-compatible service
+package storage
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that could escape the store (absolute, "..", etc.)
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Object containing a stored blob's properties
+type BlobInfo struct {
+	Key         string    // key the blob is stored under
+	ContentType string    // MIME type of the blob
+	Size        int64     // size in bytes
+	ModTime     time.Time // time the blob was stored
+}
+
+// BlobStore is implemented by anything that can store and retrieve blobs by key
+//
+// Keys are slash separated relative paths ending in an extension, e.g. thumbs/5e2b...a1.jpg
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob
+	Put(key string, r io.Reader, size int64, contentType string) error
+
+	// Get returns the blob stored under key, the caller must close it
+	Get(key string) (io.ReadCloser, BlobInfo, error)
+
+	// Delete removes the blob stored under key, missing blobs aren't an error
+	Delete(key string) error
+}
+
+// ValidKey checks that key is a clean relative path made of safe characters
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key {
+		return false
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+
+	return strings.Trim(key, "abcdefghijklmnopqrstuvwxyz0123456789-_./") == ""
+}
+
+// contentTypeOf returns the MIME type of a key based on its extension
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+
+	return "application/octet-stream"
+}
+
+// store used by the app
+var Default BlobStore
+
+// InitBlobStore sets up Default from GOBLOG_BLOB_STORE
+//
+// "local" (default): files under GOBLOG_MEDIA_DIR (default "media")
+//
+// "s3": bucket GOBLOG_S3_BUCKET at GOBLOG_S3_ENDPOINT in GOBLOG_S3_REGION, authenticated with
+// GOBLOG_S3_ACCESS_KEY and GOBLOG_S3_SECRET_KEY
+func InitBlobStore() {
+	switch os.Getenv("GOBLOG_BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("GOBLOG_MEDIA_DIR")
+
+		if dir == "" {
+			dir = "media"
+		}
+
+		Default = &LocalStore{Dir: dir}
+	case "s3":
+		region := os.Getenv("GOBLOG_S3_REGION")
+
+		if region == "" {
+			region = "us-east-1"
+		}
+
+		Default = &S3Store{
+			Endpoint:  os.Getenv("GOBLOG_S3_ENDPOINT"),
+			Region:    region,
+			Bucket:    os.Getenv("GOBLOG_S3_BUCKET"),
+			AccessKey: os.Getenv("GOBLOG_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("GOBLOG_S3_SECRET_KEY"),
+		}
+	default:
+		panic("unknown GOBLOG_BLOB_STORE " + os.Getenv("GOBLOG_BLOB_STORE"))
+	}
+}
@@ -0,0 +1,75 @@
+// new, updated and deleted posts show up on the home page without reloading
+(function () {
+    if (!window.EventSource || !window.fetch) return;
+
+    var posts = document.getElementById("posts");
+    var empty = document.getElementById("empty");
+
+    if (!posts) return;
+
+    var stream = new EventSource("/api/stream");
+
+    // events are applied one after the other, so that a slow summary doesn't overtake a later event
+    var queue = Promise.resolve();
+
+    function enqueue(apply) {
+        queue = queue.then(apply).catch(function () {});
+    }
+
+    // the post_summary partial, rendered by the server, null if the post is gone
+    function summary(id) {
+        return fetch("/post/" + encodeURIComponent(id) + "/summary").then(function (res) {
+            if (!res.ok) return null;
+
+            return res.text().then(function (html) {
+                var template = document.createElement("template");
+                template.innerHTML = html.trim();
+
+                return template.content.firstElementChild;
+            });
+        });
+    }
+
+    function find(id) {
+        return Array.prototype.find.call(posts.children, function (item) { return item.dataset.post === id; });
+    }
+
+    // the home page lists the latest updated posts first
+    function show(id) {
+        return summary(id).then(function (item) {
+            var old = find(id);
+
+            if (old) old.remove();
+
+            if (item) posts.prepend(item);
+
+            empty.hidden = posts.children.length > 0;
+        });
+    }
+
+    stream.addEventListener("post.created", function (event) {
+        var id = JSON.parse(event.data).id;
+
+        enqueue(function () {
+            if (!find(id)) return show(id);
+        });
+    });
+
+    stream.addEventListener("post.updated", function (event) {
+        var id = JSON.parse(event.data).id;
+
+        enqueue(function () { return show(id); });
+    });
+
+    stream.addEventListener("post.deleted", function (event) {
+        var id = JSON.parse(event.data).id;
+
+        enqueue(function () {
+            var old = find(id);
+
+            if (old) old.remove();
+
+            empty.hidden = posts.children.length > 0;
+        });
+    });
+})();
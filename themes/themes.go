@@ -0,0 +1,14 @@
+// Package themes embeds the default theme of the website, see website.Theme for the layout of a
+// theme
+package themes
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed default
+var files embed.FS
+
+// Default is the default theme, every file missing from the active theme is taken from it
+var Default, _ = fs.Sub(files, "default")
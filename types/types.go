@@ -2,6 +2,8 @@
 package types
 
 import (
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -22,6 +24,25 @@ type Post struct {
 	CreatedAt  time.Time `json:"created_at"` // post's creation date
 	UpdatedAt  time.Time `json:"updated_at"` // post's modification date
 	AuthorInfo Author    `json:"author"`     // post's author
+	Tags       []string  `json:"tags"`       // post's tags
+
+	Attachments []Attachment `json:"attachments,omitempty"` // post's attachments
+	Revision    int          `json:"-"`                     // incremented on every update, sent as ETag
+}
+
+// Object containing an uploaded file's properties
+type Attachment struct {
+	Id           string    `json:"id"`                      // attachment's ID
+	PostId       string    `json:"post_id,omitempty"`       // ID of the post it's linked to
+	Filename     string    `json:"filename"`                // name of the uploaded file
+	ContentType  string    `json:"content_type"`            // sniffed MIME type
+	Size         int64     `json:"size"`                    // size in bytes
+	URL          string    `json:"url"`                     // path the file is served at
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"` // path the thumbnail is served at (images only)
+	CreatedAt    time.Time `json:"created_at"`              // attachment's creation date
+
+	Key          string `json:"-"` // key of the file in the blob store
+	ThumbnailKey string `json:"-"` // key of the thumbnail in the blob store
 }
 
 // Object containing author's properties including all his/her posts
@@ -30,18 +51,455 @@ type AuthorPosts struct {
 	List       []Post `json:"posts"`  // author's posts
 }
 
+// Object containing a tag and the posts having it
+type TagPosts struct {
+	Tag  string // tag
+	List []Post // posts having the tag, newest first
+}
+
+// Collection response object of API v2
+type Collection struct {
+	Items interface{} `json:"items"` // the resources
+	Count int         `json:"count"` // number of resources
+}
+
+// Object containing author's properties and posts, API v2
+type AuthorDetail struct {
+	Author
+	Posts []PostSummary `json:"posts"` // author's posts, newest first
+}
+
+// Object containing post's properties without its author, API v2
+type PostSummary struct {
+	Id        string    `json:"id"`         // post's ID
+	Title     string    `json:"title"`      // post's title
+	Body      string    `json:"body"`       // post's body
+	Tags      []string  `json:"tags"`       // post's tags
+	CreatedAt time.Time `json:"created_at"` // post's creation date
+	UpdatedAt time.Time `json:"updated_at"` // post's modification date
+}
+
+// Position of a post in the newest first order of post pages, the zero value is the start
+type PostCursor struct {
+	CreatedAt time.Time // creation date of the post
+	Id        string    // ID of the post, orders posts created at the same time
+}
+
+// Session token response object, API v2
+type Token struct {
+	Token     string    `json:"token"`      // JSON web token to send in the "token" header
+	ExpiresAt time.Time `json:"expires_at"` // expiry of the token
+}
+
+// Object containing an API key's properties, the key itself is only returned on creation
+type APIKey struct {
+	Id         string     `json:"id"`                     // key's ID
+	Name       string     `json:"name"`                   // name given by the author
+	Prefix     string     `json:"prefix"`                 // first characters of the key to recognise it
+	Scopes     []string   `json:"scopes"`                 // what the key may be used for
+	CreatedAt  time.Time  `json:"created_at"`             // key's creation date
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // last time the key was accepted (to the minute)
+	Key        string     `json:"key,omitempty"`          // the key, only set on creation
+}
+
+// Object containing a webhook's properties, the secret is only returned on creation
+type Webhook struct {
+	Id        string    `json:"id"`               // webhook's ID
+	URL       string    `json:"url"`              // endpoint the events are posted to
+	Events    []string  `json:"events"`           // event types the webhook is subscribed to
+	CreatedAt time.Time `json:"created_at"`       // webhook's creation date
+	Secret    string    `json:"secret,omitempty"` // key of the payload signatures, only set on creation
+}
+
+// Object containing an attempted or pending delivery of an event to a webhook
+type WebhookDelivery struct {
+	Id             string     `json:"id"`                        // delivery's ID
+	EventId        string     `json:"event_id"`                  // ID of the delivered event, the same for redeliveries
+	Event          string     `json:"event"`                     // type of the delivered event
+	Status         string     `json:"status"`                    // pending, succeeded or failed
+	Attempts       int        `json:"attempts"`                  // number of attempts made
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt
+	Error          string     `json:"error,omitempty"`           // why the last attempt failed
+	CreatedAt      time.Time  `json:"created_at"`                // delivery's creation date
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // when a pending delivery is attempted next
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`    // when the delivery succeeded
+}
+
+// Object identifying a deleted post
+type PostRef struct {
+	Id       string `json:"id"`        // post's ID
+	AuthorId string `json:"author_id"` // ID of the post's author
+}
+
 // Default JSON response object
 type DefaultResponse struct {
 	Status  string `json:"status"`  // status field
 	Message string `json:"message"` // message field
 }
 
+// Error response object (RFC 7807 problem details), sent as application/problem+json
+type Problem struct {
+	Type   string       `json:"type"`             // URI identifying the kind of problem
+	Title  string       `json:"title"`            // short summary of the kind of problem
+	Status int          `json:"status"`           // HTTP status code
+	Code   string       `json:"code"`             // stable machine-readable error code
+	Detail string       `json:"detail,omitempty"` // explanation specific to this occurrence
+	Errors []FieldError `json:"errors,omitempty"` // per-field validation errors
+}
+
+// Validation error of a single request field
+type FieldError struct {
+	Field   string `json:"field"`   // name of the field
+	Code    string `json:"code"`    // stable machine-readable error code
+	Message string `json:"message"` // explanation for humans
+}
+
 // Not default JSON response object
 type ValidResponse struct {
 	Status  string      `json:"status"`  // status field
 	Content interface{} `json:"content"` // content field
 }
 
+// Object containing a newly enrolled TOTP secret
+type TOTPEnrolment struct {
+	Secret string `json:"secret"` // base32 secret for manual entry
+	URI    string `json:"uri"`    // otpauth:// provisioning URI to be shown as a QR code
+}
+
+// Body of a request creating an author
+type CreateAuthorRequest struct {
+	Username string `json:"username" form:"username"` // author's username
+	Password string `json:"password" form:"password"` // author's password
+	Email    string `json:"email" form:"email"`       // author's email (optional)
+}
+
+// CreateAuthorRequest's Validate returns the errors of the fields that can be checked without the
+// database
+func (r CreateAuthorRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	switch {
+	case r.Username == "":
+		errors = append(errors, FieldError{Field: "username", Code: "required", Message: "Username is required"})
+	case len(r.Username) > 64:
+		errors = append(errors, FieldError{Field: "username", Code: "too_long", Message: "Username must be at most 64 characters"})
+	case strings.ContainsAny(r.Username, " \t\r\n/"):
+		errors = append(errors, FieldError{Field: "username", Code: "invalid", Message: "Username must not contain spaces or slashes"})
+	}
+
+	errors = append(errors, validatePassword("password", r.Password)...)
+
+	return errors
+}
+
+// Body of a login request
+type LoginRequest struct {
+	Username string `json:"username" form:"username"` // author's username
+	Password string `json:"password" form:"password"` // author's password
+	OTP      string `json:"otp" form:"otp"`           // TOTP or recovery code, if two-factor authentication is enabled
+}
+
+// Body of a request creating or updating a post
+type PostRequest struct {
+	Title string   `json:"title" form:"title"` // post's title
+	Body  string   `json:"body" form:"body"`   // post's body
+	Tags  []string `json:"tags" form:"tags"`   // post's tags, left unchanged on update if omitted
+}
+
+// LoginRequest's Validate checks that credentials were sent
+func (r LoginRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	if r.Username == "" {
+		errors = append(errors, FieldError{Field: "username", Code: "required", Message: "Username is required"})
+	}
+
+	if r.Password == "" {
+		errors = append(errors, FieldError{Field: "password", Code: "required", Message: "Password is required"})
+	}
+
+	return errors
+}
+
+// PostRequest's Validate checks the title and body
+func (r PostRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	switch {
+	case strings.TrimSpace(r.Title) == "":
+		errors = append(errors, FieldError{Field: "title", Code: "required", Message: "Title is required"})
+	case len(r.Title) > 255:
+		errors = append(errors, FieldError{Field: "title", Code: "too_long", Message: "Title must be at most 255 characters"})
+	}
+
+	if strings.TrimSpace(r.Body) == "" {
+		errors = append(errors, FieldError{Field: "body", Code: "required", Message: "Body is required"})
+	}
+
+	return errors
+}
+
+// Request body of a GraphQL query or mutation
+type GraphQLRequest struct {
+	Query         string                 `json:"query" form:"query"`                 // GraphQL document
+	OperationName string                 `json:"operationName" form:"operationName"` // operation to execute if the document has several
+	Variables     map[string]interface{} `json:"variables"`                          // values of the operation's variables
+	Extensions    map[string]interface{} `json:"extensions"`                         // ignored, sent by some clients
+}
+
+// layout of export archives, the posts are also written as Markdown files with front matter
+const (
+	ExportManifest = "manifest.json" // Export of the archive's content
+	ExportMediaDir = "media/"        // files of attachments, named after the keys of their blobs
+	ExportPostsDir = "posts/"        // posts as Markdown files, named after their IDs
+)
+
+// Site content in the JSON format of imports and exports
+type Export struct {
+	Version     int                `json:"version"`               // format version, 1
+	Authors     []ExportAuthor     `json:"authors"`               // authors of the posts
+	Tags        []string           `json:"tags,omitempty"`        // tags of the posts
+	Posts       []ExportPost       `json:"posts"`                 // posts, newest first
+	Attachments []ExportAttachment `json:"attachments,omitempty"` // uploaded files, their files are next to the manifest
+}
+
+// Author in the JSON format of imports and exports
+type ExportAuthor struct {
+	Id        string    `json:"id"`              // author's ID
+	Username  string    `json:"username"`        // author's username
+	Email     string    `json:"email,omitempty"` // author's email
+	CreatedAt time.Time `json:"created_at"`      // author's creation date
+}
+
+// Post in the JSON format of imports and exports
+type ExportPost struct {
+	Id        string    `json:"id"`             // post's ID
+	Slug      string    `json:"slug,omitempty"` // post's slug in the blog it was imported from
+	Title     string    `json:"title"`          // post's title
+	Body      string    `json:"body"`           // post's body
+	Tags      []string  `json:"tags"`           // post's tags
+	Author    string    `json:"author"`         // username of the post's author
+	CreatedAt time.Time `json:"created_at"`     // post's creation date
+	UpdatedAt time.Time `json:"updated_at"`     // post's modification date
+}
+
+// Uploaded file in the JSON format of imports and exports
+type ExportAttachment struct {
+	Id          string    `json:"id"`                  // attachment's ID
+	PostId      string    `json:"post_id,omitempty"`   // ID of the post it's linked to
+	Author      string    `json:"author"`              // username of the author who uploaded it
+	Filename    string    `json:"filename"`            // name of the uploaded file
+	ContentType string    `json:"content_type"`        // sniffed MIME type
+	Size        int64     `json:"size"`                // size in bytes
+	File        string    `json:"file"`                // path of the file in the archive, media/<key>
+	Thumbnail   string    `json:"thumbnail,omitempty"` // path of the thumbnail in the archive (images only)
+	CreatedAt   time.Time `json:"created_at"`          // attachment's creation date
+}
+
+// outcomes of importing a post
+const (
+	ImportCreated = "created" // the post has been created (or would be in a dry run)
+	ImportExists  = "exists"  // the post has been imported before
+	ImportSkipped = "skipped" // the post isn't imported, e.g. a draft
+	ImportFailed  = "failed"  // the post can't be imported
+)
+
+// Outcome of importing a post
+type ImportResult struct {
+	ExternalId string `json:"external_id"`       // post's ID in the source
+	Title      string `json:"title"`             // post's title
+	Author     string `json:"author,omitempty"`  // username the post is imported as
+	Status     string `json:"status"`            // created, exists, skipped or failed
+	PostId     string `json:"post_id,omitempty"` // ID of the created or existing post
+	Reason     string `json:"reason,omitempty"`  // why the post is skipped or failed
+}
+
+// Report of an import
+type ImportReport struct {
+	Source         string         `json:"source"`          // name of the imported blog, e.g. "wxr:https://example.com"
+	DryRun         bool           `json:"dry_run"`         // whether nothing has been written
+	Created        int            `json:"created"`         // number of posts created
+	Existing       int            `json:"existing"`        // number of posts imported before
+	Skipped        int            `json:"skipped"`         // number of posts skipped
+	Failed         int            `json:"failed"`          // number of posts that failed
+	AuthorsCreated []string       `json:"authors_created"` // usernames of the authors created
+	Attachments    int            `json:"attachments"`     // number of attachments created
+	Results        []ImportResult `json:"results"`         // outcome of every post
+}
+
+// Form fields of an import request, the export is uploaded as the multipart field "file"
+type ImportRequest struct {
+	Format        string   `json:"format" form:"format"`                 // wxr, markdown or json, detected if empty
+	Source        string   `json:"source" form:"source"`                 // name of the imported blog, telling apart posts with the same IDs
+	DryRun        bool     `json:"dry_run" form:"dry_run"`               // report what would be imported without writing
+	CreateAuthors bool     `json:"create_authors" form:"create_authors"` // create the authors that don't exist
+	DefaultAuthor string   `json:"default_author" form:"default_author"` // username of the author of posts whose author doesn't exist
+	AuthorMap     []string `json:"map_authors" form:"map_authors"`       // "source=username" pairs mapping authors of the source
+}
+
+// scopes of API keys
+const (
+	ScopeRead       = "read"        // GET requests
+	ScopeWritePosts = "write:posts" // creating, modifying and deleting posts and uploading files
+	ScopeAdmin      = "admin"       // everything, including the account and its API keys
+)
+
+// Request body of logging in or linking an account with an authorization code of the identity
+// provider, obtained by the client with PKCE
+type OIDCRequest struct {
+	Code         string `json:"code" form:"code"`                   // authorization code
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"` // PKCE verifier of the code challenge sent
+	RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`   // redirect URI the code was issued for
+	Nonce        string `json:"nonce" form:"nonce"`                 // nonce sent in the authorization request, if any
+}
+
+// OIDCRequest's Validate checks that the code, verifier and redirect URI were sent
+func (r OIDCRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	if r.Code == "" {
+		errors = append(errors, FieldError{Field: "code", Code: "required", Message: "Code is required"})
+	}
+
+	if r.CodeVerifier == "" {
+		errors = append(errors, FieldError{Field: "code_verifier", Code: "required", Message: "Code verifier is required"})
+	}
+
+	if r.RedirectURI == "" {
+		errors = append(errors, FieldError{Field: "redirect_uri", Code: "required", Message: "Redirect URI is required"})
+	}
+
+	return errors
+}
+
+// types of the events published on changes of posts and authors
+const (
+	EventPostCreated   = "post.created"
+	EventPostUpdated   = "post.updated"
+	EventPostDeleted   = "post.deleted"
+	EventAuthorCreated = "author.created"
+)
+
+// every event type webhooks can subscribe to
+var EventTypes = []string{EventPostCreated, EventPostUpdated, EventPostDeleted, EventAuthorCreated}
+
+// Request body of creating a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" form:"url"`       // http(s) endpoint to post the events to
+	Events []string `json:"events" form:"events"` // event types to subscribe to
+}
+
+// CreateWebhookRequest's Validate returns the invalid fields of the request
+func (r CreateWebhookRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	if u, err := url.Parse(r.URL); r.URL == "" {
+		errors = append(errors, FieldError{Field: "url", Code: "required", Message: "URL is required"})
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errors = append(errors, FieldError{Field: "url", Code: "invalid", Message: "URL must be an absolute http or https URL"})
+	} else if len(r.URL) > 2048 {
+		errors = append(errors, FieldError{Field: "url", Code: "too_long", Message: "URL must be at most 2048 characters"})
+	}
+
+	if len(r.Events) == 0 {
+		errors = append(errors, FieldError{Field: "events", Code: "required", Message: "At least one event is required"})
+	}
+
+	for _, event := range r.Events {
+		known := false
+
+		for _, eventType := range EventTypes {
+			known = known || event == eventType
+		}
+
+		if !known {
+			errors = append(errors, FieldError{Field: "events", Code: "invalid", Message: "Unknown event " + event})
+		}
+	}
+
+	return errors
+}
+
+// Request body of creating an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" form:"name"`     // name to recognise the key by
+	Scopes []string `json:"scopes" form:"scopes"` // scopes of the key
+}
+
+// CreateAPIKeyRequest's Validate returns the invalid fields of the request
+func (r CreateAPIKeyRequest) Validate() []FieldError {
+	var errors []FieldError
+
+	if strings.TrimSpace(r.Name) == "" {
+		errors = append(errors, FieldError{Field: "name", Code: "required", Message: "Name is required"})
+	} else if len(r.Name) > 64 {
+		errors = append(errors, FieldError{Field: "name", Code: "too_long", Message: "Name must be at most 64 characters"})
+	}
+
+	if len(r.Scopes) == 0 {
+		errors = append(errors, FieldError{Field: "scopes", Code: "required", Message: "At least one scope is required"})
+	}
+
+	for _, scope := range r.Scopes {
+		if scope != ScopeRead && scope != ScopeWritePosts && scope != ScopeAdmin {
+			errors = append(errors, FieldError{Field: "scopes", Code: "invalid", Message: "Unknown scope " + scope})
+		}
+	}
+
+	return errors
+}
+
+// Body of a request changing an author's email
+type EmailRequest struct {
+	Email string `json:"email" form:"email"` // new email address
+}
+
+// Body of a request consuming an emailed token
+type TokenRequest struct {
+	Token    string `json:"token" form:"token"`       // token from the emailed link
+	Password string `json:"password" form:"password"` // new password (password reset only)
+}
+
+// TokenRequest's Validate checks the token and, if resetPassword, the new password
+func (r TokenRequest) Validate(resetPassword bool) []FieldError {
+	var errors []FieldError
+
+	if r.Token == "" {
+		errors = append(errors, FieldError{Field: "token", Code: "required", Message: "Token is required"})
+	}
+
+	if resetPassword {
+		errors = append(errors, validatePassword("password", r.Password)...)
+	}
+
+	return errors
+}
+
+// validatePassword checks the length of a new password
+func validatePassword(field, password string) []FieldError {
+	switch {
+	case password == "":
+		return []FieldError{{Field: field, Code: "required", Message: "Password is required"}}
+	case len(password) < 8:
+		return []FieldError{{Field: field, Code: "too_short", Message: "Password must be at least 8 characters"}}
+	case len(password) > 1024:
+		return []FieldError{{Field: field, Code: "too_long", Message: "Password must be at most 1024 characters"}}
+	}
+
+	return nil
+}
+
+// Body of a request starting the password reset flow
+type ForgotRequest struct {
+	Username string `json:"username" form:"username"` // author's username
+	Email    string `json:"email" form:"email"`       // or author's email
+}
+
+// Body of a request confirming or disabling two-factor authentication
+type OTPRequest struct {
+	OTP string `json:"otp" form:"otp"` // TOTP or recovery code
+}
+
 // Custom claims for the JSON web token
 type CustomClaims struct {
 	Id                 string `json:"id"` // author's ID
@@ -0,0 +1,225 @@
+// Package webhooks posts events of posts and authors to the endpoints registered by authors,
+// signed with the webhook's secret and retried with exponential backoff
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/samkit-jain/go-blog/events"
+	"github.com/samkit-jain/go-blog/models"
+)
+
+// Dispatcher queues a delivery for every webhook subscribed to a published event and sends the
+// due deliveries
+//
+// Deliveries are stored in the database, so they survive restarts and can be sent by any instance.
+// Published events are queued in memory until they are stored, away from the request that published
+// them, events published while the queue is full or not yet stored on exit are lost.
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int               // attempts before a delivery is marked failed
+	Interval    time.Duration     // time between polls for due deliveries
+	BatchSize   int               // deliveries claimed per poll
+	Queue       chan events.Event // published events whose deliveries are still to be stored
+}
+
+// time a claimed delivery is held back from other instances, longer than a request can take
+const claimLease = time.Minute
+
+// NewDispatcher returns a dispatcher with the defaults, whose client refuses to connect to private
+// addresses unless allowPrivate is set
+func NewDispatcher(allowPrivate bool) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+
+	return &Dispatcher{
+		Client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: http.ProxyFromEnvironment},
+			// redirects count as failures, the endpoint has to be registered with its final URL
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		MaxAttempts: 8,
+		Interval:    time.Second,
+		BatchSize:   20,
+		Queue:       make(chan events.Event, 1024),
+	}
+}
+
+// InitDispatcher subscribes a dispatcher to events.Default and starts sending deliveries unless
+// GOBLOG_WEBHOOKS is "off", GOBLOG_WEBHOOKS_ALLOW_PRIVATE=true allows endpoints on private addresses
+// (e.g. for local development)
+func InitDispatcher() {
+	if os.Getenv("GOBLOG_WEBHOOKS") == "off" {
+		return
+	}
+
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("GOBLOG_WEBHOOKS_ALLOW_PRIVATE"))
+	dispatcher := NewDispatcher(allowPrivate)
+
+	events.Default.Subscribe(dispatcher.enqueue)
+
+	go dispatcher.StoreDeliveries()
+	go dispatcher.Run()
+}
+
+// Dispatcher's enqueue hands an event to StoreDeliveries without blocking the publisher, failures
+// are logged as publishing can't fail
+func (d *Dispatcher) enqueue(event events.Event) {
+	select {
+	case d.Queue <- event:
+	default:
+		log.Printf("webhooks: dropping %s %s, the queue is full", event.Type, event.Id)
+	}
+}
+
+// Dispatcher's StoreDeliveries stores the deliveries of the queued events forever
+func (d *Dispatcher) StoreDeliveries() {
+	for event := range d.Queue {
+		if err := models.EnqueueWebhookDeliveries(event); err != nil {
+			log.Printf("webhooks: queueing %s %s: %v", event.Type, event.Id, err)
+		}
+	}
+}
+
+// Dispatcher's Run sends due deliveries forever
+func (d *Dispatcher) Run() {
+	for {
+		jobs, err := models.ClaimWebhookJobs(d.BatchSize, claimLease)
+
+		if err != nil {
+			log.Printf("webhooks: claiming deliveries: %v", err)
+		}
+
+		for _, job := range jobs {
+			d.deliver(job)
+		}
+
+		// a full batch suggests a backlog, polling again right away
+		if len(jobs) < d.BatchSize {
+			time.Sleep(d.Interval)
+		}
+	}
+}
+
+// Dispatcher's deliver makes one attempt of a delivery and records its outcome
+func (d *Dispatcher) deliver(job models.WebhookJob) {
+	responseStatus, err := d.send(job)
+
+	status, message, nextAttempt := models.DeliverySucceeded, "", time.Now()
+
+	if err != nil {
+		message = err.Error()
+
+		if job.Attempts+1 >= d.MaxAttempts {
+			status = models.DeliveryFailed
+		} else {
+			status = models.DeliveryPending
+			nextAttempt = time.Now().Add(Backoff(job.Attempts + 1))
+		}
+	}
+
+	if err := models.FinishWebhookJob(job.DeliveryId, status, responseStatus, message, nextAttempt); err != nil {
+		log.Printf("webhooks: recording delivery %s: %v", job.DeliveryId, err)
+	}
+}
+
+// Dispatcher's send posts the payload of a delivery and returns the response status, non-2xx
+// responses are errors
+func (d *Dispatcher) send(job models.WebhookJob) (int, error) {
+	req, err := http.NewRequest("POST", job.URL, bytes.NewReader(job.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goblog-webhooks")
+	req.Header.Set("X-Goblog-Event", job.Event)
+	req.Header.Set("X-Goblog-Event-Id", job.EventId)
+	req.Header.Set("X-Goblog-Delivery", job.DeliveryId)
+	req.Header.Set("X-Goblog-Signature", "t="+strconv.FormatInt(timestamp, 10)+",v1="+Sign(job.Secret, timestamp, job.Payload))
+
+	res, err := d.Client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// reading a bit of the body lets the connection be reused
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, errors.New("endpoint responded " + res.Status)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the signature of a payload sent at timestamp: the hex encoded HMAC-SHA256 of
+// "<timestamp>.<payload>" keyed with the webhook's secret
+//
+// Receivers should recompute it, compare in constant time and reject old timestamps.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before attempt (1 for the first retry): 30 seconds doubling every
+// attempt, at most 6 hours
+func Backoff(attempt int) time.Duration {
+	delay := 30 * time.Second
+
+	for i := 1; i < attempt && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+
+	return delay
+}
+
+// errPrivateAddress is returned when a webhook endpoint resolves to a non-public address
+var errPrivateAddress = errors.New("webhook endpoints on private addresses are not allowed")
+
+// publicOnly refuses connections to loopback, private, link-local and unspecified addresses, so
+// that webhooks can't be used to reach services inside the network
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samkit-jain/go-blog/dbtest"
+	"github.com/samkit-jain/go-blog/events"
+)
+
+func TestPublishDoesNotWaitForTheDatabase(t *testing.T) {
+	db := dbtest.Open(t)
+	db.On("SELECT webhook_id FROM webhooks").Rows(dbtest.Row("01HV0000000000000000000WHK"))
+	db.On("INSERT INTO webhook_deliveries")
+
+	dispatcher := NewDispatcher(false)
+	dispatcher.Queue = make(chan events.Event, 1)
+
+	bus := new(events.Bus)
+	bus.Subscribe(dispatcher.enqueue)
+
+	// nothing drains the queue yet, the second event is dropped instead of blocking
+	done := make(chan bool)
+
+	go func() {
+		bus.Publish("post.created", map[string]string{"id": "1"})
+		bus.Publish("post.created", map[string]string{"id": "2"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a full queue")
+	}
+
+	if queries := db.Queries(); len(queries) > 0 {
+		t.Fatalf("Publish ran %d queries", len(queries))
+	}
+
+	close(dispatcher.Queue)
+	dispatcher.StoreDeliveries()
+
+	deliveries := db.Ran("INSERT INTO webhook_deliveries")
+
+	if len(deliveries) != 1 || deliveries[0].Args[1] != "01HV0000000000000000000WHK" || deliveries[0].Args[3] != "post.created" {
+		t.Errorf("stored deliveries %v, want one of the first event", deliveries)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		8:  64 * time.Minute,
+		10: 256 * time.Minute,
+		11: 6 * time.Hour,
+		50: 6 * time.Hour,
+	}
+
+	for attempt, want := range tests {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) is %v, want %v", attempt, got, want)
+		}
+	}
+}
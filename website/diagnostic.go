@@ -0,0 +1,79 @@
+package website
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// line of a template shown by the diagnostic page
+type sourceLine struct {
+	Number int
+	Text   string
+	Error  bool // the line of the error
+}
+
+// error shown by the diagnostic page
+type diagnostic struct {
+	Theme   string
+	Page    string
+	Message string
+	File    string
+	Lines   []sourceLine // around the error, if its line is known
+}
+
+// number of lines shown before and after the line of an error
+const diagnosticContext = 5
+
+// page shown in development mode when a template doesn't parse or fails, it doesn't depend on the
+// theme as that's what's broken
+var diagnosticPage = template.Must(template.New("diagnostic").Parse(`<!DOCTYPE html>
+<html lang="en">
+    <head>
+        <meta charset="UTF-8">
+        <title>Template error</title>
+        <style>
+            body { font: 15px/1.5 sans-serif; margin: 2rem; color: #1f2328; }
+            pre { background: #f6f8fa; padding: 1rem; overflow-x: auto; }
+            .error { background: #ffebe9; display: block; }
+            .number { color: #656d76; user-select: none; }
+        </style>
+    </head>
+    <body>
+        <h1>Template error</h1>
+        <p>Theme <b>{{ .Theme }}</b>{{ if .Page }}, page <b>{{ .Page }}</b>{{ end }}{{ if .File }}, file <b>{{ .File }}</b>{{ end }}</p>
+        <pre>{{ .Message }}</pre>
+        {{ if .Lines }}
+            <pre>{{ range .Lines }}<span{{ if .Error }} class="error"{{ end }}><span class="number">{{ printf "%4d" .Number }}  </span>{{ .Text }}
+</span>{{ end }}</pre>
+        {{ end }}
+        <p>Reload the page once the theme is fixed, it's parsed again as soon as a file is saved.</p>
+    </body>
+</html>
+`))
+
+// renderDiagnostic responds with the diagnostic page of err, the error of rendering page with the
+// theme named theme
+func renderDiagnostic(res http.ResponseWriter, theme, page string, err error) {
+	content := diagnostic{Theme: theme, Page: page, Message: err.Error()}
+
+	var templateError *TemplateError
+
+	if errors.As(err, &templateError) {
+		content.File = templateError.File
+		lines := strings.Split(templateError.Source, "\n")
+
+		for number := templateError.Line - diagnosticContext; templateError.Line > 0 && number <= templateError.Line+diagnosticContext; number++ {
+			if number >= 1 && number <= len(lines) {
+				content.Lines = append(content.Lines, sourceLine{Number: number, Text: lines[number-1], Error: number == templateError.Line})
+			}
+		}
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(http.StatusInternalServerError)
+
+	diagnosticPage.Execute(res, content)
+}
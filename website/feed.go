@@ -0,0 +1,143 @@
+package website
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/types"
+)
+
+// number of posts in a feed, the latest updated
+const feedLength = 20
+
+// Atom feed (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type FeedHandler struct {
+}
+
+// FeedHandler's ServeHTTP serves the feed of the latest posts of the whole site
+//
+// GET	<base>/feed.xml
+func (h *FeedHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	posts, err := models.GetAllPosts()
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeFeed(res, "Go-Blog", "/", posts)
+}
+
+// writeFeed responds with an Atom feed titled title of the latest updated of posts, the feed of the
+// page at path (e.g. /author/:id/) is served at path + "feed.xml"
+//
+// Links are absolute, to GOBLOG_BASE_URL.
+func writeFeed(res http.ResponseWriter, title, path string, posts []types.Post) {
+	base := helpers.BaseURL()
+
+	// posts may be cached, sorting a copy
+	latest := append([]types.Post(nil), posts...)
+
+	sort.SliceStable(latest, func(i, j int) bool {
+		return latest[i].UpdatedAt.After(latest[j].UpdatedAt)
+	})
+
+	if len(latest) > feedLength {
+		latest = latest[:feedLength]
+	}
+
+	// fixed for feeds without posts, so that rendering twice gives the same feed
+	updated := time.Unix(0, 0)
+
+	if len(latest) > 0 {
+		updated = latest[0].UpdatedAt
+	}
+
+	feed := atomFeed{
+		Id:      base + path,
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + path + "feed.xml"},
+			{Rel: "alternate", Type: "text/html", Href: base + path},
+		},
+	}
+
+	for _, post := range latest {
+		entry := atomEntry{
+			Id:        base + postURL(post.Id),
+			Title:     post.Title,
+			Published: post.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   post.UpdatedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: base + postURL(post.Id)},
+			Author:    atomAuthor{Name: post.AuthorInfo.Username, URI: base + authorURL(post.AuthorInfo.AuthorId)},
+			Content:   atomContent{Type: "text", Text: post.Body},
+		}
+
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	content, err := xml.MarshalIndent(feed, "", "  ")
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	res.Write([]byte(xml.Header))
+	res.Write(content)
+}
@@ -0,0 +1,328 @@
+package website
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samkit-jain/go-blog/helpers"
+)
+
+// words read per minute for reading time estimates
+const wordsPerMinute = 200
+
+// language of dates, with the phrases of relative dates and reading times
+type locale struct {
+	months  [12]string
+	date    func(day int, month string, year int) string
+	clock   string               // layout of the time of day
+	justNow string               // less than a minute from now
+	ago     string               // e.g. "%s ago"
+	in      string               // e.g. "in %s"
+	units   map[string][2]string // singular and plural of every unit of relative dates
+	read    [2]string            // singular and plural of reading times, e.g. "%d minute read"
+}
+
+// languages of dates by GOBLOG_LOCALE
+var locales = map[string]locale{
+	"en": {
+		months:  [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		date:    func(day int, month string, year int) string { return fmt.Sprintf("%s %d, %d", month, day, year) },
+		clock:   "3:04 PM",
+		justNow: "just now",
+		ago:     "%s ago",
+		in:      "in %s",
+		units: map[string][2]string{
+			"year": {"year", "years"}, "month": {"month", "months"}, "week": {"week", "weeks"},
+			"day": {"day", "days"}, "hour": {"hour", "hours"}, "minute": {"minute", "minutes"},
+		},
+		read: [2]string{"%d minute read", "%d minutes read"},
+	},
+	"de": {
+		months:  [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		date:    func(day int, month string, year int) string { return fmt.Sprintf("%d. %s %d", day, month, year) },
+		clock:   "15:04",
+		justNow: "gerade eben",
+		ago:     "vor %s",
+		in:      "in %s",
+		units: map[string][2]string{
+			"year": {"Jahr", "Jahren"}, "month": {"Monat", "Monaten"}, "week": {"Woche", "Wochen"},
+			"day": {"Tag", "Tagen"}, "hour": {"Stunde", "Stunden"}, "minute": {"Minute", "Minuten"},
+		},
+		read: [2]string{"%d Minute Lesezeit", "%d Minuten Lesezeit"},
+	},
+	"fr": {
+		months:  [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		date:    func(day int, month string, year int) string { return fmt.Sprintf("%d %s %d", day, month, year) },
+		clock:   "15:04",
+		justNow: "à l'instant",
+		ago:     "il y a %s",
+		in:      "dans %s",
+		units: map[string][2]string{
+			"year": {"an", "ans"}, "month": {"mois", "mois"}, "week": {"semaine", "semaines"},
+			"day": {"jour", "jours"}, "hour": {"heure", "heures"}, "minute": {"minute", "minutes"},
+		},
+		read: [2]string{"%d minute de lecture", "%d minutes de lecture"},
+	},
+	"es": {
+		months:  [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		date:    func(day int, month string, year int) string { return fmt.Sprintf("%d de %s de %d", day, month, year) },
+		clock:   "15:04",
+		justNow: "ahora mismo",
+		ago:     "hace %s",
+		in:      "dentro de %s",
+		units: map[string][2]string{
+			"year": {"año", "años"}, "month": {"mes", "meses"}, "week": {"semana", "semanas"},
+			"day": {"día", "días"}, "hour": {"hora", "horas"}, "minute": {"minuto", "minutos"},
+		},
+		read: [2]string{"%d minuto de lectura", "%d minutos de lectura"},
+	},
+}
+
+// units of relative dates, largest first
+var relativeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+}
+
+// language and time zone of dates, set up by InitTheme
+var (
+	localeName = "en"
+	location   = time.UTC
+)
+
+// initFormats reads the language of dates from GOBLOG_LOCALE (en (default), de, fr or es) and their
+// time zone from GOBLOG_TIMEZONE (an IANA name, default UTC)
+func initFormats() error {
+	if name := os.Getenv("GOBLOG_LOCALE"); name != "" {
+		if _, ok := locales[name]; !ok {
+			return errors.New("unknown GOBLOG_LOCALE " + name + ", expected en, de, fr or es")
+		}
+
+		localeName = name
+	}
+
+	if name := os.Getenv("GOBLOG_TIMEZONE"); name != "" {
+		zone, err := time.LoadLocation(name)
+
+		if err != nil {
+			return err
+		}
+
+		location = zone
+	}
+
+	return nil
+}
+
+// Formats returns the language and time zone of the dates of pages, e.g. "en UTC"
+func Formats() string {
+	return localeName + " " + location.String()
+}
+
+// templateFuncs returns the functions of the templates of theme
+//
+//	{{ excerpt 200 .Body }}          plain text of the first 200 characters, cut at a word
+//	{{ date .CreatedAt }}            localised date, e.g. January 2, 2006
+//	{{ datetime .CreatedAt }}        localised date and time
+//	{{ isodate .CreatedAt }}         RFC 3339 date, e.g. for <time datetime="...">
+//	{{ relative .UpdatedAt }}        e.g. 3 hours ago, as of rendering
+//	{{ readingTime .Body }}          estimated minutes to read, at least 1
+//	{{ minutesRead 3 }}              localised reading time, e.g. 3 minutes read
+//	{{ pluralize "minute" "minutes" 3 }}  3 minutes
+//	{{ postURL .Id }} {{ authorURL .AuthorInfo.AuthorId }} {{ tagURL . }}  paths of pages
+//	{{ absURL "/feed.xml" }}         absolute URL at GOBLOG_BASE_URL
+//	{{ asset "css/style.css" }}      fingerprinted URL of a static asset
+//	{{ locale }}                     language of dates, e.g. for <html lang="...">
+func templateFuncs(theme *Theme) template.FuncMap {
+	return template.FuncMap{
+		"excerpt":     excerpt,
+		"date":        formatDate,
+		"datetime":    formatDateTime,
+		"isodate":     formatISODate,
+		"relative":    relative,
+		"readingTime": readingTime,
+		"minutesRead": minutesRead,
+		"pluralize":   pluralize,
+		"postURL":     postURL,
+		"authorURL":   authorURL,
+		"tagURL":      tagURL,
+		"absURL":      absURL,
+		"asset":       theme.asset,
+		"locale":      func() string { return localeName },
+	}
+}
+
+// Markdown and HTML left out of excerpts and reading times
+var (
+	codeBlocks     = regexp.MustCompile("(?s)```.*?```")
+	htmlTags       = regexp.MustCompile(`<[^>]*>`)
+	markdownImages = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLinks  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	linePrefixes   = regexp.MustCompile(`(?m)^[ \t]{0,3}(#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+|\d+\.[ \t]+)`)
+	emphasis       = []*regexp.Regexp{
+		regexp.MustCompile(`\*\*([^*]+)\*\*`),
+		regexp.MustCompile(`__([^_]+)__`),
+		regexp.MustCompile(`~~([^~]+)~~`),
+		regexp.MustCompile("`([^`]+)`"),
+		regexp.MustCompile(`\*([^*\s][^*]*)\*`),
+	}
+	// only around words, so that snake_case stays
+	underscores = regexp.MustCompile(`(^|\W)_([^_\s][^_]*)_(\W|$)`)
+)
+
+// plainText returns text without its Markdown and HTML syntax, on one line
+func plainText(text string) string {
+	text = codeBlocks.ReplaceAllString(text, " ")
+	text = htmlTags.ReplaceAllString(text, " ")
+	text = markdownImages.ReplaceAllString(text, "$1")
+	text = markdownLinks.ReplaceAllString(text, "$1")
+	text = linePrefixes.ReplaceAllString(text, "")
+
+	for _, pattern := range emphasis {
+		text = pattern.ReplaceAllString(text, "$1")
+	}
+
+	text = underscores.ReplaceAllString(text, "$1$2$3")
+
+	// escaped again by the templates
+	text = html.UnescapeString(text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// excerpt returns the plain text of text, cut at the last word boundary within length characters
+// and followed by an ellipsis if it's longer
+func excerpt(length int, text string) string {
+	plain := []rune(plainText(text))
+
+	if len(plain) <= length {
+		return string(plain)
+	}
+
+	cut := length
+
+	// back to the space before the word that doesn't fit, unless it's the first word
+	for index := length; index > 0; index-- {
+		if unicode.IsSpace(plain[index]) {
+			cut = index
+			break
+		}
+	}
+
+	return strings.TrimRightFunc(string(plain[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// formatDate returns the date of t in the language and time zone of pages
+func formatDate(t time.Time) string {
+	t = t.In(location)
+	language := locales[localeName]
+
+	return language.date(t.Day(), language.months[t.Month()-1], t.Year())
+}
+
+// formatDateTime returns the date and time of day of t in the language and time zone of pages
+func formatDateTime(t time.Time) string {
+	return formatDate(t) + " " + t.In(location).Format(locales[localeName].clock)
+}
+
+// formatISODate returns t as an RFC 3339 date, in the time zone of pages
+func formatISODate(t time.Time) string {
+	return t.In(location).Format(time.RFC3339)
+}
+
+// relative returns how long ago (or in how long) t is, in the largest whole unit
+func relative(t time.Time) string {
+	return relativeTo(t, time.Now())
+}
+
+// relativeTo returns how long before (or after) now t is
+func relativeTo(t, now time.Time) string {
+	language := locales[localeName]
+	difference := now.Sub(t)
+	pattern := language.ago
+
+	if difference < 0 {
+		difference, pattern = -difference, language.in
+	}
+
+	for _, unit := range relativeUnits {
+		if count := int(difference / unit.duration); count >= 1 {
+			forms := language.units[unit.name]
+			word := forms[1]
+
+			if count == 1 {
+				word = forms[0]
+			}
+
+			return fmt.Sprintf(pattern, strconv.Itoa(count)+" "+word)
+		}
+	}
+
+	return language.justNow
+}
+
+// readingTime returns the minutes it takes to read text, at least 1
+func readingTime(text string) int {
+	words := len(strings.Fields(plainText(text)))
+
+	return int(math.Max(1, math.Ceil(float64(words)/wordsPerMinute)))
+}
+
+// minutesRead returns the reading time of minutes in the language of pages, e.g. "3 minutes read"
+func minutesRead(minutes int) string {
+	forms := locales[localeName].read
+
+	if minutes == 1 {
+		return fmt.Sprintf(forms[0], minutes)
+	}
+
+	return fmt.Sprintf(forms[1], minutes)
+}
+
+// pluralize returns count followed by singular if it's 1, else by plural
+func pluralize(singular, plural string, count int) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+
+	return strconv.Itoa(count) + " " + plural
+}
+
+// postURL returns the path of the page of the post postId
+func postURL(postId string) string {
+	return "/post/" + url.PathEscape(postId)
+}
+
+// authorURL returns the path of the page of the author authorId
+func authorURL(authorId string) string {
+	return "/author/" + url.PathEscape(authorId)
+}
+
+// tagURL returns the path of the page of tag
+func tagURL(tag string) string {
+	return "/tag/" + url.PathEscape(tag) + "/"
+}
+
+// absURL returns the URL of path at GOBLOG_BASE_URL
+func absURL(path string) string {
+	return helpers.BaseURL() + path
+}
@@ -0,0 +1,74 @@
+package website
+
+import "testing"
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		plain string
+		cut   string // excerpt of 40 characters
+	}{
+		{
+			name:  "markdown",
+			text:  "# Title\n\nSome **bold** and *it* and _under_ snake_case ~~gone~~ `code`.\n\n```go\nfunc x() {}\n```\n> quote\n- item\n1. one [link](http://x) ![img](y.png) <b>tag</b> &amp; &lt;3 &nbsp;x",
+			plain: "Title Some bold and it and under snake_case gone code. quote item one link img tag & <3 x",
+			cut:   "Title Some bold and it and under…",
+		},
+		{
+			name:  "line prefixes only after newlines",
+			text:  "a\r# not heading\n  ## yes heading",
+			plain: "a # not heading yes heading",
+			cut:   "a # not heading yes heading",
+		},
+		{
+			name:  "underscores",
+			text:  "__x__ _y_. (_z_)",
+			plain: "x y. (z)",
+			cut:   "x y. (z)",
+		},
+		{
+			name:  "cut in runes before punctuation",
+			text:  "Ünïcødé 😀 words, here; end!!! more words follow here",
+			plain: "Ünïcødé 😀 words, here; end!!! more words follow here",
+			cut:   "Ünïcødé 😀 words, here; end!!! more words…",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := plainText(test.text); got != test.plain {
+				t.Errorf("plainText is %q, want %q", got, test.plain)
+			}
+
+			if got := excerpt(40, test.text); got != test.cut {
+				t.Errorf("excerpt is %q, want %q", got, test.cut)
+			}
+		})
+	}
+}
+
+func TestMinutesRead(t *testing.T) {
+	defer func(name string) { localeName = name }(localeName)
+
+	tests := []struct {
+		locale  string
+		minutes int
+		want    string
+	}{
+		{"en", 1, "1 minute read"},
+		{"en", 3, "3 minutes read"},
+		{"de", 1, "1 Minute Lesezeit"},
+		{"de", 12, "12 Minuten Lesezeit"},
+		{"fr", 2, "2 minutes de lecture"},
+		{"es", 1, "1 minuto de lectura"},
+	}
+
+	for _, test := range tests {
+		localeName = test.locale
+
+		if got := minutesRead(test.minutes); got != test.want {
+			t.Errorf("minutesRead(%d) in %s is %q, want %q", test.minutes, test.locale, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package website
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/samkit-jain/go-blog/storage"
+)
+
+// content types that are safe to display inline, everything else is downloaded
+var inlineTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type MediaHandler struct {
+}
+
+// MediaHandler's ServeHTTP serves uploaded files and thumbnails from the blob store
+//
+// Keys are derived from the content, so responses can be cached forever.
+func (h *MediaHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(req.URL.Path, "/")
+
+	if !storage.ValidKey(key) {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + key + `"`
+
+	if req.Header.Get("If-None-Match") == etag {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, info, err := storage.Default.Get(key)
+
+	if err == storage.ErrNotFound {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	defer blob.Close()
+
+	res.Header().Set("Content-Type", info.ContentType)
+	res.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	res.Header().Set("ETag", etag)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if info.Size >= 0 {
+		res.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+
+	if !info.ModTime.IsZero() {
+		res.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	if !inlineTypes[info.ContentType] {
+		res.Header().Set("Content-Disposition", "attachment")
+	}
+
+	if req.Method == "HEAD" {
+		return
+	}
+
+	io.Copy(res, blob)
+}
@@ -0,0 +1,163 @@
+package website
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/samkit-jain/go-blog/helpers"
+	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/oidc"
+)
+
+// cookie holding the flow between redirecting to the identity provider and its redirect back
+const oidcFlowCookie = "oidc_flow"
+
+// OIDCHandler signs authors in through the identity provider
+type OIDCHandler struct {
+}
+
+// OIDCHandler's ServeHTTP handles URLs of type
+//
+// GET	<base>/auth/oidc/			Sign in at the identity provider
+//
+// GET	<base>/auth/oidc/link		Link the signed in author to an identity at the identity provider
+//
+// GET	<base>/auth/oidc/callback	Redirect back from the identity provider
+func (h *OIDCHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	if req.URL.Path != "/" || oidc.Default == nil {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if req.Method != "GET" {
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch head {
+	case "":
+		startOIDCFlow(res, req, "")
+	case "link":
+		cookie, err := req.Cookie("token")
+
+		if err != nil || helpers.GetAuthorIdFromToken(cookie.Value) == "" {
+			http.Redirect(res, req, "/auth/signin/", http.StatusFound)
+			return
+		}
+
+		startOIDCFlow(res, req, helpers.GetAuthorIdFromToken(cookie.Value))
+	case "callback":
+		finishOIDCFlow(res, req)
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+}
+
+// oidcRedirectURI returns the URL the identity provider redirects back to
+func oidcRedirectURI() string {
+	return helpers.BaseURL() + "/auth/oidc/callback"
+}
+
+// startOIDCFlow remembers a new flow in a cookie and redirects to the identity provider, authorId
+// is the author to link the identity to or empty to sign in
+func startOIDCFlow(res http.ResponseWriter, req *http.Request, authorId string) {
+	flow, err := oidc.NewFlow(authorId)
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	encoded, err := flow.Encode()
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	location, err := oidc.Default.AuthCodeURL(oidcRedirectURI(), flow.State, flow.Nonce, flow.Verifier)
+
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		http.Error(res, "The identity provider is unavailable, please try again later", http.StatusBadGateway)
+		return
+	}
+
+	// Lax so the cookie is sent along on the identity provider's top-level redirect back
+	http.SetCookie(res, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    encoded,
+		Path:     "/auth/oidc/",
+		MaxAge:   10 * 60,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(helpers.BaseURL(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(res, req, location, http.StatusFound)
+}
+
+// finishOIDCFlow redeems the code the identity provider redirected back with and signs the author
+// in or links the identity
+func finishOIDCFlow(res http.ResponseWriter, req *http.Request) {
+	// the flow is single use
+	http.SetCookie(res, &http.Cookie{Name: oidcFlowCookie, Value: "", Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true})
+
+	if message := req.FormValue("error"); message != "" {
+		http.Error(res, "Sign in failed: "+message, http.StatusForbidden)
+		return
+	}
+
+	cookie, err := req.Cookie(oidcFlowCookie)
+
+	if err != nil {
+		http.Error(res, oidc.ErrInvalidFlow.Error(), http.StatusForbidden)
+		return
+	}
+
+	flow, err := oidc.DecodeFlow(cookie.Value)
+
+	if err != nil || req.FormValue("state") != flow.State {
+		http.Error(res, oidc.ErrInvalidFlow.Error(), http.StatusForbidden)
+		return
+	}
+
+	identity, err := oidc.Default.Exchange(req.FormValue("code"), flow.Verifier, oidcRedirectURI(), flow.Nonce)
+
+	if err != nil {
+		log.Printf("oidc: exchanging code: %v", err)
+		http.Error(res, "Signing in with the identity provider failed", http.StatusForbidden)
+		return
+	}
+
+	if flow.AuthorId != "" {
+		if err := models.LinkIdentity(flow.AuthorId, identity); err == models.ErrIdentityLinked {
+			http.Error(res, err.Error(), http.StatusConflict)
+		} else if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		} else {
+			renderTemplate(res, "message", "Your account has been linked, you can now sign in with the identity provider.")
+		}
+
+		return
+	}
+
+	authorId, err := models.LoginWithIdentity(identity, oidc.Default.Provision)
+
+	if err == models.ErrIdentityNotLinked {
+		http.Error(res, "No account is linked to this identity, sign in and link it first", http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the identity provider is trusted to have done any second factor
+	startSession(res, req, authorId)
+}
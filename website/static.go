@@ -0,0 +1,77 @@
+package website
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+type StaticHandler struct {
+}
+
+// StaticHandler's ServeHTTP serves the static assets of the active theme
+//
+// Fingerprinted names (css/style.1a2b3c4d.css) change with the content, so they can be cached
+// forever. Plain names (css/style.css) are revalidated, e.g. for url() references in stylesheets.
+//
+// GET	<base>/static/*path
+func (h *StaticHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// assets of the last theme that loaded are served while the theme is broken in development mode
+	theme, _ := CurrentTheme()
+
+	if theme == nil {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	asset, ok := theme.assets[strings.TrimPrefix(req.URL.Path, "/")]
+
+	if !ok {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	file, err := asset.fsys.Open(asset.name)
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+
+	// embedded and os files can seek, others are read at once
+	if !ok {
+		data, err := io.ReadAll(file)
+
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		content = bytes.NewReader(data)
+	}
+
+	if asset.fingerprint {
+		res.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		res.Header().Set("Cache-Control", "public, no-cache")
+	}
+
+	res.Header().Set("ETag", `"`+asset.hash[:32]+`"`)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// the content type is taken from the extension, the modification time is left out as
+	// embedded files have none
+	http.ServeContent(res, req, path.Base(asset.name), time.Time{}, content)
+}
@@ -0,0 +1,416 @@
+package website
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samkit-jain/go-blog/themes"
+)
+
+// name of the theme embedded in the binary
+const defaultTheme = "default"
+
+// Theme is the look of the website, the templates and static assets of a directory laid out as
+//
+//	layout.html       base layout of every page, with the blocks "title", "head", "content" and "scripts"
+//	partials/*.html   templates shared by the pages, e.g. {{ template "header" . }}
+//	pages/*.html      a template per page (home.html, post.html, ...) defining the layout's blocks
+//	static/...        assets served under /static/, {{ asset "css/style.css" }} is their URL
+//
+// Templates can use the functions listed by templateFuncs.
+//
+// Files missing from a theme are taken from the default theme, so themes only hold what they
+// change. Assets are served with their content's hash in their names (css/style.1a2b3c4d.css), so
+// they can be cached forever.
+type Theme struct {
+	Name    string
+	Version string // hash of every file, changes whenever one does
+
+	pages   map[string]*template.Template // by page name, e.g. "post"
+	sources map[string]string             // contents of the templates by file, for diagnostics
+	assets  map[string]themeAsset         // by path under /static/, fingerprinted or not
+	urls    map[string]string             // fingerprinted URLs by asset name, e.g. css/style.css
+}
+
+// static asset of a theme
+type themeAsset struct {
+	fsys        fs.FS
+	name        string // in fsys
+	hash        string // of the content
+	fingerprint bool   // served under a name including hash
+}
+
+// TemplateError is a template of a theme that doesn't parse
+type TemplateError struct {
+	File   string // in the theme, e.g. partials/header.html
+	Line   int    // line of the error, 0 if unknown
+	Source string // content of the file
+	Err    error
+}
+
+func (e *TemplateError) Error() string {
+	return e.Err.Error()
+}
+
+// file and line of a template error, e.g. template: partials/header.html:3: unexpected "}" in operand
+var templateErrorLine = regexp.MustCompile(`^template: ([^:]+):(\d+):`)
+
+// newTemplateError returns the error err of parsing the file of a theme holding source
+func newTemplateError(file, source string, err error) *TemplateError {
+	templateError := &TemplateError{File: file, Source: source, Err: err}
+
+	if match := templateErrorLine.FindStringSubmatch(err.Error()); match != nil && match[1] == file {
+		templateError.Line, _ = strconv.Atoi(match[2])
+	}
+
+	return templateError
+}
+
+// Theme's templateError returns the error err of executing a template with the file it names, if
+// it's one of the theme's
+func (t *Theme) templateError(err error) error {
+	if match := templateErrorLine.FindStringSubmatch(err.Error()); match != nil {
+		if source, ok := t.sources[match[1]]; ok {
+			return newTemplateError(match[1], source, err)
+		}
+	}
+
+	return err
+}
+
+// development mode and the name of the active theme, set up by InitTheme
+var (
+	devMode   bool
+	themeName string
+)
+
+var (
+	themeMutex  sync.RWMutex
+	activeTheme *Theme // last theme that loaded
+	themeError  error  // why the theme's files changed since don't load, development mode only
+)
+
+// CurrentTheme returns the active theme, or the error loading it in development mode
+func CurrentTheme() (*Theme, error) {
+	themeMutex.RLock()
+	defer themeMutex.RUnlock()
+
+	if themeError != nil {
+		return activeTheme, themeError
+	}
+
+	return activeTheme, nil
+}
+
+// setTheme makes theme the active theme, unless err
+func setTheme(theme *Theme, err error) {
+	themeMutex.Lock()
+	defer themeMutex.Unlock()
+
+	if err == nil {
+		activeTheme = theme
+	}
+
+	themeError = err
+}
+
+// InitTheme loads the theme named by GOBLOG_THEME (default "default") from the directory
+// GOBLOG_THEMES_DIR (default "themes"), the default theme is embedded in the binary, and the
+// language and time zone of dates, see initFormats
+//
+// With GOBLOG_DEV=true the themes, the default one included, are read from the directory and
+// reloaded whenever a file changes. Templates that don't parse are shown as a diagnostic page
+// instead of stopping the app.
+func InitTheme() {
+	name := os.Getenv("GOBLOG_THEME")
+
+	if name == "" {
+		name = defaultTheme
+	}
+
+	dir := os.Getenv("GOBLOG_THEMES_DIR")
+
+	if dir == "" {
+		dir = "themes"
+	}
+
+	devMode, themeName = os.Getenv("GOBLOG_DEV") == "true", name
+
+	if err := initFormats(); err != nil {
+		panic(err)
+	}
+
+	if devMode {
+		// before loading, so that changes made meanwhile are picked up
+		snapshot := themeSnapshot(dir, name)
+		theme, err := openTheme(dir, name, true)
+
+		if err != nil {
+			log.Printf("loading theme %s: %v", name, err)
+		}
+
+		setTheme(theme, err)
+
+		go watchTheme(dir, name, snapshot, time.Second)
+
+		return
+	}
+
+	theme, err := OpenTheme(dir, name)
+
+	if err != nil {
+		panic("loading theme " + name + ": " + err.Error())
+	}
+
+	setTheme(theme, nil)
+}
+
+// OpenTheme loads the theme name from the directory dir, over the embedded default theme
+func OpenTheme(dir, name string) (*Theme, error) {
+	return openTheme(dir, name, false)
+}
+
+// openTheme loads the theme name from the directory dir over the default theme, read from dir
+// instead of the binary if fromDisk and dir has it
+func openTheme(dir, name string, fromDisk bool) (*Theme, error) {
+	fallback := themes.Default
+
+	if fromDisk && isDir(filepath.Join(dir, defaultTheme)) {
+		fallback = os.DirFS(filepath.Join(dir, defaultTheme))
+	}
+
+	if name == defaultTheme {
+		return LoadTheme(name, nil, fallback)
+	}
+
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, errors.New("invalid theme name")
+	}
+
+	if !isDir(filepath.Join(dir, name)) {
+		return nil, errors.New(filepath.Join(dir, name) + " is not a directory")
+	}
+
+	return LoadTheme(name, os.DirFS(filepath.Join(dir, name)), fallback)
+}
+
+// watchTheme reloads the theme name from dir every interval in which a file of it or of the default
+// theme changed, from their state in last
+func watchTheme(dir, name, last string, interval time.Duration) {
+	for range time.Tick(interval) {
+		snapshot := themeSnapshot(dir, name)
+
+		if snapshot == last {
+			continue
+		}
+
+		last = snapshot
+		theme, err := openTheme(dir, name, true)
+
+		if err != nil {
+			log.Printf("reloading theme %s: %v", name, err)
+		} else {
+			log.Printf("reloaded theme %s", name)
+		}
+
+		setTheme(theme, err)
+	}
+}
+
+// themeSnapshot returns the names, sizes and modification times of the files of the theme name and
+// the default theme in dir
+func themeSnapshot(dir, name string) string {
+	var snapshot strings.Builder
+
+	for _, theme := range []string{defaultTheme, name} {
+		filepath.WalkDir(filepath.Join(dir, theme), func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+
+			if info, err := entry.Info(); err == nil {
+				fmt.Fprintf(&snapshot, "%s %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+			}
+
+			return nil
+		})
+	}
+
+	return snapshot.String()
+}
+
+// isDir checks whether name is a directory
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+
+	return err == nil && info.IsDir()
+}
+
+// LoadTheme parses the templates and lists the assets of the theme fsys, with the files missing
+// from it taken from fallback, fsys may be nil to only use fallback
+func LoadTheme(name string, fsys, fallback fs.FS) (*Theme, error) {
+	files, err := themeFiles(fallback, nil)
+
+	if err == nil && fsys != nil {
+		files, err = themeFiles(fsys, files)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	theme := &Theme{Name: name, pages: map[string]*template.Template{}, sources: map[string]string{}, assets: map[string]themeAsset{}, urls: map[string]string{}}
+	names := make([]string, 0, len(files))
+
+	for file := range files {
+		names = append(names, file)
+	}
+
+	sort.Strings(names)
+
+	version := sha256.New()
+	contents := map[string][]byte{}
+
+	for _, file := range names {
+		content, err := fs.ReadFile(files[file], file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+
+		fmt.Fprintf(version, "%s %s\n", file, hash)
+
+		if asset, ok := strings.CutPrefix(file, "static/"); ok {
+			fingerprinted := fingerprintName(asset, hash[:8])
+
+			theme.assets[asset] = themeAsset{fsys: files[file], name: file, hash: hash}
+			theme.assets[fingerprinted] = themeAsset{fsys: files[file], name: file, hash: hash, fingerprint: true}
+			theme.urls[asset] = "/static/" + fingerprinted
+		} else {
+			contents[file] = content
+			theme.sources[file] = string(content)
+		}
+	}
+
+	theme.Version = hex.EncodeToString(version.Sum(nil)[:16])
+
+	layout, ok := contents["layout.html"]
+
+	if !ok {
+		return nil, errors.New("theme has no layout.html")
+	}
+
+	base, err := template.New("layout.html").Funcs(templateFuncs(theme)).Parse(string(layout))
+
+	if err != nil {
+		return nil, newTemplateError("layout.html", string(layout), err)
+	}
+
+	for _, file := range names {
+		if strings.HasPrefix(file, "partials/") && path.Ext(file) == ".html" {
+			if _, err = base.New(file).Parse(string(contents[file])); err != nil {
+				return nil, newTemplateError(file, string(contents[file]), err)
+			}
+		}
+	}
+
+	for _, file := range names {
+		if !strings.HasPrefix(file, "pages/") || path.Ext(file) != ".html" {
+			continue
+		}
+
+		page, err := base.Clone()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = page.New(file).Parse(string(contents[file])); err != nil {
+			return nil, newTemplateError(file, string(contents[file]), err)
+		}
+
+		theme.pages[strings.TrimSuffix(path.Base(file), ".html")] = page
+	}
+
+	return theme, nil
+}
+
+// themeFiles adds the files of fsys to files, by their names in fsys, replacing the same files of
+// other themes
+func themeFiles(fsys fs.FS, files map[string]fs.FS) (map[string]fs.FS, error) {
+	if files == nil {
+		files = map[string]fs.FS{}
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// e.g. .DS_Store or editors' swap files
+		if strings.HasPrefix(entry.Name(), ".") && name != "." {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.IsDir() {
+			files[name] = fsys
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// fingerprintName returns name with hash before its extension, css/style.css becomes
+// css/style.<hash>.css
+func fingerprintName(name, hash string) string {
+	extension := path.Ext(name)
+
+	return strings.TrimSuffix(name, extension) + "." + hash + extension
+}
+
+// Theme's asset returns the fingerprinted URL of the asset name, e.g. css/style.css, templates call
+// it as {{ asset "css/style.css" }}
+func (t *Theme) asset(name string) (string, error) {
+	url, ok := t.urls[strings.TrimPrefix(name, "/")]
+
+	if !ok {
+		return "", errors.New("theme has no asset " + name)
+	}
+
+	return url, nil
+}
+
+// Theme's Assets returns the hashes of the contents of the assets by their paths under /static/,
+// with and without fingerprints
+func (t *Theme) Assets() map[string]string {
+	result := make(map[string]string, len(t.assets))
+
+	for name, asset := range t.assets {
+		result[name] = asset.hash
+	}
+
+	return result
+}
@@ -1,29 +1,44 @@
 package website
 
 import (
+	"bytes"
 	"fmt"
-	"html/template"
+	"log"
 	"net/http"
 
 	"github.com/samkit-jain/go-blog/helpers"
 	"github.com/samkit-jain/go-blog/models"
+	"github.com/samkit-jain/go-blog/oidc"
+	"github.com/samkit-jain/go-blog/types"
 )
 
 type WebsiteHandler struct {
 	AuthorHandler *AuthorHandler
 	AuthHandler   *AuthHandler
+	MediaHandler  *MediaHandler
 	PostHandler   *PostHandler
 	RootHandler   *RootHandler
+	TagHandler    *TagHandler
+	FeedHandler   *FeedHandler
+	StaticHandler *StaticHandler
 }
 
 func NewWebsiteHandler() *WebsiteHandler {
 	return &WebsiteHandler{
 		AuthorHandler: new(AuthorHandler),
+		MediaHandler:  new(MediaHandler),
 		PostHandler:   new(PostHandler),
 		RootHandler:   new(RootHandler),
+		TagHandler:    new(TagHandler),
+		FeedHandler:   new(FeedHandler),
+		StaticHandler: new(StaticHandler),
 		AuthHandler: &AuthHandler{
+			OIDCHandler:   new(OIDCHandler),
 			SignupHandler: new(SignupHandler),
 			SigninHandler: new(SigninHandler),
+			VerifyHandler: new(VerifyHandler),
+			ForgotHandler: new(ForgotHandler),
+			ResetHandler:  new(ResetHandler),
 		},
 	}
 }
@@ -42,6 +57,14 @@ func (h *WebsiteHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.AuthorHandler.ServeHTTP(res, req)
 	case "post":
 		h.PostHandler.ServeHTTP(res, req)
+	case "media":
+		h.MediaHandler.ServeHTTP(res, req)
+	case "tag":
+		h.TagHandler.ServeHTTP(res, req)
+	case "feed.xml":
+		h.FeedHandler.ServeHTTP(res, req)
+	case "static":
+		h.StaticHandler.ServeHTTP(res, req)
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
 	}
@@ -56,7 +79,7 @@ func (h *AuthorHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	var authorId string
 	authorId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
 
-	if req.URL.Path != "/" {
+	if (req.URL.Path != "/" && req.URL.Path != "/feed.xml") || !helpers.IsValidId(authorId) {
 		http.Error(res, "Not Found", http.StatusNotFound)
 		return
 	}
@@ -72,9 +95,53 @@ func (h *AuthorHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		// depending on the error
 	}
 
+	if req.URL.Path == "/feed.xml" {
+		writeFeed(res, content.AuthorInfo.Username, "/author/"+authorId+"/", content.List)
+		return
+	}
+
 	renderTemplate(res, "author", content)
 }
 
+type TagHandler struct {
+}
+
+// TagHandler's ServeHTTP lists the posts having a tag, newest first
+//
+// GET	<base>/tag/:tag/
+// GET	<base>/tag/:tag/feed.xml
+func (h *TagHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var tag string
+	tag, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	// tags are stored normalised
+	tags := helpers.NormaliseTags([]string{tag})
+
+	if (req.URL.Path != "/" && req.URL.Path != "/feed.xml") || len(tags) == 0 {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	posts, err := models.GetTagPosts(tags[0])
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(posts) == 0 {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if req.URL.Path == "/feed.xml" {
+		writeFeed(res, "Posts tagged "+tags[0], tagURL(tags[0]), posts)
+		return
+	}
+
+	renderTemplate(res, "tag", types.TagPosts{Tag: tags[0], List: posts})
+}
+
 type PostHandler struct {
 }
 
@@ -82,7 +149,15 @@ func (h *PostHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	var postId string
 	postId, req.URL.Path = helpers.ShiftPath(req.URL.Path)
 
-	if req.URL.Path != "/" {
+	// imported posts are also found by the slug they had in their old blog
+	if req.URL.Path == "/" && postId != "" && !helpers.IsValidId(postId) {
+		if importedId, err := models.GetPostIdBySlug(postId); err == nil {
+			http.Redirect(res, req, "/post/"+importedId, http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	if (req.URL.Path != "/" && req.URL.Path != "/summary") || !helpers.IsValidId(postId) {
 		http.Error(res, "Not Found", http.StatusNotFound)
 		return
 	}
@@ -98,12 +173,24 @@ func (h *PostHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		// depending on the error
 	}
 
+	res.Header().Set("Last-Modified", content.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	// the summary of the home page, for the posts the home page's script adds live
+	if req.URL.Path == "/summary" {
+		renderPartial(res, "home", "post_summary", content)
+		return
+	}
+
 	renderTemplate(res, "post", content)
 }
 
 type AuthHandler struct {
+	OIDCHandler   *OIDCHandler
 	SignupHandler *SignupHandler
 	SigninHandler *SigninHandler
+	VerifyHandler *VerifyHandler
+	ForgotHandler *ForgotHandler
+	ResetHandler  *ResetHandler
 }
 
 func (h *AuthHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
@@ -111,10 +198,18 @@ func (h *AuthHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
 
 	switch head {
+	case "oidc":
+		h.OIDCHandler.ServeHTTP(res, req)
 	case "signup":
 		h.SignupHandler.ServeHTTP(res, req)
 	case "signin":
 		h.SigninHandler.ServeHTTP(res, req)
+	case "verify":
+		h.VerifyHandler.ServeHTTP(res, req)
+	case "forgot":
+		h.ForgotHandler.ServeHTTP(res, req)
+	case "reset":
+		h.ResetHandler.ServeHTTP(res, req)
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
 	}
@@ -175,8 +270,14 @@ func (h *SignupEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 	if req.Method == "POST" {
 		un := req.FormValue("username")
 		ps := req.FormValue("password")
+		email := req.FormValue("email")
+
+		authorId, err := models.CreateAuthor(un, ps, email)
 
-		authorId, err := models.CreateAuthor(un, ps)
+		if err == models.ErrInvalidEmail {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
@@ -190,8 +291,9 @@ func (h *SignupEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 }
 
 type SigninHandler struct {
-	SigninStartHandler *SigninStartHandler
-	SigninEndHandler   *SigninEndHandler
+	SigninStartHandler  *SigninStartHandler
+	SigninEndHandler    *SigninEndHandler
+	SigninVerifyHandler *SigninVerifyHandler
 }
 
 func (h *SigninHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
@@ -203,6 +305,8 @@ func (h *SigninHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.SigninStartHandler.ServeHTTP(res, req)
 	case "finish":
 		h.SigninEndHandler.ServeHTTP(res, req)
+	case "verify":
+		h.SigninVerifyHandler.ServeHTTP(res, req)
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
 	}
@@ -219,7 +323,8 @@ func (h *SigninStartHandler) ServeHTTP(res http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	renderTemplate(res, "signin", nil)
+	// single sign-on is offered if configured
+	renderTemplate(res, "signin", oidc.Default != nil)
 }
 
 type SigninEndHandler struct {
@@ -253,8 +358,39 @@ func (h *SigninEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 		psMatched := helpers.CheckPasswordHash(ps, encryptedPassword)
 
 		if psMatched {
-			//http.Redirect(res, req, "/author/"+authorId, http.StatusFound)
-			http.Error(res, "Valid credentials", http.StatusFound)
+			// not failing the login if the stored hash can't be upgraded
+			if err := models.UpgradePasswordHash(un, ps, encryptedPassword); err != nil {
+				log.Printf("upgrading password hash of %s: %v", un, err)
+			}
+
+			authorId, err := models.GetAuthorIdByUsername(un)
+
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			totpEnabled, err := models.TOTPEnabled(authorId)
+
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			// second step asks for the code, remembering the checked password in a short-lived token
+			if totpEnabled {
+				pending, err := helpers.CreatePendingToken(authorId)
+
+				if err != nil {
+					http.Error(res, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				renderTemplate(res, "signin_otp", pending)
+				return
+			}
+
+			startSession(res, req, authorId)
 		} else {
 			http.Error(res, "Invalid credentials", http.StatusInternalServerError)
 		}
@@ -264,6 +400,208 @@ func (h *SigninEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 	}
 }
 
+type VerifyHandler struct {
+}
+
+func (h *VerifyHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	_, err := models.VerifyEmail(req.URL.Query().Get("token"))
+
+	if err == models.ErrInvalidToken {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(res, "message", "Your email address has been verified!")
+}
+
+type ForgotHandler struct {
+	ForgotStartHandler *ForgotStartHandler
+	ForgotEndHandler   *ForgotEndHandler
+}
+
+func (h *ForgotHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	switch head {
+	case "":
+		h.ForgotStartHandler.ServeHTTP(res, req)
+	case "finish":
+		h.ForgotEndHandler.ServeHTTP(res, req)
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+
+	return
+}
+
+type ForgotStartHandler struct {
+}
+
+func (h *ForgotStartHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	renderTemplate(res, "forgot", nil)
+}
+
+type ForgotEndHandler struct {
+}
+
+func (h *ForgotEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if req.Method == "POST" {
+		err := models.RequestPasswordReset(req.FormValue("username"))
+
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		renderTemplate(res, "message", "If the account has a verified email address, a reset link has been sent to it.")
+	} else {
+		http.Error(res, "Only POST is allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type ResetHandler struct {
+	ResetStartHandler *ResetStartHandler
+	ResetEndHandler   *ResetEndHandler
+}
+
+func (h *ResetHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+	head, req.URL.Path = helpers.ShiftPath(req.URL.Path)
+
+	switch head {
+	case "":
+		h.ResetStartHandler.ServeHTTP(res, req)
+	case "finish":
+		h.ResetEndHandler.ServeHTTP(res, req)
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+
+	return
+}
+
+type ResetStartHandler struct {
+}
+
+func (h *ResetStartHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// the token is only consumed when the form is submitted so that link previews don't use it up
+	renderTemplate(res, "reset", req.URL.Query().Get("token"))
+}
+
+type ResetEndHandler struct {
+}
+
+func (h *ResetEndHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if req.Method == "POST" {
+		_, err := models.ResetPassword(req.FormValue("token"), req.FormValue("password"))
+
+		if err == models.ErrInvalidToken {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(res, req, "/auth/signin/", http.StatusFound)
+	} else {
+		http.Error(res, "Only POST is allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type SigninVerifyHandler struct {
+}
+
+func (h *SigninVerifyHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if req.Method == "POST" {
+		authorId := helpers.GetAuthorIdFromPendingToken(req.FormValue("pending"))
+
+		if authorId == "" {
+			http.Error(res, "Sign in expired, please try again", http.StatusForbidden)
+			return
+		}
+
+		otpMatched, err := models.CheckSecondFactor(authorId, req.FormValue("otp"))
+
+		if err == models.ErrSecondFactorLocked {
+			http.Error(res, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if otpMatched {
+			startSession(res, req, authorId)
+		} else {
+			http.Error(res, "Invalid credentials", http.StatusInternalServerError)
+		}
+	} else {
+		http.Error(res, "Only POST is allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startSession stores a session token for authorId in a cookie and redirects to the author's page
+func startSession(res http.ResponseWriter, req *http.Request, authorId string) {
+	tokenString, err := helpers.CreateToken(authorId)
+
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     "token",
+		Value:    tokenString,
+		Path:     "/",
+		MaxAge:   24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(res, req, "/author/"+authorId, http.StatusFound)
+}
+
 type RootHandler struct {
 }
 
@@ -286,12 +624,65 @@ func (h *RootHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	http.Error(res, "Not Found", http.StatusNotFound)
 }
 
-var templates = template.Must(template.ParseGlob("templates/blog/*"))
-
+// renderTemplate renders the page tmpl of the active theme with data
+//
+// In development mode, templates that don't parse or fail are shown as a diagnostic page.
 func renderTemplate(res http.ResponseWriter, tmpl string, data interface{}) {
-	err := templates.ExecuteTemplate(res, tmpl+".html", data)
+	theme, err := CurrentTheme()
+
+	if err != nil {
+		renderDiagnostic(res, themeName, tmpl, err)
+		return
+	}
+
+	page, ok := theme.pages[tmpl]
+
+	if !ok {
+		http.Error(res, "Theme "+theme.Name+" has no page "+tmpl, http.StatusInternalServerError)
+		return
+	}
+
+	// buffered so that a failing template doesn't send half a page
+	var content bytes.Buffer
+
+	if err = page.ExecuteTemplate(&content, "layout.html", data); err != nil {
+		if devMode {
+			renderDiagnostic(res, theme.Name, tmpl, theme.templateError(err))
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	content.WriteTo(res)
+}
+
+// renderPartial renders the partial name, as included in the page tmpl of the active theme, with
+// data
+func renderPartial(res http.ResponseWriter, tmpl, name string, data interface{}) {
+	theme, err := CurrentTheme()
 
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page, ok := theme.pages[tmpl]
+
+	if !ok || page.Lookup(name) == nil {
+		http.Error(res, "Theme "+theme.Name+" has no partial "+name+" in page "+tmpl, http.StatusNotFound)
+		return
 	}
+
+	var content bytes.Buffer
+
+	if err = page.ExecuteTemplate(&content, name, data); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	content.WriteTo(res)
 }
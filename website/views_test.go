@@ -0,0 +1,42 @@
+package website
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samkit-jain/go-blog/dbtest"
+)
+
+func TestPostSummary(t *testing.T) {
+	defer func(theme *Theme) { setTheme(theme, nil) }(activeTheme)
+
+	setTheme(OpenTheme(t.TempDir(), defaultTheme))
+
+	const postId = "01HV0000000000000000000P01"
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	db := dbtest.Open(t)
+	db.On("WHERE posts.post_id=$1").
+		Rows(dbtest.Row("sam", "01HV0000000000000000000A01", created, "Hello", "Some **bold** text", created, created, 1, []string{"go"}))
+	db.On("FROM attachments WHERE post_id=ANY")
+
+	res := httptest.NewRecorder()
+	NewWebsiteHandler().ServeHTTP(res, httptest.NewRequest("GET", "/post/"+postId+"/summary", nil))
+
+	if res.Code != 200 {
+		t.Fatalf("status %d: %s", res.Code, res.Body)
+	}
+
+	// only the partial, as on the home page
+	for _, want := range []string{`<article class="post-summary" data-post="` + postId + `">`, "March 1, 2024", "1 minute read", "<p>Some bold text</p>"} {
+		if !strings.Contains(res.Body.String(), want) {
+			t.Errorf("summary %s doesn't contain %q", res.Body, want)
+		}
+	}
+
+	if strings.Contains(res.Body.String(), "<html") {
+		t.Errorf("summary %s is a whole page", res.Body)
+	}
+}